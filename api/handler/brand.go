@@ -2,60 +2,63 @@ package handler
 
 import (
 	"fmt"
-	"time"
-
-	"github.com/markus-azer/products-service/pkg/entity"
 
 	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/product"
 )
 
 //MakeBrandHandlers make msg handlers
-func MakeBrandHandlers(msgRepo brand.MessagesRepository, service brand.UseCase) {
+//products referencing a renamed or deleted brand have the brand renamed or removed before the brand is stored,
+//a message failing on the products leaves the brand unchanged so applying it again renames the remaining products
+func MakeBrandHandlers(msgRepo brand.MessagesRepository, service brand.UseCase, productService product.UseCase) {
 	c := msgRepo.GetMessages()
 
 	go func() {
 		for msg := range c {
-			switch msg.Type {
-			case "BRAND_CREATED":
-				fmt.Println("BRAND_CREATED", msg)
-				b := &entity.Brand{
-					ID:      entity.ID(msg.ID),
-					Version: msg.Version,
-					//Name:        msg.Payload["Name"],
-					// Description: msg.Payload["Description"],
-					// Slug:        msg.Payload["Slug"],
-					// CreatedAt:   msg.Payload["CreatedAt"],
-				}
-
-				for key, item := range msg.Payload {
-					value, _ := item.(string)
-					fmt.Println("value =>>>>>>>>>", value)
-
-					switch key {
-					case "CreatedAt":
-						layout := "2006-01-02T15:04:05.000Z"
-						t, _ := time.Parse(layout, value)
-						b.CreatedAt = time.Time(t)
-						fmt.Println("t =>>>>>>>>>", t)
-					case "Description":
-						b.Description = value
-					case "Name":
-						b.Name = value
-					case "Slug":
-						b.Slug = value
-					}
-				}
-				fmt.Println("=>>>>>>>>>", b)
-				// brand := *entity.Brand{ID: msg.ID, Version: msg.Version, Name:}
-				service.Create(b)
-			case "BRAND_UPDATED":
-				fmt.Println("BRAND_UPDATED")
-			case "BRAND_DELETED":
-				fmt.Println("BRAND_DELETED")
-			default:
+			err := applyToProducts(productService, service, msg)
+			if err == nil {
+				_, err = service.Apply(msg)
+			}
+
+			switch err {
+			case nil:
+			case brand.ErrStaleVersion:
+				fmt.Println("Ignored stale", msg.Type, msg.ID, msg.Version)
+			case brand.ErrUnknownMessage:
 				fmt.Println("No handler")
+			default:
+				fmt.Println("Error on handling", msg.Type, msg.ID, err)
 			}
 		}
 	}()
+}
+
+//applyToProducts rename or remove the brand the message applies to on the products
+func applyToProducts(productService product.UseCase, service brand.UseCase, msg entity.Message) error {
+	b, err := service.Before(msg)
+	if err != nil {
+		return err
+	}
+
+	//An empty name would match every product
+	if b == nil || b.Name == "" {
+		return nil
+	}
+
+	var e *entity.Error
+	switch msg.Type {
+	case "BRAND_UPDATED":
+		if u := brand.UpdateFromMessage(msg); u.Name != nil && *u.Name != "" && *u.Name != b.Name {
+			e = productService.RenameBrand(b.Name, *u.Name)
+		}
+	case "BRAND_DELETED":
+		e = productService.RemoveBrand(b.Name)
+	}
+
+	if e != nil {
+		return e
+	}
 
+	return nil
 }
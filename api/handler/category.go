@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/markus-azer/products-service/pkg/category"
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//MakeCategoryHandlers make msg handlers
+func MakeCategoryHandlers(msgRepo category.MessagesRepository, service category.UseCase) {
+	c := msgRepo.GetMessages()
+
+	go func() {
+		for msg := range c {
+			var err error
+
+			switch msg.Type {
+			case "CATEGORY_CREATED":
+				err = service.Create(category.FromMessage(msg))
+			case "CATEGORY_UPDATED":
+				err = service.UpdateOne(entity.ID(msg.ID), category.UpdateFromMessage(msg))
+			case "CATEGORY_DELETED":
+				err = service.DeleteOne(entity.ID(msg.ID), msg.Version)
+			default:
+				fmt.Println("No handler")
+			}
+
+			switch err {
+			case nil:
+			case category.ErrStaleVersion:
+				fmt.Println("Ignored stale", msg.Type, msg.ID, msg.Version)
+			default:
+				fmt.Println("Error on handling", msg.Type, msg.ID, err)
+			}
+		}
+	}()
+}
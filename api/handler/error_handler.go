@@ -39,6 +39,14 @@ func errorHandler(err error) *response {
 	return &response{StatusCode: StatusCode, Message: string(e.ErrorMessage), Errors: e.Errors, Successful: false}
 }
 
+//deliveryPending the change is stored but its events are not delivered yet, the outbox relay retries them
+func deliveryPending(payload *response, e *entity.Error) *response {
+	payload.StatusCode = http.StatusAccepted
+	payload.Message = string(e.ErrorMessage)
+
+	return payload
+}
+
 func serializationErrorHandler(err error) *response {
 	switch {
 	case err == io.EOF:
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/location"
+)
+
+func createLocation(service location.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var l location.CreateLocationDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&l)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		ID, e := service.Create(l)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"id": ID}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findLocations(service location.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locations, e := service.FindMany()
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"locations": locations}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findLocation(service location.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		l, e := service.FindOneByID(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"location": l}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//MakeLocationHandlers make url handlers
+func MakeLocationHandlers(r *mux.Router, service location.UseCase) {
+	r.Handle("/v1/locations", findLocations(service)).Methods("GET").Name("ListLocations")
+	r.Handle("/v1/locations/{id}", findLocation(service)).Methods("GET").Name("GetLocation")
+	r.Handle("/v1/locations", createLocation(service)).Methods("POST").Name("CreateLocation")
+	preflight(r, "/v1/locations", "/v1/locations/{id}")
+}
@@ -0,0 +1,17 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+//preflight register a single OPTIONS route per path, the CORS middleware answers the preflight requests
+//the method routes don't match OPTIONS so a preflight isn't handled by the first route of its path
+func preflight(r *mux.Router, paths ...string) {
+	for _, path := range paths {
+		r.Handle(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})).Methods("OPTIONS")
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/pricelist"
+)
+
+func createPriceList(service pricelist.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var l pricelist.CreatePriceListDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&l)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		ID, e := service.Create(l)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"id": ID}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findPriceLists(service pricelist.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lists, e := service.FindMany()
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"priceLists": lists}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findPriceList(service pricelist.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		l, e := service.FindOneByID(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"priceList": l}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//MakePriceListHandlers make url handlers
+func MakePriceListHandlers(r *mux.Router, service pricelist.UseCase) {
+	r.Handle("/v1/price-lists", findPriceLists(service)).Methods("GET").Name("ListPriceLists")
+	r.Handle("/v1/price-lists/{id}", findPriceList(service)).Methods("GET").Name("GetPriceList")
+	r.Handle("/v1/price-lists", createPriceList(service)).Methods("POST").Name("CreatePriceList")
+	preflight(r, "/v1/price-lists", "/v1/price-lists/{id}")
+}
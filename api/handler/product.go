@@ -3,7 +3,10 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/markus-azer/products-service/pkg/entity"
@@ -31,7 +34,7 @@ func create(service product.UseCase) http.Handler {
 		}
 
 		ID, v, err := service.Create(p)
-		if err != nil {
+		if err != nil && entity.KindE(err) != entity.DeliveryFailed {
 			payload := errorHandler(err)
 			w.WriteHeader(payload.StatusCode)
 			json.NewEncoder(w).Encode(payload)
@@ -39,6 +42,9 @@ func create(service product.UseCase) http.Handler {
 		}
 
 		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"id": ID, "version": v}, Successful: true}
+		if err != nil {
+			deliveryPending(payload, err.(*entity.Error))
+		}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 		return
@@ -50,7 +56,7 @@ func update(service product.UseCase) http.Handler {
 
 		vars := mux.Vars(r)
 		ID := entity.ID(vars["id"])
-		version, err := strconv.ParseInt(vars["version"], 0, 8)
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
 		if err != nil {
 			payload := &response{StatusCode: 500, Message: "Internal Service Error", Successful: false}
 			w.WriteHeader(payload.StatusCode)
@@ -71,16 +77,19 @@ func update(service product.UseCase) http.Handler {
 			return
 		}
 
-		v, err := service.UpdateOne(ID, int32(version), p)
+		v, e := service.UpdateOne(ID, int32(version), p)
 
-		if err != nil {
-			payload := errorHandler(err)
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
 			w.WriteHeader(payload.StatusCode)
 			json.NewEncoder(w).Encode(payload)
 			return
 		}
 
 		payload := &response{StatusCode: http.StatusAccepted, Message: "Updated Successfully", Data: map[string]interface{}{"id": ID, "version": v}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 	})
@@ -90,7 +99,7 @@ func delete(service product.UseCase) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		ID := entity.ID(vars["id"])
-		version, err := strconv.ParseInt(vars["version"], 0, 8)
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
 		if err != nil {
 			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide Valid version value", Successful: false}
 			w.WriteHeader(payload.StatusCode)
@@ -98,7 +107,40 @@ func delete(service product.UseCase) http.Handler {
 			return
 		}
 
-		err = service.Delete(ID, int32(version))
+		e := service.Delete(ID, int32(version))
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusAccepted, Message: "Deleted Successfully", Data: map[string]interface{}{}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findOne(service product.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		q := r.URL.Query()
+
+		if q.Get("asOf") != "" || q.Get("version") != "" {
+			findOneAt(service, ID, q).ServeHTTP(w, r)
+			return
+		}
+
+		var embed []string
+		if e := q.Get("embed"); e != "" {
+			embed = strings.Split(e, ",")
+		}
+
+		p, err := service.FindOneByID(ID, embed)
 		if err != nil {
 			payload := errorHandler(err)
 			w.WriteHeader(payload.StatusCode)
@@ -106,7 +148,166 @@ func delete(service product.UseCase) http.Handler {
 			return
 		}
 
-		payload := &response{StatusCode: http.StatusAccepted, Message: "Deleted Successfully", Data: map[string]interface{}{}, Successful: true}
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"product": p, "version": p.Version}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findOneAt product as it was at ?version=N or ?asOf=<RFC3339>, embeds aren't available for past states
+func findOneAt(service product.UseCase, ID entity.ID, q url.Values) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var errs []entity.ErrorField
+		dto := product.FindOneAtDTO{Version: int32(queryInt(q, "version", 32, &errs))}
+
+		if v := q.Get("asOf"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				errs = append(errs, entity.ErrorField{Field: "asOf", Error: "Provide RFC3339 timestamp"})
+			}
+			dto.AsOf = t
+		}
+
+		if q.Get("embed") != "" {
+			errs = append(errs, entity.ErrorField{Field: "embed", Error: "Not allowed with asOf or version"})
+		}
+
+		if len(errs) > 0 {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide valid Query", Errors: errs, Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		p, err := service.FindOneAt(ID, dto)
+		if err != nil {
+			payload := errorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"product": p, "version": p.Version}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findHistory(service product.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		entries, err := service.History(ID)
+		if err != nil {
+			payload := errorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"history": entries}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//queryInt parse an optional integer query param, appends to errs if it's not a valid number
+func queryInt(q url.Values, key string, bitSize int, errs *[]entity.ErrorField) int64 {
+	v := q.Get(key)
+	if v == "" {
+		return 0
+	}
+
+	i, err := strconv.ParseInt(v, 10, bitSize)
+	if err != nil {
+		*errs = append(*errs, entity.ErrorField{Field: key, Error: "Provide valid number"})
+	}
+
+	return i
+}
+
+func findMany(service product.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		dto := product.ListProductsDTO{
+			Brand:    q.Get("brand"),
+			Category: q.Get("category"),
+			Seller:   q.Get("seller"),
+			Status:   q.Get("status"),
+			Currency: q.Get("currency"),
+			Sort:     q.Get("sort"),
+			Order:    q.Get("order"),
+			Cursor:   q.Get("cursor"),
+		}
+
+		var errs []entity.ErrorField
+		dto.MinPrice = queryInt(q, "minPrice", 64, &errs)
+		dto.MaxPrice = queryInt(q, "maxPrice", 64, &errs)
+		dto.Limit = int(queryInt(q, "limit", 32, &errs))
+
+		if len(errs) > 0 {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide valid Query", Errors: errs, Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		products, next, err := service.FindMany(dto)
+		if err != nil {
+			payload := errorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		data := map[string]interface{}{"products": products}
+		if next != "" {
+			data["nextCursor"] = next
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: data, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func search(service product.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		dto := product.SearchProductsDTO{
+			Query:    q.Get("q"),
+			Brand:    q.Get("brand"),
+			Category: q.Get("category"),
+			Seller:   q.Get("seller"),
+			Status:   q.Get("status"),
+			Currency: q.Get("currency"),
+		}
+
+		var errs []entity.ErrorField
+		dto.MinPrice = queryInt(q, "minPrice", 64, &errs)
+		dto.MaxPrice = queryInt(q, "maxPrice", 64, &errs)
+		dto.Page = int(queryInt(q, "page", 32, &errs))
+		dto.Limit = int(queryInt(q, "limit", 32, &errs))
+
+		if len(errs) > 0 {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide valid Query", Errors: errs, Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		result, err := service.Search(dto)
+		if err != nil {
+			payload := errorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"products": result.Hits, "facets": result.Facets, "total": result.Total}, Successful: true}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 	})
@@ -114,7 +315,12 @@ func delete(service product.UseCase) http.Handler {
 
 //MakeProductHandlers make url handlers
 func MakeProductHandlers(r *mux.Router, service product.UseCase) {
-	r.Handle("/v1/products", create(service)).Methods("POST", "OPTIONS").Name("CreateProduct")
-	r.Handle("/v1/products/{id}/{version}", update(service)).Methods("PATCH", "OPTIONS").Name("UpdateProduct")
-	r.Handle("/v1/products/{id}/{version}", delete(service)).Methods("DELETE", "OPTIONS").Name("DeleteProduct")
+	r.Handle("/v1/products", findMany(service)).Methods("GET").Name("ListProducts")
+	r.Handle("/v1/products/search", search(service)).Methods("GET").Name("SearchProducts")
+	r.Handle("/v1/products/{id}", findOne(service)).Methods("GET").Name("GetProduct")
+	r.Handle("/v1/products/{id}/history", findHistory(service)).Methods("GET").Name("GetProductHistory")
+	r.Handle("/v1/products", create(service)).Methods("POST").Name("CreateProduct")
+	r.Handle("/v1/products/{id}/{version}", update(service)).Methods("PATCH").Name("UpdateProduct")
+	r.Handle("/v1/products/{id}/{version}", delete(service)).Methods("DELETE").Name("DeleteProduct")
+	preflight(r, "/v1/products", "/v1/products/search", "/v1/products/{id}", "/v1/products/{id}/history", "/v1/products/{id}/{version}")
 }
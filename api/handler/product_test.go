@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/gorilla/mux"
@@ -78,3 +79,143 @@ func TestCreateProduct(t *testing.T) {
 	assert.Equal(t, float64(v), resp.Data["version"])
 
 }
+
+func TestCreateProductDeliveryPending(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	ID := entity.NewID()
+	v := entity.Version(2)
+	service := product.NewMockUseCase(controller)
+	service.EXPECT().Create(gomock.Any()).Return(&ID, &v, &entity.Error{Op: "Create", Kind: entity.DeliveryFailed, ErrorMessage: "Created, events delivery pending"})
+
+	payload := []byte(`{"name": "Test product"}`)
+
+	req, err := http.NewRequest("POST", "localhost:8080/v1/products", bytes.NewBuffer(payload))
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+
+	create(service).ServeHTTP(rec, req)
+
+	res := rec.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	var resp *response
+	json.NewDecoder(res.Body).Decode(&resp)
+	assert.True(t, resp.Successful)
+	assert.Equal(t, "Created, events delivery pending", resp.Message)
+	assert.Equal(t, string(ID), resp.Data["id"])
+}
+
+func TestFindOneProduct(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	ID := entity.NewID()
+	p := &product.ProductDTO{Product: &entity.Product{ID: ID, Version: 4, Name: "Test product"}}
+	service := product.NewMockUseCase(controller)
+	service.EXPECT().FindOneByID(ID, []string{"variants", "brand"}).Return(p, nil)
+
+	r := mux.NewRouter()
+	MakeProductHandlers(r, service)
+	path, err := r.GetRoute("GetProduct").GetPathTemplate()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v1/products/{id}", path)
+
+	req, err := http.NewRequest("GET", "/v1/products/"+string(ID)+"?embed=variants,brand", nil)
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var resp *response
+	json.NewDecoder(res.Body).Decode(&resp)
+	assert.Equal(t, float64(4), resp.Data["version"])
+	assert.Equal(t, string(ID), resp.Data["product"].(map[string]interface{})["id"])
+}
+
+func TestFindOneProductAsOf(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	ID := entity.NewID()
+	asOf := time.Date(2020, 6, 2, 0, 0, 0, 0, time.UTC)
+	p := &entity.Product{ID: ID, Version: 2, Name: "Test product"}
+	service := product.NewMockUseCase(controller)
+	service.EXPECT().FindOneAt(ID, product.FindOneAtDTO{AsOf: asOf}).Return(p, nil)
+
+	r := mux.NewRouter()
+	MakeProductHandlers(r, service)
+
+	req, err := http.NewRequest("GET", "/v1/products/"+string(ID)+"?asOf=2020-06-02T00:00:00Z", nil)
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var resp *response
+	json.NewDecoder(res.Body).Decode(&resp)
+	assert.Equal(t, float64(2), resp.Data["version"])
+
+	req, err = http.NewRequest("GET", "/v1/products/"+string(ID)+"?asOf=yesterday", nil)
+	assert.Nil(t, err)
+	rec = httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Result().StatusCode)
+}
+
+func TestUpdateProductLargeVersion(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	ID := entity.NewID()
+	v := int32(301)
+	service := product.NewMockUseCase(controller)
+	service.EXPECT().UpdateOne(ID, int32(300), gomock.Any()).Return(&v, nil)
+
+	r := mux.NewRouter()
+	MakeProductHandlers(r, service)
+
+	req, err := http.NewRequest("PATCH", "/v1/products/"+string(ID)+"/300", bytes.NewBuffer([]byte(`{"name": "Test product"}`)))
+	assert.Nil(t, err)
+	rec := httptest.NewRecorder()
+
+	r.ServeHTTP(rec, req)
+
+	res := rec.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+}
+
+func TestProductsPreflight(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	service := product.NewMockUseCase(controller)
+
+	r := mux.NewRouter()
+	MakeProductHandlers(r, service)
+
+	req, err := http.NewRequest("OPTIONS", "/v1/products", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	// The preflight has its own route, it isn't matched to the list or the create route
+	var match mux.RouteMatch
+	assert.True(t, r.Match(req, &match))
+	assert.Equal(t, "", match.Route.GetName())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Result().StatusCode)
+}
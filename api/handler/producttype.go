@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/producttype"
+)
+
+func createProductType(service producttype.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var t producttype.CreateProductTypeDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&t)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		ID, e := service.Create(t)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"id": ID}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findProductTypes(service producttype.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		types, e := service.FindMany()
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"productTypes": types}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findProductType(service producttype.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		t, e := service.FindOneByID(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"productType": t}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//MakeProductTypeHandlers make url handlers
+func MakeProductTypeHandlers(r *mux.Router, service producttype.UseCase) {
+	r.Handle("/v1/product-types", findProductTypes(service)).Methods("GET").Name("ListProductTypes")
+	r.Handle("/v1/product-types/{id}", findProductType(service)).Methods("GET").Name("GetProductType")
+	r.Handle("/v1/product-types", createProductType(service)).Methods("POST").Name("CreateProductType")
+	preflight(r, "/v1/product-types", "/v1/product-types/{id}")
+}
@@ -26,16 +26,53 @@ func createVariant(service variant.UseCase) http.Handler {
 			return
 		}
 
-		ID, v, err := service.Create(variant)
+		ID, v, e := service.Create(variant)
 
-		if err != nil {
-			payload := errorHandler(err)
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
 			w.WriteHeader(payload.StatusCode)
 			json.NewEncoder(w).Encode(payload)
 			return
 		}
 
 		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"id": ID, "version": v}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func generateVariants(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var matrix variant.GenerateVariantsDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&matrix)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		IDs, e := service.GenerateVariants(matrix)
+
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Created Successfully", Data: map[string]interface{}{"ids": IDs}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 	})
@@ -46,7 +83,7 @@ func updateVariant(service variant.UseCase) http.Handler {
 
 		vars := mux.Vars(r)
 		ID := entity.ID(vars["id"])
-		version, err := strconv.ParseInt(vars["version"], 0, 8)
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
 		if err != nil {
 			payload := &response{StatusCode: 500, Message: "Internal Service Error", Successful: false}
 			w.WriteHeader(payload.StatusCode)
@@ -67,16 +104,19 @@ func updateVariant(service variant.UseCase) http.Handler {
 			return
 		}
 
-		v, err := service.UpdateOne(ID, int32(version), variant)
+		v, e := service.UpdateOne(ID, int32(version), variant)
 
-		if err != nil {
-			payload := errorHandler(err)
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
 			w.WriteHeader(payload.StatusCode)
 			json.NewEncoder(w).Encode(payload)
 			return
 		}
 
 		payload := &response{StatusCode: http.StatusAccepted, Message: "Updated Successfully", Data: map[string]interface{}{"id": ID, "version": v}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 	})
@@ -86,7 +126,7 @@ func deleteVariant(service variant.UseCase) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id := entity.ID(vars["id"])
-		version, err := strconv.ParseInt(vars["version"], 0, 8)
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
 		if err != nil {
 			payload := &response{StatusCode: 500, Message: "Internal Service Error", Successful: false}
 			w.WriteHeader(payload.StatusCode)
@@ -94,15 +134,362 @@ func deleteVariant(service variant.UseCase) http.Handler {
 			return
 		}
 
-		err = service.Delete(id, int32(version))
-		if err != nil {
-			payload := errorHandler(err)
+		e := service.Delete(id, int32(version))
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
 			w.WriteHeader(payload.StatusCode)
 			json.NewEncoder(w).Encode(payload)
 			return
 		}
 
 		payload := &response{StatusCode: http.StatusAccepted, Message: "Deleted Successfully", Data: map[string]interface{}{}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func setVariantPrice(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
+		if err != nil {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide Valid version value", Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		var price variant.SetPriceDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err = dec.Decode(&price)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		v, e := service.SetPrice(ID, int32(version), price)
+
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusAccepted, Message: "Updated Successfully", Data: map[string]interface{}{"id": ID, "version": v}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findVariant(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		v, e := service.FindOneByID(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"variant": v, "version": v.Version}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findVariantByBarcode variant by EAN-8, UPC-A, EAN-13 or GTIN-14 barcode
+func findVariantByBarcode(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		v, e := service.FindOneByBarcode(vars["barcode"])
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"variant": v, "version": v.Version}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func scheduleVariantSalePrice(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		version, err := strconv.ParseInt(vars["version"], 0, 32)
+		if err != nil {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide Valid version value", Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		var sale variant.ScheduleSalePriceDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err = dec.Decode(&sale)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		saleID, v, e := service.ScheduleSalePrice(ID, int32(version), sale)
+
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Scheduled Successfully", Data: map[string]interface{}{"id": ID, "salePrice": saleID, "version": v}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findVariantPrice effective variant price for ?currency=EUR&region=DE
+func findVariantPrice(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		q := r.URL.Query()
+
+		p, e := service.EffectivePrice(ID, variant.EffectivePriceDTO{Region: q.Get("region"), Currency: q.Get("currency")})
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"price": p}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findVariantLowestPrice current variant price with its lowest price over the previous ?days=30 in ?priceList=
+func findVariantLowestPrice(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		q := r.URL.Query()
+
+		var errs []entity.ErrorField
+		dto := variant.LowestPriceDTO{PriceList: entity.ID(q.Get("priceList"))}
+		dto.Days = int(queryInt(q, "days", 32, &errs))
+
+		if len(errs) > 0 {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide valid Query", Errors: errs, Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		l, e := service.LowestPrice(ID, dto)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"price": l}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func reserve(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var reservation variant.ReserveDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&reservation)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		res, e := service.Reserve(reservation)
+
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Reserved Successfully", Data: map[string]interface{}{"reservation": res}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func findReservation(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		res, e := service.FindReservation(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"reservation": res}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+func postStockMovement(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		var movement variant.PostMovementDTO
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields() //WARNNING return only one unknown field
+
+		err := dec.Decode(&movement)
+
+		if err != nil {
+			payload := serializationErrorHandler(err)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		m, e := service.PostMovement(ID, movement)
+
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusCreated, Message: "Posted Successfully", Data: map[string]interface{}{"movement": m}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findStockMovements variant stock ledger latest first, paged with ?page=&limit=
+func findStockMovements(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+		q := r.URL.Query()
+
+		var errs []entity.ErrorField
+		dto := variant.ListMovementsDTO{}
+		dto.Page = int(queryInt(q, "page", 32, &errs))
+		dto.Limit = int(queryInt(q, "limit", 32, &errs))
+
+		if len(errs) > 0 {
+			payload := &response{StatusCode: http.StatusBadRequest, Message: "Provide valid Query", Errors: errs, Successful: false}
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		movements, e := service.FindMovements(ID, dto)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"movements": movements}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//findVariantStock variant available and reserved stock per location and in total
+func findVariantStock(service variant.UseCase) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		a, e := service.FindStock(ID)
+		if e != nil {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusOK, Data: map[string]interface{}{"stock": a}, Successful: true}
+		w.WriteHeader(payload.StatusCode)
+		json.NewEncoder(w).Encode(payload)
+	})
+}
+
+//finishReservation commit or release a reservation
+func finishReservation(finish func(id entity.ID) *entity.Error, message string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		ID := entity.ID(vars["id"])
+
+		e := finish(ID)
+		if e != nil && e.Kind != entity.DeliveryFailed {
+			payload := errorHandler(e)
+			w.WriteHeader(payload.StatusCode)
+			json.NewEncoder(w).Encode(payload)
+			return
+		}
+
+		payload := &response{StatusCode: http.StatusAccepted, Message: message, Data: map[string]interface{}{"id": ID}, Successful: true}
+		if e != nil {
+			deliveryPending(payload, e)
+		}
 		w.WriteHeader(payload.StatusCode)
 		json.NewEncoder(w).Encode(payload)
 	})
@@ -110,7 +497,39 @@ func deleteVariant(service variant.UseCase) http.Handler {
 
 //MakeVariantHandlers make url handlers
 func MakeVariantHandlers(r *mux.Router, service variant.UseCase) {
-	r.Handle("/v1/variants/create", createVariant(service)).Methods("POST", "OPTIONS").Name("CreateVariant")
-	r.Handle("/v1/variants/{id}/{version}/update", updateVariant(service)).Methods("PATCH", "OPTIONS").Name("UpdateVariant")
-	r.Handle("/v1/variants/{id}/{version}/delete", deleteVariant(service)).Methods("DELETE", "OPTIONS").Name("DeleteVariant")
+	r.Handle("/v1/variants/create", createVariant(service)).Methods("POST").Name("CreateVariant")
+	r.Handle("/v1/variants/generate", generateVariants(service)).Methods("POST").Name("GenerateVariants")
+	r.Handle("/v1/variants/{id}/{version}/update", updateVariant(service)).Methods("PATCH").Name("UpdateVariant")
+	r.Handle("/v1/variants/{id}/{version}/delete", deleteVariant(service)).Methods("DELETE").Name("DeleteVariant")
+	r.Handle("/v1/variants/{id}/{version}/prices", setVariantPrice(service)).Methods("PUT").Name("SetVariantPrice")
+	r.Handle("/v1/variants/{id}/price", findVariantPrice(service)).Methods("GET").Name("GetVariantPrice")
+	r.Handle("/v1/variants/{id}/lowest-price", findVariantLowestPrice(service)).Methods("GET").Name("GetVariantLowestPrice")
+	r.Handle("/v1/variants/{id}/{version}/sale-prices", scheduleVariantSalePrice(service)).Methods("POST").Name("ScheduleVariantSalePrice")
+	r.Handle("/v1/variants/{id}/stock-movements", postStockMovement(service)).Methods("POST").Name("PostStockMovement")
+	r.Handle("/v1/variants/{id}/stock-movements", findStockMovements(service)).Methods("GET").Name("GetStockMovements")
+	r.Handle("/v1/variants/{id}/stock", findVariantStock(service)).Methods("GET").Name("GetVariantStock")
+	r.Handle("/v1/variants/{id}", findVariant(service)).Methods("GET").Name("GetVariant")
+	r.Handle("/v1/barcodes/{barcode}", findVariantByBarcode(service)).Methods("GET").Name("GetVariantByBarcode")
+	r.Handle("/v1/reservations", reserve(service)).Methods("POST").Name("ReserveStock")
+	r.Handle("/v1/reservations/{id}", findReservation(service)).Methods("GET").Name("GetReservation")
+	r.Handle("/v1/reservations/{id}/commit", finishReservation(service.Commit, "Committed Successfully")).Methods("POST").Name("CommitReservation")
+	r.Handle("/v1/reservations/{id}/release", finishReservation(service.Release, "Released Successfully")).Methods("POST").Name("ReleaseReservation")
+	preflight(r,
+		"/v1/variants/create",
+		"/v1/variants/generate",
+		"/v1/variants/{id}/{version}/update",
+		"/v1/variants/{id}/{version}/delete",
+		"/v1/variants/{id}/{version}/prices",
+		"/v1/variants/{id}/price",
+		"/v1/variants/{id}/lowest-price",
+		"/v1/variants/{id}/{version}/sale-prices",
+		"/v1/variants/{id}/stock-movements",
+		"/v1/variants/{id}/stock",
+		"/v1/variants/{id}",
+		"/v1/barcodes/{barcode}",
+		"/v1/reservations",
+		"/v1/reservations/{id}",
+		"/v1/reservations/{id}/commit",
+		"/v1/reservations/{id}/release",
+	)
 }
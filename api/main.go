@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/markus-azer/products-service/config"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -16,8 +17,15 @@ import (
 	kafkaStore "github.com/markus-azer/products-service/lib/kafka"
 	"github.com/markus-azer/products-service/lib/mongodb"
 	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/category"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/location"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/markus-azer/products-service/pkg/pricelist"
 	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/producttype"
 	"github.com/markus-azer/products-service/pkg/variant"
+	"github.com/markus-azer/products-service/pkg/webhook"
 )
 
 func check(err error) {
@@ -40,18 +48,43 @@ func main() {
 	fmt.Printf("Mongo Client Created %v\n", mongoDatastore)
 	r := mux.NewRouter()
 
-	productStoreRepo := product.NewMongoRepository(mongoDatastore.Db)
-	productMsgRepo := product.NewKafkaRepository(client.Producer)
+	eventStoreRepo := eventstore.NewMongoRepository(mongoDatastore.Db)
 
-	variantStoreRepo := variant.NewMongoRepository(mongoDatastore.Db)
-	variantMsgRepo := variant.NewKafkaRepository(client.Producer)
+	outboxStoreRepo := outbox.NewMongoRepository(mongoDatastore.Db)
+	outboxMsgRepo := outbox.NewKafkaRepository(client.Producer, 5*time.Second)
+
+	productStoreRepo := product.NewMongoRepository(mongoDatastore.Db, eventStoreRepo, outboxStoreRepo)
+
+	variantStoreRepo := variant.NewMongoRepository(mongoDatastore.Db, eventStoreRepo, outboxStoreRepo)
+
+	priceListStoreRepo := pricelist.NewMongoRepository(mongoDatastore.Db)
+
+	locationStoreRepo := location.NewMongoRepository(mongoDatastore.Db)
+
+	productTypeStoreRepo := producttype.NewMongoRepository(mongoDatastore.Db)
+
+	webhookStoreRepo := webhook.NewMongoRepository(mongoDatastore.Db)
+	webhookMsgRepo := webhook.NewHTTPRepository(5 * time.Second)
 
 	brandStoreRepo := brand.NewMongoRepository(mongoDatastore.Db)
 	brandMsgRepo := brand.NewKafkaRepository(client.Consumer)
 
-	productService := product.NewService(productMsgRepo, productStoreRepo, brandStoreRepo)
-	variantService := variant.NewService(variantMsgRepo, variantStoreRepo, productStoreRepo)
+	categoryConsumer, err := kafkaStore.NewConsumer("products-categories-consumer")
+	check(err)
+
+	categoryStoreRepo := category.NewMongoRepository(mongoDatastore.Db)
+	categoryMsgRepo := category.NewKafkaRepository(categoryConsumer)
+
+	outboxService := outbox.NewService(outboxStoreRepo, outboxMsgRepo)
+	productService := product.NewService(productStoreRepo, brandStoreRepo, categoryStoreRepo, productTypeStoreRepo, eventStoreRepo, outboxService)
+	webhookService := webhook.NewService(webhookStoreRepo, webhookMsgRepo)
+	variantService := variant.NewService(variantStoreRepo, productStoreRepo, priceListStoreRepo, locationStoreRepo, productTypeStoreRepo, eventStoreRepo, outboxService, webhookService, config.DevConfig.LowStockThreshold, config.DevConfig.SKUTemplate)
+	priceListService := pricelist.NewService(priceListStoreRepo)
+	locationService := location.NewService(locationStoreRepo)
+	productTypeService := producttype.NewService(productTypeStoreRepo)
+
 	brandService := brand.NewService(brandStoreRepo)
+	categoryService := category.NewService(categoryStoreRepo)
 
 	metricService, err := metric.NewPrometheusService()
 	if err != nil {
@@ -72,8 +105,22 @@ func main() {
 
 	// Route Handlers - Endpoints
 	handler.MakeProductHandlers(r, productService)
-	handler.MakeBrandHandlers(brandMsgRepo, brandService)
+	handler.MakeBrandHandlers(brandMsgRepo, brandService, productService)
+	handler.MakeCategoryHandlers(categoryMsgRepo, categoryService)
 	handler.MakeVariantHandlers(r, variantService)
+	handler.MakePriceListHandlers(r, priceListService)
+	handler.MakeLocationHandlers(r, locationService)
+	handler.MakeProductTypeHandlers(r, productTypeService)
+	handler.MakeWebhookHandlers(r, webhookService)
+
+	//Publish the product and variant events stored in the outbox
+	go outboxService.Run(time.Second, 100, make(chan struct{}))
+
+	//Start and end the scheduled variant sale prices
+	go variantService.RunSalePrices(time.Minute, 100, make(chan struct{}))
+
+	//Return the stock of the expired reservations
+	go variantService.RunReservations(10*time.Second, 100, make(chan struct{}))
 
 	log.Fatal(http.ListenAndServe(":8080", r))
 }
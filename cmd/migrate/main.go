@@ -0,0 +1,352 @@
+package main
+
+//migrate convert the prices stored as whole numbers without currency to money, the stock to per location stock
+//and sign the variants attributes
+//
+//Products and variants documents with a numeric price are rewritten in place to
+//{amount: price in minor units, currency: entity.DefaultCurrency}, documents already migrated are left untouched
+//so it can be run again safely. A price of 0 was stored for the unpriced documents, it is unset instead. Stored events are never rewritten, their legacy prices are converted when read.
+//
+//Variants stock, reservations and stock movements recorded before locations are moved to entity.DefaultLocation,
+//documents already holding a location are left untouched.
+//
+//Variants get the canonical signature of their attributes, unique per product. The unique signature index is created
+//first, variants duplicating the attributes of another variant of their product are reported and left without signature
+//to be fixed by hand, they are reported again on every run until fixed.
+//
+//Variants sharing a SKU are reported, the unique SKU index can't be created and the service doesn't start
+//until they are fixed by hand.
+//
+//Variants get the version of their last merchandising edit, their current version as the stock changes made
+//before it was tracked can't be told apart.
+//
+//Products categories stored by name before categories were referenced by id get the id of the category of that name,
+//names shared by several categories and categories matching no category are reported to be fixed by hand.
+//cmd/replay restores the names of the legacy category events, run it again after a replay.
+//
+//Products and variants stored before the event store get a snapshot event of their migrated document, their history
+//starts at the migration and cmd/replay rebuilds them from it. Aggregates already holding events are left untouched.
+//
+//Variants stock ledgers are not migrated here, cmd/replay rebuilds them from the variants events
+//recording the legacy quantity updates as adjustments.
+//
+//	go run ./cmd/migrate
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/markus-azer/products-service/config"
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/variant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func main() {
+	mongoDatastore := mongodb.NewDatastore(config.DevConfig)
+
+	for _, collection := range []string{"products", "variants"} {
+		n, unset, err := migrateMoney(mongoDatastore.Db.Collection(collection))
+		if err != nil {
+			log.Fatalln("Error on migrating", collection, err)
+		}
+
+		log.Printf("%s: %d prices migrated, %d zero prices unset\n", collection, n, unset)
+	}
+
+	n, err := migrateStock(mongoDatastore.Db.Collection("variants"))
+	if err != nil {
+		log.Fatalln("Error on migrating variants stock", err)
+	}
+
+	log.Printf("variants: %d stocks migrated\n", n)
+
+	for _, collection := range []string{"reservations", "stock-movements"} {
+		r, err := mongoDatastore.Db.Collection(collection).UpdateMany(context.Background(), bson.M{"location": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"location": entity.DefaultLocation}})
+		if err != nil {
+			log.Fatalln("Error on migrating", collection, err)
+		}
+
+		log.Printf("%s: %d locations migrated\n", collection, r.ModifiedCount)
+	}
+
+	n, err = migrateSignatures(mongoDatastore.Db.Collection("variants"))
+	if err != nil {
+		log.Fatalln("Error on migrating variants signatures", err)
+	}
+
+	log.Printf("variants: %d signatures migrated\n", n)
+
+	n, err = migrateEdited(mongoDatastore.Db.Collection("variants"))
+	if err != nil {
+		log.Fatalln("Error on migrating variants edited versions", err)
+	}
+
+	log.Printf("variants: %d edited versions migrated\n", n)
+
+	if err := reportDuplicateSKUs(mongoDatastore.Db.Collection("variants")); err != nil {
+		log.Fatalln("Error on checking variants SKUs", err)
+	}
+
+	n, err = migrateCategories(mongoDatastore.Db.Collection("categories"), mongoDatastore.Db.Collection("products"))
+	if err != nil {
+		log.Fatalln("Error on migrating products categories", err)
+	}
+
+	log.Printf("products: %d categories migrated\n", n)
+
+	if err := reportUnknownCategories(mongoDatastore.Db.Collection("products")); err != nil {
+		log.Fatalln("Error on checking products categories", err)
+	}
+
+	//Snapshots are taken last so they hold the migrated documents
+	eventStoreRepo := eventstore.NewMongoRepository(mongoDatastore.Db)
+	at := time.Now()
+
+	n, err = seedEvents(mongoDatastore.Db.Collection("products"), eventStoreRepo, "product", func(cur *mongo.Cursor) (*entity.StoredEvent, error) {
+		p := &entity.Product{}
+		if err := cur.Decode(p); err != nil {
+			return nil, err
+		}
+
+		return product.NewSnapshot(p, at), nil
+	})
+	if err != nil {
+		log.Fatalln("Error on seeding products events", err)
+	}
+
+	log.Printf("products: %d snapshots taken\n", n)
+
+	n, err = seedEvents(mongoDatastore.Db.Collection("variants"), eventStoreRepo, "variant", func(cur *mongo.Cursor) (*entity.StoredEvent, error) {
+		v := &entity.Variant{}
+		if err := cur.Decode(v); err != nil {
+			return nil, err
+		}
+
+		return variant.NewSnapshot(v, at), nil
+	})
+	if err != nil {
+		log.Fatalln("Error on seeding variants events", err)
+	}
+
+	log.Printf("variants: %d snapshots taken\n", n)
+}
+
+func migrateMoney(c *mongo.Collection) (int64, int64, error) {
+	unit := entity.LegacyMoney(1)
+
+	//Money has a min amount of 1, the zero prices are the ones never set
+	unset, err := c.UpdateMany(context.Background(), bson.M{"price": 0}, bson.M{"$unset": bson.M{"price": ""}})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	filter := bson.M{"price": bson.M{"$type": "number"}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"price": bson.M{
+			"amount":   bson.M{"$toLong": bson.M{"$multiply": bson.A{"$price", unit.Amount}}},
+			"currency": unit.Currency,
+		}}}},
+	}
+
+	r, err := c.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return 0, unset.ModifiedCount, err
+	}
+
+	return r.ModifiedCount, unset.ModifiedCount, nil
+}
+
+func migrateStock(c *mongo.Collection) (int64, error) {
+	filter := bson.M{"stock": bson.M{"$exists": false}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"stock": bson.M{
+			string(entity.DefaultLocation): bson.M{
+				"quantity": bson.M{"$ifNull": bson.A{"$quantity", 0}},
+				"reserved": bson.M{"$ifNull": bson.A{"$reserved", 0}},
+			},
+		}}}},
+	}
+
+	r, err := c.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.ModifiedCount, nil
+}
+
+func migrateSignatures(c *mongo.Collection) (int64, error) {
+	//Without the index the duplicates would be signed and the service would fail creating it
+	if _, err := c.Indexes().CreateOne(context.Background(), variant.SignatureIndex()); err != nil {
+		return 0, err
+	}
+
+	cur, err := c.Find(context.Background(), bson.M{"signature": bson.M{"$exists": false}})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.Background())
+
+	var n int64
+	for cur.Next(context.Background()) {
+		v := entity.Variant{}
+		if err := cur.Decode(&v); err != nil {
+			return n, err
+		}
+
+		_, err := c.UpdateOne(context.Background(), bson.M{"_id": v.ID}, bson.M{"$set": bson.M{"signature": entity.AttributesSignature(v.Attributes)}})
+		switch {
+		case mongodb.IsDuplicateKeyError(err):
+			log.Println("Variant", v.ID, "duplicates the attributes of another variant of product", v.Product)
+		case err != nil:
+			return n, err
+		default:
+			n++
+		}
+	}
+
+	return n, cur.Err()
+}
+
+func migrateEdited(c *mongo.Collection) (int64, error) {
+	filter := bson.M{"_E": bson.M{"$exists": false}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"_E": "$_V"}}},
+	}
+
+	r, err := c.UpdateMany(context.Background(), filter, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.ModifiedCount, nil
+}
+
+func reportDuplicateSKUs(c *mongo.Collection) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"sku": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$sku", "variants": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}
+
+	cur, err := c.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.Background())
+
+	for cur.Next(context.Background()) {
+		var duplicate struct {
+			SKU      string      `bson:"_id"`
+			Variants []entity.ID `bson:"variants"`
+		}
+		if err := cur.Decode(&duplicate); err != nil {
+			return err
+		}
+
+		log.Println("SKU", duplicate.SKU, "is used by variants", duplicate.Variants)
+	}
+
+	return cur.Err()
+}
+
+func migrateCategories(categories *mongo.Collection, products *mongo.Collection) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deleted": bson.M{"$ne": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "categories": bson.M{"$push": "$_id"}}}},
+	}
+
+	cur, err := categories.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.Background())
+
+	var n int64
+	for cur.Next(context.Background()) {
+		var named struct {
+			Name       string      `bson:"_id"`
+			Categories []entity.ID `bson:"categories"`
+		}
+		if err := cur.Decode(&named); err != nil {
+			return n, err
+		}
+
+		if len(named.Categories) > 1 {
+			log.Println("Category name", named.Name, "is used by categories", named.Categories, "its products are left untouched")
+			continue
+		}
+
+		r, err := products.UpdateMany(context.Background(), bson.M{"category": named.Name}, bson.M{"$set": bson.M{"category": named.Categories[0]}})
+		if err != nil {
+			return n, err
+		}
+
+		n += r.ModifiedCount
+	}
+
+	return n, cur.Err()
+}
+
+func reportUnknownCategories(c *mongo.Collection) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"category": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$lookup", Value: bson.M{"from": "categories", "localField": "_id", "foreignField": "_id", "as": "categories"}}},
+		{{Key: "$match", Value: bson.M{"categories": bson.M{"$size": 0}}}},
+	}
+
+	cur, err := c.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(context.Background())
+
+	for cur.Next(context.Background()) {
+		var unknown struct {
+			Category string `bson:"_id"`
+			Count    int    `bson:"count"`
+		}
+		if err := cur.Decode(&unknown); err != nil {
+			return err
+		}
+
+		log.Println("Category", unknown.Category, "of", unknown.Count, "products is no known category")
+	}
+
+	return cur.Err()
+}
+
+func seedEvents(c *mongo.Collection, events eventstore.StoreRepository, aggregateType string, snapshot func(cur *mongo.Cursor) (*entity.StoredEvent, error)) (int64, error) {
+	ids, err := events.FindAggregateIDs(aggregateType)
+	if err != nil {
+		return 0, err
+	}
+
+	cur, err := c.Find(context.Background(), bson.M{"_id": bson.M{"$nin": ids}})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(context.Background())
+
+	var n int64
+	for cur.Next(context.Background()) {
+		e, err := snapshot(cur)
+		if err != nil {
+			return n, err
+		}
+
+		if err := events.Append([]*entity.StoredEvent{e}); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, cur.Err()
+}
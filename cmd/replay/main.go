@@ -0,0 +1,106 @@
+package main
+
+//replay rebuild the products, variants and brands read collections
+//
+//products and variants are rebuilt from the event store, or from the products topic with -source kafka,
+//brands are owned by the brands service and are always rebuilt from the brands topic.
+//Every run clears the collections and rebuilds them from scratch so it can be run again safely,
+//with -id only the given aggregate is rebuilt and the rest of the collections are left untouched.
+//A collection holding documents the source has no events for is not cleared, cmd/migrate snapshots the products
+//and variants stored before the event store. The snapshots are not published, replay them from the event store.
+//
+//	go run ./cmd/replay
+//	go run ./cmd/replay -source kafka -collections products,variants
+//	go run ./cmd/replay -collections products -id 3f1b8a9c-0f6e-4c51-9a0e-6ad2f1a9c2b7
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/markus-azer/products-service/config"
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/category"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/location"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/markus-azer/products-service/pkg/pricelist"
+	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/producttype"
+	"github.com/markus-azer/products-service/pkg/variant"
+)
+
+func main() {
+	source := flag.String("source", "events", "products and variants source, events or kafka")
+	collections := flag.String("collections", "products,variants,brands", "comma separated collections to rebuild")
+	id := flag.String("id", "", "rebuild a single aggregate id")
+	flag.Parse()
+
+	if *source != "events" && *source != "kafka" {
+		log.Fatalln("Unknown source", *source)
+	}
+
+	mongoDatastore := mongodb.NewDatastore(config.DevConfig)
+
+	eventStoreRepo := eventstore.NewMongoRepository(mongoDatastore.Db)
+	outboxStoreRepo := outbox.NewMongoRepository(mongoDatastore.Db)
+	productStoreRepo := product.NewMongoRepository(mongoDatastore.Db, eventStoreRepo, outboxStoreRepo)
+	variantStoreRepo := variant.NewMongoRepository(mongoDatastore.Db, eventStoreRepo, outboxStoreRepo)
+	brandStoreRepo := brand.NewMongoRepository(mongoDatastore.Db)
+	categoryStoreRepo := category.NewMongoRepository(mongoDatastore.Db)
+	priceListStoreRepo := pricelist.NewMongoRepository(mongoDatastore.Db)
+	locationStoreRepo := location.NewMongoRepository(mongoDatastore.Db)
+	productTypeStoreRepo := producttype.NewMongoRepository(mongoDatastore.Db)
+
+	//The replay only writes the read collections, it never publishes events
+	r := &replayer{
+		products: product.NewService(productStoreRepo, brandStoreRepo, categoryStoreRepo, productTypeStoreRepo, eventStoreRepo, nil),
+		variants: variant.NewService(variantStoreRepo, productStoreRepo, priceListStoreRepo, locationStoreRepo, productTypeStoreRepo, eventStoreRepo, nil, nil, config.DevConfig.LowStockThreshold, config.DevConfig.SKUTemplate),
+		brands:   brand.NewService(brandStoreRepo),
+		events:   eventStoreRepo,
+		db:       mongoDatastore.Db,
+		id:       *id,
+	}
+
+	selected := make(map[string]bool)
+	for _, c := range strings.Split(*collections, ",") {
+		selected[strings.TrimSpace(c)] = true
+	}
+
+	failed := 0
+
+	if selected["products"] || selected["variants"] {
+		var results []*counts
+		var err error
+
+		if *source == "kafka" {
+			results, err = r.aggregatesFromKafka(selected["products"], selected["variants"])
+		} else {
+			results, err = r.aggregatesFromEventStore(selected["products"], selected["variants"])
+		}
+		if err != nil {
+			log.Fatalln("Error on replaying", err)
+		}
+
+		for _, c := range results {
+			c.report()
+			failed += c.failed
+		}
+	}
+
+	if selected["brands"] {
+		c, err := r.brandsFromKafka()
+		if err != nil {
+			log.Fatalln("Error on replaying brands", err)
+		}
+
+		c.report()
+		failed += c.failed
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
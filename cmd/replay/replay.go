@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	kafkaStore "github.com/markus-azer/products-service/lib/kafka"
+	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/variant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+//progressEvery number of aggregates between two progress reports
+const progressEvery = 100
+
+//replayer rebuild the read collections
+type replayer struct {
+	products product.UseCase
+	variants variant.UseCase
+	brands   brand.UseCase
+	events   eventstore.StoreRepository
+	db       *mongo.Database
+	id       string
+}
+
+//counts outcome of the rebuild of a collection, brands are counted per applied message
+type counts struct {
+	collection string
+	rebuilt    int
+	removed    int
+	skipped    int
+	failed     int
+}
+
+func (c *counts) progress(done int, total int) {
+	if done%progressEvery == 0 || done == total {
+		log.Printf("%s: %d/%d\n", c.collection, done, total)
+	}
+}
+
+func (c *counts) report() {
+	log.Printf("%s: %d rebuilt, %d removed, %d skipped, %d failed\n", c.collection, c.rebuilt, c.removed, c.skipped, c.failed)
+}
+
+//projector rebuild a single aggregate of a collection
+type projector func(id entity.ID, events []*entity.StoredEvent) (bool, *entity.Error)
+
+func (r *replayer) projectProduct(id entity.ID, events []*entity.StoredEvent) (bool, *entity.Error) {
+	p, err := r.products.Project(id, events)
+	return p != nil, err
+}
+
+func (r *replayer) projectVariant(id entity.ID, events []*entity.StoredEvent) (bool, *entity.Error) {
+	v, err := r.variants.Project(id, events)
+	return v != nil, err
+}
+
+//project rebuild the given aggregates, the collection is cleared first unless a single aggregate is replayed
+func (r *replayer) project(c *counts, ids []string, load func(id string) ([]*entity.StoredEvent, error), fn projector, clear func() *entity.Error) (*counts, error) {
+	if r.id == "" {
+		//Documents the source doesn't know would be removed and never rebuilt
+		unknown, err := r.db.Collection(c.collection).CountDocuments(context.Background(), bson.M{"_id": bson.M{"$nin": ids}})
+		if err != nil {
+			return nil, err
+		}
+		if unknown > 0 {
+			return nil, fmt.Errorf("%s holds %d documents without events, run cmd/migrate to snapshot them before replaying", c.collection, unknown)
+		}
+
+		if err := clear(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, id := range ids {
+		events, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+
+		stored, e := fn(entity.ID(id), events)
+		switch {
+		case e != nil:
+			log.Println("Error on rebuilding", c.collection, id, e.Err)
+			c.failed++
+		case stored:
+			c.rebuilt++
+		default:
+			c.removed++
+		}
+
+		c.progress(i+1, len(ids))
+	}
+
+	return c, nil
+}
+
+//aggregatesFromEventStore rebuild products and variants from the event store
+func (r *replayer) aggregatesFromEventStore(products bool, variants bool) ([]*counts, error) {
+	var results []*counts
+
+	collections := []struct {
+		selected      bool
+		collection    string
+		aggregateType string
+		fn            projector
+		clear         func() *entity.Error
+	}{
+		{products, "products", "product", r.projectProduct, r.products.RemoveAll},
+		{variants, "variants", "variant", r.projectVariant, r.variants.RemoveAll},
+	}
+
+	for _, col := range collections {
+		if !col.selected {
+			continue
+		}
+
+		var ids []string
+		if r.id != "" {
+			events, err := r.events.FindByAggregate(r.id)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(events) > 0 && events[0].AggregateType == col.aggregateType {
+				ids = []string{r.id}
+			}
+		} else {
+			var err error
+			ids, err = r.events.FindAggregateIDs(col.aggregateType)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		c, err := r.project(&counts{collection: col.collection}, ids, r.events.FindByAggregate, col.fn, col.clear)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, c)
+	}
+
+	return results, nil
+}
+
+//aggregatesFromKafka rebuild products and variants from the products topic read from offset zero
+func (r *replayer) aggregatesFromKafka(products bool, variants bool) ([]*counts, error) {
+	productEvents := make(map[string][]*entity.StoredEvent)
+	variantEvents := make(map[string][]*entity.StoredEvent)
+
+	read, err := kafkaStore.ReadFromBeginning("products", func(msg *kafka.Message) error {
+		m, err := messageFromKafka(msg)
+		if err != nil {
+			log.Println("Ignored undecodable message", msg.TopicPartition, err)
+			return nil
+		}
+
+		if r.id != "" && m.ID != r.id {
+			return nil
+		}
+
+		if strings.HasPrefix(m.Type, "PRODUCT_VARIANT_") {
+			variantEvents[m.ID] = append(variantEvents[m.ID], entity.NewStoredEvents("variant", []*entity.Message{m})...)
+		} else {
+			productEvents[m.ID] = append(productEvents[m.ID], entity.NewStoredEvents("product", []*entity.Message{m})...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("products topic: %d messages read\n", read)
+
+	load := func(events map[string][]*entity.StoredEvent) func(id string) ([]*entity.StoredEvent, error) {
+		return func(id string) ([]*entity.StoredEvent, error) {
+			return ordered(events[id]), nil
+		}
+	}
+
+	var results []*counts
+
+	if products {
+		c, err := r.project(&counts{collection: "products"}, keys(productEvents), load(productEvents), r.projectProduct, r.products.RemoveAll)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	if variants {
+		c, err := r.project(&counts{collection: "variants"}, keys(variantEvents), load(variantEvents), r.projectVariant, r.variants.RemoveAll)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, c)
+	}
+
+	return results, nil
+}
+
+//brandsFromKafka rebuild brands from the brands topic read from offset zero
+func (r *replayer) brandsFromKafka() (*counts, error) {
+	c := &counts{collection: "brands"}
+
+	var clear func() error = r.brands.RemoveAll
+	if r.id != "" {
+		clear = func() error { return r.brands.RemoveOne(entity.ID(r.id)) }
+	}
+
+	if err := clear(); err != nil {
+		return nil, err
+	}
+
+	read, err := kafkaStore.ReadFromBeginning("brands", func(msg *kafka.Message) error {
+		m, err := messageFromKafka(msg)
+		if err != nil {
+			log.Println("Ignored undecodable message", msg.TopicPartition, err)
+			return nil
+		}
+
+		if r.id != "" && m.ID != r.id {
+			return nil
+		}
+
+		_, err = r.brands.Apply(*m)
+		switch {
+		case err == brand.ErrStaleVersion || err == brand.ErrUnknownMessage:
+			c.skipped++
+		case err != nil:
+			log.Println("Error on rebuilding brands", m.ID, err)
+			c.failed++
+		case m.Type == "BRAND_DELETED":
+			c.removed++
+		default:
+			c.rebuilt++
+		}
+
+		if n := c.rebuilt + c.removed + c.skipped + c.failed; n%progressEvery == 0 {
+			log.Printf("brands: %d messages applied\n", n)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("brands topic: %d messages read\n", read)
+
+	return c, nil
+}
+
+//messageFromKafka decode a message, the aggregate id is the message key
+func messageFromKafka(msg *kafka.Message) (*entity.Message, error) {
+	var m entity.Message
+	if err := json.Unmarshal(msg.Value, &m); err != nil {
+		return nil, err
+	}
+	m.ID = string(msg.Key)
+
+	return &m, nil
+}
+
+//ordered sort events by version and drop the ones delivered more than once
+func ordered(events []*entity.StoredEvent) []*entity.StoredEvent {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Version < events[j].Version })
+
+	result := events[:0]
+	for _, e := range events {
+		if len(result) > 0 && result[len(result)-1].Version == e.Version {
+			continue
+		}
+		result = append(result, e)
+	}
+
+	return result
+}
+
+//keys aggregate ids in a stable order
+func keys(events map[string][]*entity.StoredEvent) []string {
+	ids := make([]string, 0, len(events))
+	for id := range events {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
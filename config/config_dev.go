@@ -5,10 +5,15 @@ package config
 
 //GeneralConfig GeneralConfig
 type GeneralConfig struct {
+	//DatabaseHost a replica set or mongos, the writes run in transactions
 	DatabaseHost string
 	DatabaseName string
 	APIPort      string
+	//LowStockThreshold default variants low stock threshold of the products without one
+	LowStockThreshold int
+	//SKUTemplate template of the SKUs generated for the variants created without one, product types may override it
+	SKUTemplate string
 }
 
 //DevConfig DevConfig
-var DevConfig = GeneralConfig{DatabaseHost: "mongodb://localhost:27017", DatabaseName: "products-service", APIPort: ":8080"}
+var DevConfig = GeneralConfig{DatabaseHost: "mongodb://localhost:27017/?replicaSet=rs0", DatabaseName: "products-service", APIPort: ":8080", LowStockThreshold: 5, SKUTemplate: "{brand}-{product-slug}-{attrs}"}
@@ -16,6 +16,7 @@ require (
 	github.com/sirupsen/logrus v1.7.0
 	github.com/stretchr/testify v1.4.0
 	go.mongodb.org/mongo-driver v1.3.4
+	golang.org/x/text v0.3.2
 	gopkg.in/confluentinc/confluent-kafka-go.v1 v1.4.2
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
 	rsc.io/quote/v3 v3.1.0 // indirect
@@ -2,6 +2,7 @@ package kafkaStore
 
 import (
 	"fmt"
+	"log"
 
 	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
 )
@@ -17,6 +18,10 @@ func NewKafkaClient() (*Client, error) {
 
 	var client *Client
 	p, err := kafka.NewProducer(&kafka.ConfigMap{"bootstrap.servers": "localhost:9095,localhost:9096,localhost:9097"})
+	if err != nil {
+		return nil, err
+	}
+
 	c, err := kafka.NewConsumer(&kafka.ConfigMap{"bootstrap.servers": "localhost:9095,localhost:9096,localhost:9097", "group.id": "products-consumer"})
 	if err != nil {
 		return nil, err
@@ -25,16 +30,17 @@ func NewKafkaClient() (*Client, error) {
 	fmt.Printf("Created Producer %v\n", p)
 	fmt.Printf("Created Consumer %v\n", c)
 
-	// Delivery report handler for produced messages
+	//Delivery reports go to the channel passed to Produce, the repositories wait for them
+	//only reports of messages produced without a channel and the client errors end up here
 	go func() {
 		for e := range p.Events() {
 			switch ev := e.(type) {
 			case *kafka.Message:
 				if ev.TopicPartition.Error != nil {
-					fmt.Printf("Delivery failed: %v\n", ev.TopicPartition)
-				} else {
-					fmt.Printf("Delivered message to %v\n", ev.TopicPartition)
+					log.Println("Delivery failed", ev.TopicPartition)
 				}
+			case kafka.Error:
+				log.Println("Kafka producer error", ev)
 			}
 		}
 	}()
@@ -45,3 +51,9 @@ func NewKafkaClient() (*Client, error) {
 
 	return client, nil
 }
+
+//NewConsumer Function that creates a Kafka Consumer in its own consumer group
+//each topic subscriber needs its own consumer, a subscription replaces the previous one
+func NewConsumer(groupID string) (*kafka.Consumer, error) {
+	return kafka.NewConsumer(&kafka.ConfigMap{"bootstrap.servers": "localhost:9095,localhost:9096,localhost:9097", "group.id": groupID})
+}
@@ -0,0 +1,79 @@
+package kafkaStore
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+const metadataTimeoutMs = 10000
+
+//ReadFromBeginning read every message of a topic from offset zero up to the end offsets found when starting
+//fn is called in the order of each partition, reading stops at the first error of fn
+//it uses its own consumer without consumer group offsets so it doesn't interfere with the running consumers
+func ReadFromBeginning(topic string, fn func(msg *kafka.Message) error) (int, error) {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  "localhost:9095,localhost:9096,localhost:9097",
+		"group.id":           fmt.Sprintf("products-replay-%d", time.Now().UnixNano()),
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+
+	metadata, err := c.GetMetadata(&topic, false, metadataTimeoutMs)
+	if err != nil {
+		return 0, err
+	}
+
+	var partitions []kafka.TopicPartition
+	ends := make(map[int32]kafka.Offset)
+
+	for _, p := range metadata.Topics[topic].Partitions {
+		low, high, err := c.QueryWatermarkOffsets(topic, p.ID, metadataTimeoutMs)
+		if err != nil {
+			return 0, err
+		}
+
+		if high <= low {
+			continue
+		}
+
+		partitions = append(partitions, kafka.TopicPartition{Topic: &topic, Partition: p.ID, Offset: kafka.OffsetBeginning})
+		ends[p.ID] = kafka.Offset(high)
+	}
+
+	if len(partitions) == 0 {
+		return 0, nil
+	}
+
+	if err := c.Assign(partitions); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for len(ends) > 0 {
+		msg, err := c.ReadMessage(-1)
+		if err != nil {
+			return count, err
+		}
+
+		end, ok := ends[msg.TopicPartition.Partition]
+		if !ok {
+			continue
+		}
+
+		if err := fn(msg); err != nil {
+			return count, err
+		}
+		count++
+
+		if msg.TopicPartition.Offset+1 >= end {
+			delete(ends, msg.TopicPartition.Partition)
+		}
+	}
+
+	return count, nil
+}
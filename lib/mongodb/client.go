@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/markus-azer/products-service/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -68,6 +70,15 @@ func connectToMongo(generalConfig config.GeneralConfig) (a *mongo.Database) {
 		log.Fatal("Failed to connect to Mongo db ", generalConfig.DatabaseHost)
 	}
 
+	//Transactions need a replica set or a sharded cluster, a standalone server fails every write
+	var hello bson.M
+	if err := session.Database("admin").RunCommand(ctx, bson.D{primitive.E{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+		log.Fatal("Failed to check Mongo db topology ", err)
+	}
+	if hello["setName"] == nil && hello["msg"] != "isdbgrid" {
+		log.Fatal("Mongo db ", generalConfig.DatabaseHost, " is a standalone server, transactions need a replica set")
+	}
+
 	var DB = session.Database(generalConfig.DatabaseName)
 	fmt.Println(connected, generalConfig.DatabaseName)
 
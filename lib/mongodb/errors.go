@@ -0,0 +1,63 @@
+package mongodb
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const duplicateKeyCode = 11000
+
+//IsDuplicateKeyError check if a write failed on a unique index
+func IsDuplicateKeyError(err error) bool {
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.BulkWriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == duplicateKeyCode
+	}
+
+	return false
+}
+
+//IsDuplicateKeyErrorOn check if a write failed on the unique index named index
+func IsDuplicateKeyErrorOn(err error, index string) bool {
+	var messages []string
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				messages = append(messages, we.Message)
+			}
+		}
+	case mongo.BulkWriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKeyCode {
+				messages = append(messages, we.Message)
+			}
+		}
+	case mongo.CommandError:
+		if e.Code == duplicateKeyCode {
+			messages = append(messages, e.Message)
+		}
+	}
+
+	//E11000 duplicate key error collection: db.variants index: <index> dup key: ...
+	for _, m := range messages {
+		if strings.Contains(m, " index: "+index+" ") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,15 +18,17 @@ type MessagesRepository interface {
 
 //StoreReader brand reader interface
 type storeReader interface {
-	FindOneByID(id entity.ID) (entity.Brand, error)
+	FindOneByID(id entity.ID) (*entity.Brand, error)
 	FindOneByName(name string) (*entity.Brand, error)
 }
 
 //StoreWriter brand writer interface
 type storeWriter interface {
-	Create(b *entity.Brand)
-	UpdateOne(id entity.ID, b *entity.Brand)
-	DeleteOne(id entity.ID)
+	Create(b *entity.Brand) error
+	UpdateOne(id entity.ID, u *entity.UpdateBrand) error
+	DeleteOne(id entity.ID, v entity.Version) error
+	RemoveOne(id entity.ID) error
+	RemoveAll() error
 }
 
 //StoreRepository brand store repository interface
@@ -35,8 +37,24 @@ type StoreRepository interface {
 	storeWriter
 }
 
+//Writer interface
+type writer interface {
+	Create(b *entity.Brand) error
+	UpdateOne(id entity.ID, u *entity.UpdateBrand) (*entity.Brand, error)
+	DeleteOne(id entity.ID, v entity.Version) (*entity.Brand, error)
+	RemoveOne(id entity.ID) error
+	RemoveAll() error
+	Apply(msg entity.Message) (*entity.Brand, error)
+}
+
+//reader interface
+type reader interface {
+	storeReader
+	Before(msg entity.Message) (*entity.Brand, error)
+}
+
 //UseCase use case interface
 type UseCase interface {
-	storeReader
-	storeWriter
+	reader
+	writer
 }
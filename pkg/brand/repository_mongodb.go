@@ -2,14 +2,37 @@ package brand
 
 import (
 	"context"
-	"log"
 
+	"github.com/markus-azer/products-service/lib/mongodb"
 	"github.com/markus-azer/products-service/pkg/entity"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+//Brands are a replica of the brands service, kafka may deliver their events duplicated or out of order.
+//Every write is conditioned on the stored version being older than the event version,
+//deleted brands are kept as tombstones so a late create or update can't resurrect them.
+
+//olderThan filter matching the brand only while its stored version is older than v,
+//the upsert of a brand stored at v or newer fails on the duplicate _id
+func olderThan(id entity.ID, v entity.Version) bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: bson.M{"$lt": v}}}
+}
+
+//tombstone update marking a brand as deleted at version v
+func tombstone(v entity.Version) bson.D {
+	return bson.D{primitive.E{Key: "$set", Value: bson.M{"_V": v, "deleted": true}}}
+}
+
+//notDeleted filter skipping the tombstones of deleted brands
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted"] = bson.M{"$ne": true}
+
+	return filter
+}
+
 //MongoRepository mongodb repo
 type MongoRepository struct {
 	db *mongo.Database
@@ -23,23 +46,26 @@ func NewMongoRepository(db *mongo.Database) StoreRepository {
 }
 
 //FindOneByID find brand by Id
-func (r *MongoRepository) FindOneByID(id entity.ID) (entity.Brand, error) {
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Brand, error) {
 	result := entity.Brand{}
 	coll := r.db.Collection("brands")
-	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+	err := coll.FindOne(context.TODO(), notDeleted(bson.M{"_id": id})).Decode(&result)
 
-	if err != nil {
-		return result, err
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
 	}
-
-	return result, nil
 }
 
 //FindOneByName find brand by Name
 func (r *MongoRepository) FindOneByName(name string) (*entity.Brand, error) {
 	result := entity.Brand{}
 	coll := r.db.Collection("brands")
-	err := coll.FindOne(context.TODO(), bson.M{"name": name}).Decode(&result)
+	err := coll.FindOne(context.TODO(), notDeleted(bson.M{"name": name})).Decode(&result)
 
 	switch err {
 	case nil:
@@ -51,29 +77,67 @@ func (r *MongoRepository) FindOneByName(name string) (*entity.Brand, error) {
 	}
 }
 
-//Create create new Brand
-func (r *MongoRepository) Create(e *entity.Brand) {
+//Create create new Brand, returns ErrStaleVersion if the brand is already known
+func (r *MongoRepository) Create(e *entity.Brand) error {
 	coll := r.db.Collection("brands")
+
 	_, err := coll.InsertOne(context.TODO(), e)
-	if err != nil {
-		log.Println("Error on creating Brand", err)
+	if mongodb.IsDuplicateKeyError(err) {
+		return ErrStaleVersion
 	}
+
+	return err
 }
 
-//UpdateOne update an existing brand
-func (r *MongoRepository) UpdateOne(id entity.ID, e *entity.Brand) {
+//UpdateOne set the fields carried by an update at its version, returns ErrStaleVersion if a newer version is stored
+func (r *MongoRepository) UpdateOne(id entity.ID, u *entity.UpdateBrand) error {
 	coll := r.db.Collection("brands")
-	_, err := coll.UpdateOne(context.TODO(), bson.D{primitive.E{Key: "_id", Value: id}}, bson.D{primitive.E{Key: "$set", Value: e}})
-	if err != nil {
-		log.Println("Error on updating Brand", err)
+
+	//An update received before the create inserts the brand, the create is then ignored as stale
+	_, err := coll.UpdateOne(
+		context.TODO(),
+		olderThan(id, u.Version),
+		bson.D{primitive.E{Key: "$set", Value: u}},
+		options.Update().SetUpsert(true),
+	)
+	if mongodb.IsDuplicateKeyError(err) {
+		return ErrStaleVersion
 	}
+
+	return err
 }
 
-//DeleteOne update an existing Brand
-func (r *MongoRepository) DeleteOne(id entity.ID) {
+//DeleteOne mark a brand as deleted at the given version, returns ErrStaleVersion if a newer version is stored
+func (r *MongoRepository) DeleteOne(id entity.ID, v entity.Version) error {
 	coll := r.db.Collection("brands")
-	_, err := coll.DeleteOne(context.TODO(), bson.D{primitive.E{Key: "_id", Value: id}})
-	if err != nil {
-		log.Println("Error on deleting Brand", err)
+
+	_, err := coll.UpdateOne(
+		context.TODO(),
+		olderThan(id, v),
+		tombstone(v),
+		options.Update().SetUpsert(true),
+	)
+	if mongodb.IsDuplicateKeyError(err) {
+		return ErrStaleVersion
 	}
+
+	return err
+}
+
+//RemoveOne remove a brand and its tombstone
+func (r *MongoRepository) RemoveOne(id entity.ID) error {
+	coll := r.db.Collection("brands")
+
+	_, err := coll.DeleteOne(context.TODO(), bson.M{"_id": id})
+
+	return err
+}
+
+//RemoveAll remove all the brands and tombstones
+func (r *MongoRepository) RemoveAll() error {
+	coll := r.db.Collection("brands")
+
+	_, err := coll.DeleteMany(context.TODO(), bson.M{})
+
+	return err
 }
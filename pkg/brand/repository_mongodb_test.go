@@ -0,0 +1,39 @@
+package brand
+
+import (
+	"testing"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOlderThan(t *testing.T) {
+	ID := entity.NewID()
+
+	assert.Equal(t, bson.D{
+		primitive.E{Key: "_id", Value: ID},
+		primitive.E{Key: "_V", Value: bson.M{"$lt": entity.Version(3)}},
+	}, olderThan(ID, 3))
+}
+
+func TestTombstone(t *testing.T) {
+	assert.Equal(t, bson.D{primitive.E{Key: "$set", Value: bson.M{"_V": entity.Version(4), "deleted": true}}}, tombstone(4))
+
+	// Lookups skip the tombstones
+	assert.Equal(t, bson.M{"name": "Acme", "deleted": bson.M{"$ne": true}}, notDeleted(bson.M{"name": "Acme"}))
+}
+
+func TestUpdateSetsCarriedFields(t *testing.T) {
+	u := UpdateFromMessage(entity.Message{ID: string(entity.NewID()), Type: "BRAND_UPDATED", Version: 3, Payload: map[string]interface{}{"Name": "Acme", "Description": ""}})
+
+	raw, err := bson.Marshal(u)
+	assert.Nil(t, err)
+
+	set := bson.M{}
+	assert.Nil(t, bson.Unmarshal(raw, &set))
+
+	// createdAt and slug aren't in the message, an empty description is
+	assert.Equal(t, bson.M{"_V": int32(3), "name": "Acme", "description": ""}, set)
+}
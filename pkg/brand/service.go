@@ -1,6 +1,17 @@
 package brand
 
-import "github.com/markus-azer/products-service/pkg/entity"
+import (
+	"errors"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//ErrStaleVersion the event is a duplicate or older than the stored brand
+var ErrStaleVersion = errors.New("Stale brand version")
+
+//ErrUnknownMessage the message type has no handler
+var ErrUnknownMessage = errors.New("Unknown brand message")
 
 //Service service interface
 type Service struct {
@@ -14,27 +25,145 @@ func NewService(r StoreRepository) *Service {
 	}
 }
 
-//FindOneByID new product
-func (s *Service) FindOneByID(id entity.ID) (entity.Brand, error) {
+//FindOneByID brand
+func (s *Service) FindOneByID(id entity.ID) (*entity.Brand, error) {
 	return s.repo.FindOneByID(id)
 }
 
-//FindOneByName new product
+//FindOneByName brand
 func (s *Service) FindOneByName(name string) (*entity.Brand, error) {
 	return s.repo.FindOneByName(name)
 }
 
-//Create new product
-func (s *Service) Create(b *entity.Brand) {
-	s.repo.Create(b)
+//Create new brand
+func (s *Service) Create(b *entity.Brand) error {
+	return s.repo.Create(b)
+}
+
+//UpdateOne brand, returns the brand as it was before the update or nil if it was never received
+func (s *Service) UpdateOne(id entity.ID, u *entity.UpdateBrand) (*entity.Brand, error) {
+	b, err := s.repo.FindOneByID(id)
+	if err != nil && err != entity.ErrNotFound {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateOne(id, u); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+//DeleteOne brand, returns the deleted brand or nil if it was never received
+func (s *Service) DeleteOne(id entity.ID, v entity.Version) (*entity.Brand, error) {
+	b, err := s.repo.FindOneByID(id)
+	if err != nil && err != entity.ErrNotFound {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteOne(id, v); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+//RemoveOne remove a brand whatever its version, used to rebuild it from its messages
+func (s *Service) RemoveOne(id entity.ID) error {
+	return s.repo.RemoveOne(id)
+}
+
+//RemoveAll remove all the brands, used to rebuild them from the brands topic
+func (s *Service) RemoveAll() error {
+	return s.repo.RemoveAll()
+}
+
+//Before the brand a BRAND_UPDATED or BRAND_DELETED message applies to, nil if it was never received or for other messages
+//returns ErrStaleVersion if the brand already has the message version, nothing is written
+func (s *Service) Before(msg entity.Message) (*entity.Brand, error) {
+	if msg.Type != "BRAND_UPDATED" && msg.Type != "BRAND_DELETED" {
+		return nil, nil
+	}
+
+	b, err := s.repo.FindOneByID(entity.ID(msg.ID))
+	switch {
+	case err == entity.ErrNotFound:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case b.Version >= msg.Version:
+		return nil, ErrStaleVersion
+	}
+
+	return b, nil
+}
+
+//Apply apply a brands topic message, returns the brand as it was before a BRAND_UPDATED or BRAND_DELETED
+func (s *Service) Apply(msg entity.Message) (*entity.Brand, error) {
+	switch msg.Type {
+	case "BRAND_CREATED":
+		return nil, s.Create(FromMessage(msg))
+	case "BRAND_UPDATED":
+		return s.UpdateOne(entity.ID(msg.ID), UpdateFromMessage(msg))
+	case "BRAND_DELETED":
+		return s.DeleteOne(entity.ID(msg.ID), msg.Version)
+	default:
+		return nil, ErrUnknownMessage
+	}
+}
+
+//FromMessage map a brands topic message to a brand
+func FromMessage(msg entity.Message) *entity.Brand {
+	b := &entity.Brand{
+		ID:      entity.ID(msg.ID),
+		Version: msg.Version,
+	}
+
+	for key, item := range msg.Payload {
+		value, _ := item.(string)
+
+		switch key {
+		case "CreatedAt":
+			b.CreatedAt = parseCreatedAt(value)
+		case "Description":
+			b.Description = value
+		case "Name":
+			b.Name = value
+		case "Slug":
+			b.Slug = value
+		}
+	}
+
+	return b
 }
 
-//UpdateOne brand
-func (s *Service) UpdateOne(id entity.ID, b *entity.Brand) {
-	s.repo.UpdateOne(id, b)
+//UpdateFromMessage map a BRAND_UPDATED message to the brand fields it carries
+func UpdateFromMessage(msg entity.Message) *entity.UpdateBrand {
+	u := &entity.UpdateBrand{Version: msg.Version}
+
+	for key, item := range msg.Payload {
+		value, _ := item.(string)
+
+		switch key {
+		case "CreatedAt":
+			t := parseCreatedAt(value)
+			u.CreatedAt = &t
+		case "Description":
+			u.Description = &value
+		case "Name":
+			u.Name = &value
+		case "Slug":
+			u.Slug = &value
+		}
+	}
+
+	return u
 }
 
-//DeleteOne brand
-func (s *Service) DeleteOne(id entity.ID) {
-	s.repo.DeleteOne(id)
+//parseCreatedAt creation time of a brand as formatted by the brands service
+func parseCreatedAt(value string) time.Time {
+	layout := "2006-01-02T15:04:05.000Z"
+	t, _ := time.Parse(layout, value)
+
+	return t
 }
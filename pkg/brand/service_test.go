@@ -0,0 +1,114 @@
+package brand_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := brand.NewMockStoreRepository(controller)
+	service := brand.NewService(repo)
+
+	ID := entity.NewID()
+	stored := &entity.Brand{ID: ID, Version: 2, Name: "Acme", Description: "Tools"}
+	name := "Acme Tools"
+
+	// Only the name is carried, the other fields are left as stored
+	repo.EXPECT().FindOneByID(ID).Return(stored, nil)
+	repo.EXPECT().UpdateOne(ID, &entity.UpdateBrand{Version: 3, Name: &name}).Return(nil)
+
+	b, err := service.Apply(entity.Message{ID: string(ID), Type: "BRAND_UPDATED", Version: 3, Payload: map[string]interface{}{"Name": name}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, stored, b)
+
+	// A duplicate or older update is stale
+	repo.EXPECT().FindOneByID(ID).Return(&entity.Brand{ID: ID, Version: 3, Name: name}, nil)
+	repo.EXPECT().UpdateOne(ID, gomock.Any()).Return(brand.ErrStaleVersion)
+
+	b, err = service.Apply(entity.Message{ID: string(ID), Type: "BRAND_UPDATED", Version: 3, Payload: map[string]interface{}{"Name": name}})
+
+	assert.Equal(t, brand.ErrStaleVersion, err)
+	assert.Nil(t, b)
+}
+
+func TestApplyDelete(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := brand.NewMockStoreRepository(controller)
+	service := brand.NewService(repo)
+
+	ID := entity.NewID()
+	stored := &entity.Brand{ID: ID, Version: 2, Name: "Acme"}
+
+	repo.EXPECT().FindOneByID(ID).Return(stored, nil)
+	repo.EXPECT().DeleteOne(ID, entity.Version(3)).Return(nil)
+
+	b, err := service.Apply(entity.Message{ID: string(ID), Type: "BRAND_DELETED", Version: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, stored, b)
+
+	// A delete received before the create leaves a tombstone, there's no brand to remove from the products
+	repo.EXPECT().FindOneByID(ID).Return(nil, entity.ErrNotFound)
+	repo.EXPECT().DeleteOne(ID, entity.Version(3)).Return(nil)
+
+	b, err = service.Apply(entity.Message{ID: string(ID), Type: "BRAND_DELETED", Version: 3})
+
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	// The create then fails on the tombstone
+	repo.EXPECT().Create(gomock.Any()).Return(brand.ErrStaleVersion)
+
+	_, err = service.Apply(entity.Message{ID: string(ID), Type: "BRAND_CREATED", Version: 1, Payload: map[string]interface{}{"Name": "Acme"}})
+
+	assert.Equal(t, brand.ErrStaleVersion, err)
+}
+
+func TestBefore(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := brand.NewMockStoreRepository(controller)
+	service := brand.NewService(repo)
+
+	ID := entity.NewID()
+	stored := &entity.Brand{ID: ID, Version: 2, Name: "Acme"}
+
+	// Nothing is written, the products are updated first
+	repo.EXPECT().FindOneByID(ID).Return(stored, nil)
+
+	b, err := service.Before(entity.Message{ID: string(ID), Type: "BRAND_UPDATED", Version: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, stored, b)
+
+	// An already applied message doesn't rename the products back
+	repo.EXPECT().FindOneByID(ID).Return(stored, nil)
+
+	b, err = service.Before(entity.Message{ID: string(ID), Type: "BRAND_DELETED", Version: 2})
+
+	assert.Equal(t, brand.ErrStaleVersion, err)
+	assert.Nil(t, b)
+
+	repo.EXPECT().FindOneByID(ID).Return(nil, entity.ErrNotFound)
+
+	b, err = service.Before(entity.Message{ID: string(ID), Type: "BRAND_UPDATED", Version: 3})
+
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	b, err = service.Before(entity.Message{ID: string(ID), Type: "BRAND_CREATED", Version: 1})
+
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+}
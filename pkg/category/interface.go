@@ -0,0 +1,56 @@
+//go:generate mockgen -source interface.go -destination category_mock.go -package category
+
+package category
+
+import (
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//messagesReader category reader interface
+type messagesReader interface {
+	GetMessages() <-chan entity.Message
+}
+
+//MessagesRepository repository interface
+type MessagesRepository interface {
+	messagesReader
+}
+
+//StoreReader category reader interface
+type storeReader interface {
+	FindOneByID(id entity.ID) (*entity.Category, error)
+	FindOneByName(name string) (*entity.Category, error)
+	FindDescendants(path string) ([]*entity.Category, error)
+}
+
+//StoreWriter category writer interface
+type storeWriter interface {
+	Create(c *entity.Category) error
+	UpdateOne(id entity.ID, u *entity.UpdateCategory, from string) error
+	DeleteTree(id entity.ID, path string, v entity.Version) error
+}
+
+//StoreRepository category store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//Reader interface
+type reader interface {
+	FindOneByID(id entity.ID) (*entity.Category, error)
+	FindOneByName(name string) (*entity.Category, error)
+}
+
+//Writer interface
+type writer interface {
+	Create(c *entity.Category) error
+	UpdateOne(id entity.ID, u *entity.UpdateCategory) error
+	DeleteOne(id entity.ID, v entity.Version) error
+}
+
+//UseCase use case interface
+type UseCase interface {
+	reader
+	writer
+}
@@ -0,0 +1,46 @@
+package category
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+//KafkaRepository kafka repo
+type KafkaRepository struct {
+	consumer *kafka.Consumer
+}
+
+//NewKafkaRepository create new repository
+func NewKafkaRepository(c *kafka.Consumer) MessagesRepository {
+	return &KafkaRepository{
+		consumer: c,
+	}
+}
+
+//GetMessages pull messages from kafka categories topic
+func (r *KafkaRepository) GetMessages() <-chan entity.Message {
+	r.consumer.SubscribeTopics([]string{"categories"}, nil)
+
+	c := make(chan entity.Message)
+
+	go func() {
+		for {
+			msg, err := r.consumer.ReadMessage(-1)
+			if err == nil {
+				var m entity.Message
+				json.Unmarshal(msg.Value, &m)
+				m.ID = string(msg.Key)
+
+				c <- m
+			} else {
+				// The client will automatically try to recover from all errors.
+				fmt.Printf("Consumer error: %v (%v)\n", err, msg)
+			}
+		}
+	}()
+
+	return c
+}
@@ -0,0 +1,206 @@
+package category
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//Categories are a replica of the categories service, kafka may deliver their events duplicated or out of order.
+//Every write is conditioned on the stored version being older than the event version,
+//deleted categories are kept as tombstones so a late create can't resurrect them.
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db *mongo.Database
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db: db,
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes indexes supporting lookups by name and subtree queries by path prefix
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("categories")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "name", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "path", Value: 1}}},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating categories indexes", err)
+	}
+}
+
+//olderThan filter matching the category only while its stored version is older than v
+func olderThan(id entity.ID, v entity.Version) bson.D {
+	return bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: bson.M{"$lt": v}}, primitive.E{Key: "deleted", Value: bson.M{"$ne": true}}}
+}
+
+//notDeleted filter skipping the tombstones of deleted categories
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted"] = bson.M{"$ne": true}
+
+	return filter
+}
+
+//subtree filter matching the categories under the path, the category of the path excluded
+func subtree(path string) bson.M {
+	return bson.M{"path": bson.M{"$regex": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(path)}, "$ne": path}}
+}
+
+//setFields update setting the fields carried by u, a move to the root unsets the parent
+func setFields(u *entity.UpdateCategory) bson.D {
+	if u.Parent == nil || *u.Parent != "" {
+		return bson.D{primitive.E{Key: "$set", Value: u}}
+	}
+
+	set := *u
+	set.Parent = nil
+
+	return bson.D{primitive.E{Key: "$set", Value: &set}, primitive.E{Key: "$unset", Value: bson.M{"parent": ""}}}
+}
+
+//movePaths update replacing the from prefix of the paths with to
+func movePaths(from string, to string) mongo.Pipeline {
+	n := utf8.RuneCountInString(from)
+	rest := bson.M{"$substrCP": bson.A{"$path", n, bson.M{"$subtract": bson.A{bson.M{"$strLenCP": "$path"}, n}}}}
+
+	return mongo.Pipeline{bson.D{primitive.E{Key: "$set", Value: bson.M{"path": bson.M{"$concat": bson.A{to, rest}}}}}}
+}
+
+//FindOneByID find category by Id
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Category, error) {
+	result := entity.Category{}
+	coll := r.db.Collection("categories")
+	err := coll.FindOne(context.TODO(), notDeleted(bson.M{"_id": id})).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindOneByName find category by Name
+func (r *MongoRepository) FindOneByName(name string) (*entity.Category, error) {
+	result := entity.Category{}
+	coll := r.db.Collection("categories")
+	err := coll.FindOne(context.TODO(), notDeleted(bson.M{"name": name})).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindDescendants find all the categories under the given path, sorted from the top of the subtree
+func (r *MongoRepository) FindDescendants(path string) ([]*entity.Category, error) {
+	coll := r.db.Collection("categories")
+
+	cur, err := coll.Find(context.TODO(), notDeleted(subtree(path)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	categories := []*entity.Category{}
+	if err := cur.All(context.TODO(), &categories); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+//Create create new Category, returns ErrStaleVersion if the category is already known
+func (r *MongoRepository) Create(c *entity.Category) error {
+	coll := r.db.Collection("categories")
+
+	_, err := coll.InsertOne(context.TODO(), c)
+	if mongodb.IsDuplicateKeyError(err) {
+		return ErrStaleVersion
+	}
+
+	return err
+}
+
+//UpdateOne set the fields carried by an update at its version, returns ErrStaleVersion if a newer version is stored.
+//An update carrying a new path moves the subtree, the paths of the descendants under from are rewritten in the same transaction
+func (r *MongoRepository) UpdateOne(id entity.ID, u *entity.UpdateCategory, from string) error {
+	return r.withTransaction(func(ctx mongo.SessionContext) error {
+		coll := r.db.Collection("categories")
+
+		result, err := coll.UpdateOne(ctx, olderThan(id, u.Version), setFields(u))
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount == 0 {
+			return ErrStaleVersion
+		}
+
+		if u.Path == nil || *u.Path == from {
+			return nil
+		}
+
+		_, err = coll.UpdateMany(ctx, subtree(from), movePaths(from, *u.Path))
+
+		return err
+	})
+}
+
+//DeleteTree mark a category and all its descendants as deleted, returns ErrStaleVersion if a newer version is stored
+func (r *MongoRepository) DeleteTree(id entity.ID, path string, v entity.Version) error {
+	return r.withTransaction(func(ctx mongo.SessionContext) error {
+		coll := r.db.Collection("categories")
+
+		result, err := coll.UpdateOne(ctx, olderThan(id, v), bson.D{primitive.E{Key: "$set", Value: bson.M{"_V": v, "deleted": true}}})
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount == 0 {
+			return ErrStaleVersion
+		}
+
+		_, err = coll.UpdateMany(ctx, subtree(path), bson.D{primitive.E{Key: "$set", Value: bson.M{"deleted": true}}})
+
+		return err
+	})
+}
+
+//withTransaction run fn in a transaction
+func (r *MongoRepository) withTransaction(fn func(ctx mongo.SessionContext) error) error {
+	session, err := r.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.TODO())
+
+	_, err = session.WithTransaction(context.TODO(), func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+
+	return err
+}
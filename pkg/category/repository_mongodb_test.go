@@ -0,0 +1,55 @@
+package category
+
+import (
+	"testing"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestOlderThan(t *testing.T) {
+	assert.Equal(t, bson.D{
+		primitive.E{Key: "_id", Value: entity.ID("shoes")},
+		primitive.E{Key: "_V", Value: bson.M{"$lt": entity.Version(3)}},
+		primitive.E{Key: "deleted", Value: bson.M{"$ne": true}},
+	}, olderThan("shoes", 3))
+}
+
+func TestSetFields(t *testing.T) {
+	name := "Shoes"
+	parent := entity.ID("women")
+	path := ",women,shoes,"
+
+	u := &entity.UpdateCategory{Version: 3, Name: &name, Parent: &parent, Path: &path}
+
+	assert.Equal(t, bson.M{"_V": int32(3), "name": "Shoes", "parent": "women", "path": ",women,shoes,"}, marshal(t, setFields(u)[0].Value))
+
+	// A move to the root unsets the parent
+	root := entity.ID("")
+	path = ",shoes,"
+	u = &entity.UpdateCategory{Version: 3, Parent: &root, Path: &path}
+	update := setFields(u)
+
+	assert.Equal(t, bson.M{"_V": int32(3), "path": ",shoes,"}, marshal(t, update[0].Value))
+	assert.Equal(t, primitive.E{Key: "$unset", Value: bson.M{"parent": ""}}, update[1])
+}
+
+func TestMovePaths(t *testing.T) {
+	rest := bson.M{"$substrCP": bson.A{"$path", 11, bson.M{"$subtract": bson.A{bson.M{"$strLenCP": "$path"}, 11}}}}
+
+	assert.Equal(t, mongo.Pipeline{bson.D{primitive.E{Key: "$set", Value: bson.M{"path": bson.M{"$concat": bson.A{",women,shoes,", rest}}}}}}, movePaths(",men,shoes,", ",women,shoes,"))
+}
+
+//marshal decode the document the driver sends for v
+func marshal(t *testing.T, v interface{}) bson.M {
+	raw, err := bson.Marshal(v)
+	assert.Nil(t, err)
+
+	m := bson.M{}
+	assert.Nil(t, bson.Unmarshal(raw, &m))
+
+	return m
+}
@@ -0,0 +1,163 @@
+package category
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//ErrCyclicParent category can't be moved under one of its descendants
+var ErrCyclicParent = errors.New("Category can't be a descendant of itself")
+
+//ErrStaleVersion the event is a duplicate or older than the stored category
+var ErrStaleVersion = errors.New("Stale category version")
+
+//Service service interface
+type Service struct {
+	repo StoreRepository
+}
+
+//NewService create new service
+func NewService(r StoreRepository) *Service {
+	return &Service{
+		repo: r,
+	}
+}
+
+//FindOneByID category
+func (s *Service) FindOneByID(id entity.ID) (*entity.Category, error) {
+	return s.repo.FindOneByID(id)
+}
+
+//FindOneByName category
+func (s *Service) FindOneByName(name string) (*entity.Category, error) {
+	return s.repo.FindOneByName(name)
+}
+
+//parentPath materialized path of the parent category, "" for root categories
+func (s *Service) parentPath(parent entity.ID) (string, error) {
+	if parent == "" {
+		return "", nil
+	}
+
+	p, err := s.repo.FindOneByID(parent)
+	if err != nil {
+		return "", err
+	}
+
+	return p.Path, nil
+}
+
+//Create new category under its parent
+func (s *Service) Create(c *entity.Category) error {
+	path, err := s.parentPath(c.Parent)
+	if err != nil {
+		return err
+	}
+
+	c.Path = entity.CategoryPath(path, c.ID)
+
+	return s.repo.Create(c)
+}
+
+//UpdateOne category, moves its subtree if the update carries a new parent
+func (s *Service) UpdateOne(id entity.ID, u *entity.UpdateCategory) error {
+	existing, err := s.repo.FindOneByID(id)
+	if err != nil {
+		return err
+	}
+
+	if existing.Version >= u.Version {
+		return ErrStaleVersion
+	}
+
+	if u.Parent != nil && *u.Parent != existing.Parent {
+		path, err := s.parentPath(*u.Parent)
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(path, existing.Path) {
+			return ErrCyclicParent
+		}
+
+		p := entity.CategoryPath(path, id)
+		u.Path = &p
+	}
+
+	return s.repo.UpdateOne(id, u, existing.Path)
+}
+
+//DeleteOne category with its subtree
+func (s *Service) DeleteOne(id entity.ID, v entity.Version) error {
+	c, err := s.repo.FindOneByID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteTree(id, c.Path, v)
+}
+
+//FromMessage map a categories topic message to a category
+func FromMessage(msg entity.Message) *entity.Category {
+	c := &entity.Category{
+		ID:      entity.ID(msg.ID),
+		Version: msg.Version,
+	}
+
+	for key, item := range msg.Payload {
+		value, _ := item.(string)
+
+		switch key {
+		case "CreatedAt":
+			c.CreatedAt = parseCreatedAt(value)
+		case "Description":
+			c.Description = value
+		case "Name":
+			c.Name = value
+		case "Slug":
+			c.Slug = value
+		case "Parent":
+			c.Parent = entity.ID(value)
+		}
+	}
+
+	return c
+}
+
+//UpdateFromMessage map a CATEGORY_UPDATED message to the category fields it carries
+func UpdateFromMessage(msg entity.Message) *entity.UpdateCategory {
+	u := &entity.UpdateCategory{Version: msg.Version}
+
+	for key, item := range msg.Payload {
+		value, _ := item.(string)
+
+		switch key {
+		case "CreatedAt":
+			t := parseCreatedAt(value)
+			u.CreatedAt = &t
+		case "Description":
+			u.Description = &value
+		case "Name":
+			u.Name = &value
+		case "Slug":
+			u.Slug = &value
+		case "Parent":
+			//A null or empty parent moves the category to the root
+			parent := entity.ID(value)
+			u.Parent = &parent
+		}
+	}
+
+	return u
+}
+
+//parseCreatedAt creation time of a category as formatted by the categories service
+func parseCreatedAt(value string) time.Time {
+	layout := "2006-01-02T15:04:05.000Z"
+	t, _ := time.Parse(layout, value)
+
+	return t
+}
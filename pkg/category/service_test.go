@@ -0,0 +1,99 @@
+package category_test
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/markus-azer/products-service/pkg/category"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateOneMovesSubtree(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := category.NewMockStoreRepository(controller)
+	service := category.NewService(repo)
+
+	shoes := &entity.Category{ID: "shoes", Version: 2, Parent: "men", Path: ",men,shoes,"}
+	women := &entity.Category{ID: "women", Path: ",women,"}
+	boots := &entity.Category{ID: "boots", Parent: "shoes", Path: ",men,shoes,boots,"}
+	name := "Shoes"
+	parent := entity.ID("women")
+
+	repo.EXPECT().FindOneByID(entity.ID("shoes")).Return(shoes, nil)
+	repo.EXPECT().FindOneByID(entity.ID("women")).Return(women, nil)
+	repo.EXPECT().UpdateOne(entity.ID("shoes"), gomock.Any(), ",men,shoes,").DoAndReturn(func(id entity.ID, u *entity.UpdateCategory, from string) error {
+		assert.Equal(t, ",women,shoes,", *u.Path)
+		return nil
+	})
+
+	err := service.UpdateOne("shoes", &entity.UpdateCategory{Version: 3, Name: &name, Parent: &parent})
+
+	assert.Nil(t, err)
+
+	// A category can't be moved under its own subtree
+	parent = "boots"
+	repo.EXPECT().FindOneByID(entity.ID("shoes")).Return(shoes, nil)
+	repo.EXPECT().FindOneByID(entity.ID("boots")).Return(boots, nil)
+
+	err = service.UpdateOne("shoes", &entity.UpdateCategory{Version: 3, Name: &name, Parent: &parent})
+
+	assert.Equal(t, category.ErrCyclicParent, err)
+}
+
+func TestUpdateOneKeepsParent(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	repo := category.NewMockStoreRepository(controller)
+	service := category.NewService(repo)
+
+	shoes := &entity.Category{ID: "shoes", Version: 2, Parent: "men", Path: ",men,shoes,"}
+	name := "Shoes"
+	root := entity.ID("")
+
+	// An update without a parent only sets its fields
+	repo.EXPECT().FindOneByID(entity.ID("shoes")).Return(shoes, nil)
+	repo.EXPECT().UpdateOne(entity.ID("shoes"), &entity.UpdateCategory{Version: 3, Name: &name}, ",men,shoes,").Return(nil)
+
+	err := service.UpdateOne("shoes", &entity.UpdateCategory{Version: 3, Name: &name})
+
+	assert.Nil(t, err)
+
+	// An empty parent moves the category to the root
+	repo.EXPECT().FindOneByID(entity.ID("shoes")).Return(shoes, nil)
+	repo.EXPECT().UpdateOne(entity.ID("shoes"), gomock.Any(), ",men,shoes,").DoAndReturn(func(id entity.ID, u *entity.UpdateCategory, from string) error {
+		assert.Equal(t, ",shoes,", *u.Path)
+		return nil
+	})
+
+	err = service.UpdateOne("shoes", &entity.UpdateCategory{Version: 3, Parent: &root})
+
+	assert.Nil(t, err)
+
+	// A duplicate or older update is stale
+	repo.EXPECT().FindOneByID(entity.ID("shoes")).Return(shoes, nil)
+
+	err = service.UpdateOne("shoes", &entity.UpdateCategory{Version: 2, Name: &name})
+
+	assert.Equal(t, category.ErrStaleVersion, err)
+}
+
+func TestUpdateFromMessage(t *testing.T) {
+	ID := entity.NewID()
+
+	u := category.UpdateFromMessage(entity.Message{ID: string(ID), Type: "CATEGORY_UPDATED", Version: 3, Payload: map[string]interface{}{"Name": "Shirts", "Parent": nil}})
+
+	assert.Equal(t, entity.Version(3), u.Version)
+	assert.Equal(t, "Shirts", *u.Name)
+	// A null parent moves the category to the root, a missing one keeps it
+	assert.Equal(t, entity.ID(""), *u.Parent)
+	assert.Nil(t, u.Slug)
+
+	u = category.UpdateFromMessage(entity.Message{ID: string(ID), Type: "CATEGORY_UPDATED", Version: 4, Payload: map[string]interface{}{"Slug": "shirts"}})
+
+	assert.Nil(t, u.Parent)
+	assert.Equal(t, "shirts", *u.Slug)
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+)
+
+//Barcode types, the GTIN family of barcodes
+const (
+	BarcodeEAN8   = "EAN-8"
+	BarcodeUPCA   = "UPC-A"
+	BarcodeEAN13  = "EAN-13"
+	BarcodeGTIN14 = "GTIN-14"
+)
+
+//ErrBarcodeLength the barcode isn't 8, 12, 13 or 14 digits long
+var ErrBarcodeLength = errors.New("Barcode must be 8, 12, 13 or 14 digits")
+
+//ErrBarcodeCheckDigit the last digit of the barcode doesn't match its check digit
+var ErrBarcodeCheckDigit = errors.New("Barcode check digit is invalid")
+
+//DetectBarcode type of the barcode detected from its length, its check digit is validated
+func DetectBarcode(barcode string) (string, error) {
+	for _, r := range barcode {
+		if r < '0' || r > '9' {
+			return "", ErrBarcodeLength
+		}
+	}
+
+	var barcodeType string
+	switch len(barcode) {
+	case 8:
+		barcodeType = BarcodeEAN8
+	case 12:
+		barcodeType = BarcodeUPCA
+	case 13:
+		barcodeType = BarcodeEAN13
+	case 14:
+		barcodeType = BarcodeGTIN14
+	default:
+		return "", ErrBarcodeLength
+	}
+
+	if checkDigit(barcode[:len(barcode)-1]) != barcode[len(barcode)-1] {
+		return "", ErrBarcodeCheckDigit
+	}
+
+	return barcodeType, nil
+}
+
+//GTIN barcode padded with leading zeros to 14 digits, the same product has the same GTIN whatever its barcode type.
+//Barcodes of 14 characters or more are returned as is
+func GTIN(barcode string) string {
+	if len(barcode) >= 14 {
+		return barcode
+	}
+
+	return strings.Repeat("0", 14-len(barcode)) + barcode
+}
+
+//checkDigit GS1 check digit of the digits, weighted 3 and 1 alternately from the rightmost digit
+func checkDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
@@ -10,4 +10,14 @@ type Brand struct {
 	Description string    `json:"description" bson:"description,omitempty"`
 	Slug        string    `json:"slug" bson:"slug,omitempty"`
 	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	Deleted     bool      `json:"-" bson:"deleted,omitempty"`
+}
+
+//UpdateBrand data, only the fields carried by the message are set, nil pointers are omitted
+type UpdateBrand struct {
+	Version     Version    `bson:"_V,omitempty"`
+	Name        *string    `bson:"name,omitempty"`
+	Description *string    `bson:"description,omitempty"`
+	Slug        *string    `bson:"slug,omitempty"`
+	CreatedAt   *time.Time `bson:"createdAt,omitempty"`
 }
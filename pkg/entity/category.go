@@ -1,11 +1,37 @@
 package entity
 
+import "time"
+
 //Category data
 type Category struct {
-	ID          ID     `json:"id" bson:"_id,omitempty"`
-	Version     int32  `json:"version" bson:"_V,omitempty"`
-	Name        string `json:"name" bson:"name"`
-	Description string `json:"description" bson:"description,omitempty"`
-	Slug        string `json:"slug" bson:"slug,omitempty"`
-	CreatedAt   Time   `json:"createdAt" bson:"createdAt"`
+	ID          ID        `json:"id" bson:"_id,omitempty"`
+	Version     Version   `json:"version" bson:"_V,omitempty"`
+	Name        string    `json:"name" bson:"name"`
+	Description string    `json:"description" bson:"description,omitempty"`
+	Slug        string    `json:"slug" bson:"slug,omitempty"`
+	Parent      ID        `json:"parent,omitempty" bson:"parent,omitempty"`
+	Path        string    `json:"path" bson:"path"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	Deleted     bool      `json:"-" bson:"deleted,omitempty"`
+}
+
+//UpdateCategory data, only the fields carried by the message are set, nil pointers are omitted
+type UpdateCategory struct {
+	Version     Version    `bson:"_V,omitempty"`
+	Name        *string    `bson:"name,omitempty"`
+	Description *string    `bson:"description,omitempty"`
+	Slug        *string    `bson:"slug,omitempty"`
+	CreatedAt   *time.Time `bson:"createdAt,omitempty"`
+	//Parent pointing to "" moves the category to the root
+	Parent *ID     `bson:"parent,omitempty"`
+	Path   *string `bson:"path,omitempty"`
+}
+
+//CategoryPath materialized path of a category, the ids from the root down to the category e.g. ",root,parent,id,"
+func CategoryPath(parentPath string, id ID) string {
+	if parentPath == "" {
+		parentPath = ","
+	}
+
+	return parentPath + string(id) + ","
 }
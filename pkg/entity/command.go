@@ -5,6 +5,9 @@ import "time"
 //Command command struct
 type Command struct {
 
+	// ID command id, the events produced by the command reference it
+	ID ID `json:"id" bson:"_id,omitempty"`
+
 	// AggregateID contains the AggregateID
 	AggregateID string `json:"aggregateId" bson:"aggregateId"`
 
@@ -9,6 +9,15 @@ import (
 //ErrNotFound not found
 var ErrNotFound = errors.New("Not found")
 
+//ErrVersionConflict the aggregate version changed since it was read
+var ErrVersionConflict = errors.New("Version conflict")
+
+//ErrAlreadyExists a unique field is already used by another document
+var ErrAlreadyExists = errors.New("Already exists")
+
+//ErrAggregateDeleted the events of the aggregate end with its deletion
+var ErrAggregateDeleted = errors.New("Aggregate deleted")
+
 // Op A unique string operation pointing to a function
 // Multiple operations can construct a friendly stack trace.
 type Op string
@@ -27,6 +36,8 @@ const (
 	Unexpected
 	//NoUpdates no updates found
 	NoUpdates
+	//DeliveryFailed the change is stored but its events are not delivered yet, the outbox relay retries them
+	DeliveryFailed
 )
 
 //ErrorMessage ErrorMessage
@@ -1,6 +1,11 @@
 package entity
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 // Event describe a change that happened to the Aggregate
 //
@@ -64,3 +69,173 @@ func (m Model) EventVersion() int {
 func (m Model) EventAt() time.Time {
 	return m.At
 }
+
+//StoredEvent event appended to the event store, an aggregate has a single event per version
+type StoredEvent struct {
+	ID            ID                     `json:"id" bson:"_id"`
+	AggregateType string                 `json:"aggregateType" bson:"aggregateType"`
+	AggregateID   string                 `json:"aggregateId" bson:"aggregateId"`
+	Type          string                 `json:"type" bson:"type"`
+	Version       Version                `json:"version" bson:"version"`
+	Payload       map[string]interface{} `json:"payload,omitempty" bson:"payload,omitempty"`
+	Timestamp     time.Time              `json:"timestamp" bson:"timestamp"`
+	CommandID     ID                     `json:"commandId,omitempty" bson:"commandId,omitempty"`
+}
+
+//NewStoredEvents create the events of an aggregate type from the messages emitted by its service
+func NewStoredEvents(aggregateType string, messages []*Message) []*StoredEvent {
+	events := make([]*StoredEvent, len(messages))
+
+	for i, m := range messages {
+		events[i] = &StoredEvent{
+			ID:            NewID(),
+			AggregateType: aggregateType,
+			AggregateID:   m.ID,
+			Type:          m.Type,
+			Version:       m.Version,
+			Payload:       m.Payload,
+			Timestamp:     m.Timestamp,
+		}
+	}
+
+	return events
+}
+
+//String string payload value, "" if missing
+func (e *StoredEvent) String(key string) string {
+	s, _ := e.Payload[key].(string)
+	return s
+}
+
+//Int integer payload value, 0 if missing
+func (e *StoredEvent) Int(key string) int64 {
+	i, _ := toInt64(e.Payload[key])
+	return i
+}
+
+//Time time payload value, zero if missing
+//times are decoded as primitive.DateTime from the store and RFC3339 strings from json messages
+func (e *StoredEvent) Time(key string) time.Time {
+	switch v := e.Payload[key].(type) {
+	case time.Time:
+		return v
+	case primitive.DateTime:
+		return time.Unix(0, int64(v)*int64(time.Millisecond))
+	case string:
+		t, _ := time.Parse(time.RFC3339, v)
+		return t
+	}
+
+	return time.Time{}
+}
+
+//Money money payload value, nil if missing
+//prices emitted before money had a currency are whole numbers, they are read as DefaultCurrency and 0 as no price
+func (e *StoredEvent) Money(key string) *Money {
+	switch v := e.Payload[key].(type) {
+	case *Money:
+		return v
+	case Money:
+		return &v
+	case map[string]interface{}:
+		return moneyFromMap(v)
+	case primitive.M:
+		return moneyFromMap(v)
+	case primitive.D:
+		return moneyFromMap(v.Map())
+	}
+
+	if amount, ok := toInt64(e.Payload[key]); ok && amount != 0 {
+		return LegacyMoney(amount)
+	}
+
+	return nil
+}
+
+//Decode decode a document payload value into v, snapshots hold the whole aggregate document
+func (e *StoredEvent) Decode(key string, v interface{}) error {
+	b, err := bson.Marshal(e.Payload[key])
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(b, v)
+}
+
+//Options product options payload value, nil if missing
+func (e *StoredEvent) Options(key string) []*Option {
+	switch v := e.Payload[key].(type) {
+	case []*Option:
+		return v
+	case []interface{}:
+		return optionsFromSlice(v)
+	case primitive.A:
+		return optionsFromSlice(v)
+	}
+
+	return nil
+}
+
+func optionsFromSlice(a []interface{}) []*Option {
+	var options []*Option
+	for _, item := range a {
+		var m map[string]interface{}
+		switch o := item.(type) {
+		case map[string]interface{}:
+			m = o
+		case primitive.M:
+			m = o
+		case primitive.D:
+			m = o.Map()
+		default:
+			continue
+		}
+
+		option := &Option{}
+		option.Name, _ = m["name"].(string)
+
+		var values []interface{}
+		switch v := m["values"].(type) {
+		case []interface{}:
+			values = v
+		case primitive.A:
+			values = v
+		case []string:
+			option.Values = v
+		}
+		for _, value := range values {
+			if s, ok := value.(string); ok {
+				option.Values = append(option.Values, s)
+			}
+		}
+
+		options = append(options, option)
+	}
+
+	return options
+}
+
+func moneyFromMap(m map[string]interface{}) *Money {
+	amount, _ := toInt64(m["amount"])
+	currency, _ := m["currency"].(string)
+
+	return &Money{Amount: amount, Currency: currency}
+}
+
+//toInt64 numbers are decoded as int32/int64 from the store and float64 from json messages
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+//DefaultLocation location of the stock recorded before it was tracked per location
+const DefaultLocation ID = "default"
+
+//Location warehouse or store holding variants stock
+type Location struct {
+	ID        ID        `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	Address   string    `json:"address,omitempty" bson:"address,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+//Stock variant stock at a location
+type Stock struct {
+	Quantity int `json:"quantity" bson:"quantity"` //available stock, reserved stock excluded
+	Reserved int `json:"reserved" bson:"reserved"`
+}
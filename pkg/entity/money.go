@@ -0,0 +1,43 @@
+package entity
+
+//DefaultCurrency currency of the prices stored before prices had a currency
+const DefaultCurrency = "USD"
+
+//currencies ISO-4217 currencies accepted for prices with their number of minor unit digits
+var currencies = map[string]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EGP": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "MAD": 2, "MXN": 2, "NOK": 2,
+	"NZD": 2, "OMR": 3, "PLN": 2, "QAR": 2, "RON": 2, "RUB": 2, "SAR": 2, "SEK": 2,
+	"SGD": 2, "THB": 2, "TND": 3, "TRY": 2, "USD": 2, "ZAR": 2,
+}
+
+//Money amount in minor units (e.g. cents) of an ISO-4217 currency
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount" validate:"min=1"`
+	Currency string `json:"currency" bson:"currency" validate:"required,len=3"`
+}
+
+//IsCurrency check if code is a supported ISO-4217 currency
+func IsCurrency(code string) bool {
+	_, ok := currencies[code]
+	return ok
+}
+
+//LegacyMoney convert a price stored as a whole number without currency to money in DefaultCurrency
+func LegacyMoney(amount int64) *Money {
+	for i := 0; i < currencies[DefaultCurrency]; i++ {
+		amount *= 10
+	}
+
+	return &Money{Amount: amount, Currency: DefaultCurrency}
+}
+
+//Equal check if both amounts and currencies are equal, nil is only equal to nil
+func (m *Money) Equal(o *Money) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+
+	return m.Amount == o.Amount && m.Currency == o.Currency
+}
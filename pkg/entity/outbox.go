@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+//OutboxEntry message stored with the state change that produced it, waiting to be published
+type OutboxEntry struct {
+	ID        ID        `json:"id" bson:"_id"`
+	Topic     string    `json:"topic" bson:"topic"`
+	Message   *Message  `json:"message" bson:"message"`
+	Sent      bool      `json:"sent" bson:"sent"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	SentAt    time.Time `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+	//Attempts failed publishes of the aggregate while the entry was pending, the relay retries it from RetryAt
+	Attempts int       `json:"attempts,omitempty" bson:"attempts,omitempty"`
+	RetryAt  time.Time `json:"retryAt,omitempty" bson:"retryAt,omitempty"`
+}
+
+//NewOutboxEntries create the outbox entries publishing messages to topic
+func NewOutboxEntries(topic string, messages []*Message) []*OutboxEntry {
+	createdAt := time.Now()
+	entries := make([]*OutboxEntry, len(messages))
+
+	for i, m := range messages {
+		entries[i] = &OutboxEntry{ID: NewID(), Topic: topic, Message: m, CreatedAt: createdAt}
+	}
+
+	return entries
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+//PriceChange effective price of a variant in a price list from At until the next change, PriceList is empty for the base price
+type PriceChange struct {
+	ID        ID        `json:"-" bson:"_id"`
+	Variant   ID        `json:"variant" bson:"variant"`
+	PriceList ID        `json:"priceList,omitempty" bson:"priceList"`
+	Price     *Money    `json:"price" bson:"price"`
+	Sale      bool      `json:"sale,omitempty" bson:"sale,omitempty"`
+	At        time.Time `json:"at" bson:"at"`
+	Version   Version   `json:"version" bson:"version"`
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+//PriceList named set of variant prices in a single currency, e.g. per country or customer group
+type PriceList struct {
+	ID       ID       `json:"id" bson:"_id"`
+	Name     string   `json:"name" bson:"name"`
+	Currency string   `json:"currency" bson:"currency"`
+	Regions  []string `json:"regions,omitempty" bson:"regions,omitempty"`
+	//Priority higher priority lists win when several lists of the same region apply
+	Priority  int       `json:"priority" bson:"priority"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+//AppliesTo check if the list prices variants in the region, lists without regions apply everywhere
+func (l *PriceList) AppliesTo(region string) bool {
+	if len(l.Regions) == 0 {
+		return true
+	}
+
+	for _, r := range l.Regions {
+		if r == region {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Specific check if the list is restricted to regions
+func (l *PriceList) Specific() bool {
+	return len(l.Regions) > 0
+}
@@ -13,10 +13,47 @@ type Product struct {
 	Image       string    `json:"image,omitempty" bson:"image,omitempty"`
 	Brand       string    `json:"brand,omitempty" bson:"brand,omitempty"`
 	Category    string    `json:"category,omitempty" bson:"category,omitempty"`
-	Price       int8      `json:"price,omitempty" bson:"price,omitempty"`
+	Price       *Money    `json:"price,omitempty" bson:"price,omitempty"`
 	Status      string    `json:"status,omitempty" bson:"status,omitempty"`
 	Seller      string    `json:"seller,omitempty" bson:"seller,omitempty"`
 	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+	//LowStockThreshold available quantity at or below which the product variants are low on stock, the global default without it
+	LowStockThreshold *int `json:"lowStockThreshold,omitempty" bson:"lowStockThreshold,omitempty"`
+	//Options options the product variants are generated from
+	Options []*Option `json:"options,omitempty" bson:"options,omitempty"`
+	//Type product type declaring the attributes of the variants, the variants attributes are free without it
+	Type ID `json:"type,omitempty" bson:"type,omitempty"`
+}
+
+//MaxOptionValues values an option may take, the max of the Values validation
+const MaxOptionValues = 50
+
+//MaxOptionCombinations combinations of the options values of a product, the variants generated at once in a single
+//transaction are bounded by it
+const MaxOptionCombinations = 100
+
+//Option product option with the values its variants may take, e.g. size: s, m, l
+type Option struct {
+	Name   string   `json:"name" bson:"name" validate:"required"`
+	Values []string `json:"values" bson:"values" validate:"required,min=1,max=50,dive,required"`
+}
+
+//OptionCombinations number of combinations of the options values, the size of the variants matrix
+func OptionCombinations(options []*Option) int {
+	if len(options) == 0 {
+		return 0
+	}
+
+	n := 1
+	for _, o := range options {
+		n *= len(o.Values)
+		//Stop before overflowing, the count is only compared to MaxOptionCombinations
+		if n > MaxOptionCombinations {
+			return n
+		}
+	}
+
+	return n
 }
 
 //UpdateProduct data
@@ -29,8 +66,34 @@ type UpdateProduct struct {
 	Image       string  `bson:"image,omitempty" structs:",omitempty"`
 	Brand       string  `bson:"brand,omitempty" structs:",omitempty"`
 	Category    string  `bson:"category,omitempty" structs:",omitempty"`
-	Price       int8    `bson:"price,omitempty" structs:",omitempty"`
+	Price       *Money  `bson:"price,omitempty" structs:",omitempty"`
 	Status      string  `bson:"status,omitempty" structs:",omitempty"`
+	//LowStockThreshold set only when updated, nil pointers are omitted
+	LowStockThreshold *int      `bson:"lowStockThreshold,omitempty" structs:",omitempty"`
+	Options           []*Option `bson:"options,omitempty" structs:",omitempty"`
+	Type              ID        `bson:"type,omitempty" structs:",omitempty"`
+}
+
+//Option find the product option by name, nil if the product has no such option
+func (p *Product) Option(name string) *Option {
+	for _, o := range p.Options {
+		if o.Name == name {
+			return o
+		}
+	}
+
+	return nil
+}
+
+//Allows check if the value is one of the option values
+func (o *Option) Allows(value string) bool {
+	for _, v := range o.Values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
 }
 
 //Validate Validate Product Struct
@@ -43,7 +106,7 @@ func (p *Product) Validate() []string {
 		errs = append(errs, "Name : Name is required")
 	}
 
-	if (p.Price == 0) || (p.Price < 1) {
+	if (p.Price == nil) || (p.Price.Amount < 1) {
 		errs = append(errs, "Price :- Provide Valid Price")
 	}
 
@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Attribute value types
+const (
+	AttributeEnum    = "enum"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeText    = "text"
+)
+
+//ProductType kind of product declaring the attributes its variants may have, e.g. shoes with size and color
+type ProductType struct {
+	ID         ID                 `json:"id" bson:"_id"`
+	Name       string             `json:"name" bson:"name"`
+	Attributes []*AttributeSchema `json:"attributes" bson:"attributes"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	//SKUTemplate template of the SKUs generated for the variants created without one, the global template without it
+	SKUTemplate string `json:"skuTemplate,omitempty" bson:"skuTemplate,omitempty"`
+}
+
+//AttributeSchema attribute declared by a product type, values are the allowed enum values and unit the unit of numbers
+type AttributeSchema struct {
+	Key      string   `json:"key" bson:"key" validate:"required"`
+	Type     string   `json:"type" bson:"type" validate:"required,oneof=enum number boolean text"`
+	Values   []string `json:"values,omitempty" bson:"values,omitempty" validate:"omitempty,dive,required"`
+	Unit     string   `json:"unit,omitempty" bson:"unit,omitempty" validate:"omitempty"`
+	Required bool     `json:"required,omitempty" bson:"required,omitempty"`
+}
+
+//Attribute find the attribute schema by key, nil if the type doesn't declare it
+func (t *ProductType) Attribute(key string) *AttributeSchema {
+	for _, a := range t.Attributes {
+		if a.Key == key {
+			return a
+		}
+	}
+
+	return nil
+}
+
+//ValidateAttributes check the variant attributes against the type, the attributes are lower cased as the schema is
+func (t *ProductType) ValidateAttributes(attributes map[string]string) []ErrorField {
+	var errs []ErrorField
+
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		a := t.Attribute(k)
+		if a == nil {
+			errs = append(errs, ErrorField{Field: "Attributes", Error: "Attribute " + k + " is not declared by product type " + t.Name})
+			continue
+		}
+
+		if err := a.Check(attributes[k]); err != "" {
+			errs = append(errs, ErrorField{Field: "Attributes", Error: "Attribute " + k + " " + err})
+		}
+	}
+
+	for _, a := range t.Attributes {
+		if _, ok := attributes[a.Key]; a.Required && !ok {
+			errs = append(errs, ErrorField{Field: "Attributes", Error: "Attribute " + a.Key + " is required"})
+		}
+	}
+
+	return errs
+}
+
+//Check check the value against the attribute type, returns what is wrong with it or "" if valid
+//Numbers may be suffixed with the attribute unit, e.g. 42cm or 42 cm
+func (a *AttributeSchema) Check(value string) string {
+	switch a.Type {
+	case AttributeEnum:
+		for _, v := range a.Values {
+			if v == value {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(a.Values, ", ")
+	case AttributeNumber:
+		number := strings.TrimSpace(strings.TrimSuffix(value, a.Unit))
+		if _, err := strconv.ParseFloat(number, 64); err != nil {
+			if a.Unit != "" {
+				return "must be a number in " + a.Unit
+			}
+			return "must be a number"
+		}
+	case AttributeBoolean:
+		if value != "true" && value != "false" {
+			return "must be true or false"
+		}
+	case AttributeText:
+		if strings.TrimSpace(value) == "" {
+			return "must not be empty"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+//Reservation status
+const (
+	ReservationPending   = "pending"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+)
+
+//Reservation variant stock held for a checkout until it's committed, released or it expires
+type Reservation struct {
+	ID        ID        `json:"id" bson:"_id"`
+	Variant   ID        `json:"variant" bson:"variant"`
+	Location  ID        `json:"location" bson:"location"`
+	Quantity  int       `json:"quantity" bson:"quantity"`
+	Reference string    `json:"reference,omitempty" bson:"reference,omitempty"`
+	Status    string    `json:"status" bson:"status"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+//SalePrice status
+const (
+	SalePriceScheduled = "scheduled"
+	SalePriceActive    = "active"
+)
+
+//SalePrice sale price of a variant valid from ValidFrom until ValidTo, in a price list or over the base price without PriceList
+//ended sale prices are removed from the variant
+type SalePrice struct {
+	ID        ID        `json:"id" bson:"id"`
+	PriceList ID        `json:"priceList,omitempty" bson:"priceList,omitempty"`
+	Price     *Money    `json:"price" bson:"price"`
+	ValidFrom time.Time `json:"validFrom" bson:"validFrom"`
+	ValidTo   time.Time `json:"validTo" bson:"validTo"`
+	Status    string    `json:"status" bson:"status"`
+}
+
+//EffectiveAt check if t is in the validity window, the scheduler may not have started or ended the sale yet
+func (s *SalePrice) EffectiveAt(t time.Time) bool {
+	return !s.ValidFrom.After(t) && t.Before(s.ValidTo)
+}
+
+//ActiveSalePrice lowest sale price of the price list effective at t, "" for the base price, nil without sale
+func (v *Variant) ActiveSalePrice(priceList ID, t time.Time) *SalePrice {
+	var sale *SalePrice
+	for _, s := range v.SalePrices {
+		if s.PriceList != priceList || !s.EffectiveAt(t) {
+			continue
+		}
+
+		if sale == nil || s.Price.Amount < sale.Price.Amount {
+			sale = s
+		}
+	}
+
+	return sale
+}
+
+//SetSalePrice set the base price sale effective at t for reads
+func (v *Variant) SetSalePrice(t time.Time) {
+	v.SalePrice = v.ActiveSalePrice("", t)
+}
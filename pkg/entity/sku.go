@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"fmt"
+	"hash/crc32"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//skuPlaceholder placeholder of a SKU template, e.g. {brand} or {attr.size}
+var skuPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+//skuSeparators runs of characters that are not letters or digits
+var skuSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+//skuLetters Latin letters without decomposition to ASCII, the others lose their diacritics, e.g. é to e
+var skuLetters = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "ł", "l", "đ", "d", "ð", "d", "þ", "th", "ı", "i")
+
+//UnknownSKUPlaceholders placeholders of the template that can't be rendered
+//Known placeholders are {brand}, {category}, {product-slug}, {attrs} with every attribute value and {attr.<key>}.
+func UnknownSKUPlaceholders(template string) []string {
+	var unknown []string
+	for _, m := range skuPlaceholder.FindAllStringSubmatch(template, -1) {
+		switch name := m[1]; {
+		case name == "brand", name == "category", name == "product-slug", name == "attrs":
+		case strings.HasPrefix(name, "attr.") && len(name) > len("attr."):
+		default:
+			unknown = append(unknown, m[0])
+		}
+	}
+
+	return unknown
+}
+
+//RenderSKU SKU of the product variant with the attributes from the template, made of lower case letters, digits and
+//dashes only, the separators of missing values are dropped, e.g. {brand}-{product-slug}-{attr.size} renders nike-air-max-44
+//
+//Latin letters are transliterated to ASCII, e.g. Café renders cafe. Values of other scripts can't be, the product
+//renders the start of its id and the attribute values a checksum so that the SKUs of the variants still differ
+func RenderSKU(template string, p *Product, attributes map[string]string) string {
+	sku := skuPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		switch {
+		case name == "brand":
+			return skuPart(p.Brand)
+		case name == "category":
+			return skuPart(p.Category)
+		case name == "product-slug":
+			slug := p.Slug
+			if slug == "" {
+				slug = p.Name
+			}
+			if part := skuPart(slug); part != "" || slug == "" {
+				return part
+			}
+
+			id := strings.Replace(string(p.ID), "-", "", -1)
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			return skuPart(id)
+		case name == "attrs":
+			keys := make([]string, 0, len(attributes))
+			for k := range attributes {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			values := make([]string, len(keys))
+			for i, k := range keys {
+				values[i] = skuValue(attributes[k])
+			}
+			return strings.Join(values, "-")
+		case strings.HasPrefix(name, "attr."):
+			return skuValue(attributes[strings.TrimPrefix(name, "attr.")])
+		}
+
+		return ""
+	})
+
+	return skuPart(sku)
+}
+
+//skuPart value transliterated to lower case ASCII letters and digits separated by dashes, "" if none is left
+func skuPart(value string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(skuLetters.Replace(strings.ToLower(value))) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Trim(skuSeparators.ReplaceAllString(b.String(), "-"), "-")
+}
+
+//skuValue attribute value as a SKU part, the checksum of the value if it has no ASCII letters or digits
+func skuValue(value string) string {
+	if part := skuPart(value); part != "" || value == "" {
+		return part
+	}
+
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(value)))
+}
@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+//Stock movement types
+const (
+	StockReceipt    = "receipt"
+	StockSale       = "sale"
+	StockReturn     = "return"
+	StockAdjustment = "adjustment"
+)
+
+//StockMovement signed change of a variant available stock at a location, the running sum of the variant movements is its stock
+type StockMovement struct {
+	ID        ID        `json:"id" bson:"_id"`
+	Variant   ID        `json:"variant" bson:"variant"`
+	Location  ID        `json:"location" bson:"location"`
+	Type      string    `json:"type" bson:"type"`
+	Quantity  int       `json:"quantity" bson:"quantity"`
+	Reason    string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	Reference string    `json:"reference,omitempty" bson:"reference,omitempty"`
+	Version   Version   `json:"version" bson:"version"` //variant version of the movement event
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -1,6 +1,9 @@
 package entity
 
-import "time"
+import (
+	"net/url"
+	"time"
+)
 
 //Variant Variant
 type Variant struct {
@@ -8,18 +11,53 @@ type Variant struct {
 	Product    ID                `json:"product" bson:"product"`
 	Version    Version           `json:"version" bson:"_V"`
 	SKU        string            `json:"sku,omitempty" bson:"sku,omitempty"`
-	Quantity   int               `json:"quantity" bson:"quantity"`
-	Price      int               `json:"price" bson:"price"`
+	Quantity   int               `json:"quantity" bson:"quantity"` //available stock, the stock ledger sum less the reserved stock
+	Reserved   int               `json:"reserved" bson:"reserved"`
+	Stock      map[string]*Stock `json:"stock,omitempty" bson:"stock,omitempty"` //location id to stock, Quantity and Reserved are the totals
+	Price      *Money            `json:"price,omitempty" bson:"price,omitempty"`
+	Prices     map[string]*Money `json:"prices,omitempty" bson:"prices,omitempty"` //price list id to price
+	SalePrices []*SalePrice      `json:"salePrices,omitempty" bson:"salePrices,omitempty"`
+	SalePrice  *SalePrice        `json:"salePrice,omitempty" bson:"-"` //base price sale effective at read time
 	Image      string            `json:"image,omitempty" bson:"image,omitempty"`
 	Attributes map[string]string `json:"attributes" bson:"attributes"`
+	Signature  string            `json:"-" bson:"signature,omitempty"` //canonical attributes, unique per product
 	CreatedAt  time.Time         `json:"createdAt" bson:"createdAt"`
+	//Barcode as entered, BarcodeType detected from its length and GTIN the barcode padded to 14 digits, unique
+	Barcode     string `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	BarcodeType string `json:"barcodeType,omitempty" bson:"barcodeType,omitempty"`
+	GTIN        string `json:"gtin,omitempty" bson:"gtin,omitempty"`
+	//Edited version of the last merchandising change, stock changes only bump the version
+	Edited Version `json:"-" bson:"_E,omitempty"`
+}
+
+//Stale reports whether version misses merchandising changes of the variant, the stock changes made since are ignored
+//so reservations and stock movements don't fail pending edits
+func (v *Variant) Stale(version Version) bool {
+	return version > v.Version || version < v.Edited
 }
 
 //UpdateVariant data
 type UpdateVariant struct {
-	Version  Version `bson:"_V,omitempty" structs:",omitempty"`
-	SKU      string  `bson:"sku,omitempty" structs:",omitempty"`
-	Quantity int     `bson:"quantity,omitempty" structs:",omitempty"`
-	Price    int     `bson:"price,omitempty" structs:",omitempty"`
-	Image    string  `bson:"image,omitempty" structs:",omitempty"`
+	Version Version `bson:"_V,omitempty" structs:",omitempty"`
+	SKU     string  `bson:"sku,omitempty" structs:",omitempty"`
+	Price   *Money  `bson:"price,omitempty" structs:",omitempty"`
+	Image   string  `bson:"image,omitempty" structs:",omitempty"`
+	//Attributes the whole set of attributes once updated
+	Attributes map[string]string `bson:"attributes,omitempty" structs:",omitempty"`
+	Signature  string            `bson:"signature,omitempty" structs:",omitempty"`
+	//Barcode set with its type and GTIN once updated
+	Barcode     string  `bson:"barcode,omitempty" structs:",omitempty"`
+	BarcodeType string  `bson:"barcodeType,omitempty" structs:",omitempty"`
+	GTIN        string  `bson:"gtin,omitempty" structs:",omitempty"`
+	Edited      Version `bson:"_E,omitempty" structs:",omitempty"`
+}
+
+//AttributesSignature canonical form of the attributes, keys sorted and escaped, equal attributes have equal signatures
+func AttributesSignature(attributes map[string]string) string {
+	values := url.Values{}
+	for k, v := range attributes {
+		values.Set(k, v)
+	}
+
+	return values.Encode()
 }
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+//Webhook url called with the events it's registered to
+type Webhook struct {
+	ID        ID        `json:"id" bson:"_id"`
+	URL       string    `json:"url" bson:"url"`
+	Events    []string  `json:"events" bson:"events"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,27 @@
+//go:generate mockgen -source interface.go -destination eventstore_mock.go -package eventstore
+
+package eventstore
+
+import (
+	"context"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//StoreReader event store reader interface
+type storeReader interface {
+	FindByAggregate(id string) ([]*entity.StoredEvent, error)
+	FindAggregateIDs(aggregateType string) ([]string, error)
+}
+
+//StoreWriter event store writer interface
+type storeWriter interface {
+	WithContext(ctx context.Context) StoreRepository
+	Append(events []*entity.StoredEvent) error
+}
+
+//StoreRepository event store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
@@ -0,0 +1,115 @@
+package eventstore
+
+import (
+	"context"
+	"log"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db  *mongo.Database
+	ctx context.Context
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db:  db,
+		ctx: context.TODO(),
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes unique aggregate version, it rejects concurrent appends of the same version
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("events")
+
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{primitive.E{Key: "aggregateId", Value: 1}, primitive.E{Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{primitive.E{Key: "aggregateType", Value: 1}, primitive.E{Key: "aggregateId", Value: 1}}},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating events indexes", err)
+	}
+}
+
+//WithContext repository bound to ctx, used to append events in the transaction of the aggregate changes
+func (r *MongoRepository) WithContext(ctx context.Context) StoreRepository {
+	return &MongoRepository{
+		db:  r.db,
+		ctx: ctx,
+	}
+}
+
+//Append append events, returns entity.ErrVersionConflict if a version of the aggregate is already stored
+func (r *MongoRepository) Append(events []*entity.StoredEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	coll := r.db.Collection("events")
+
+	var docs []interface{}
+	for _, e := range events {
+		docs = append(docs, e)
+	}
+
+	_, err := coll.InsertMany(r.ctx, docs)
+	if mongodb.IsDuplicateKeyError(err) {
+		return entity.ErrVersionConflict
+	}
+
+	return err
+}
+
+//FindByAggregate find the events of an aggregate ordered by version
+func (r *MongoRepository) FindByAggregate(id string) ([]*entity.StoredEvent, error) {
+	coll := r.db.Collection("events")
+
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "version", Value: 1}})
+
+	cur, err := coll.Find(r.ctx, bson.M{"aggregateId": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	events := []*entity.StoredEvent{}
+	if err := cur.All(r.ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+//FindAggregateIDs find the ids of all the aggregates of a type
+func (r *MongoRepository) FindAggregateIDs(aggregateType string) ([]string, error) {
+	coll := r.db.Collection("events")
+
+	values, err := coll.Distinct(r.ctx, "aggregateId", bson.M{"aggregateType": aggregateType})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
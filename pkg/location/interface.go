@@ -0,0 +1,39 @@
+//go:generate mockgen -source interface.go -destination location_mock.go -package location
+
+package location
+
+import "github.com/markus-azer/products-service/pkg/entity"
+
+//StoreReader location reader interface
+type storeReader interface {
+	FindOneByID(id entity.ID) (*entity.Location, error)
+	FindMany() ([]*entity.Location, error)
+}
+
+//StoreWriter location writer interface
+type storeWriter interface {
+	Create(l *entity.Location) error
+}
+
+//StoreRepository location store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//Reader interface
+type reader interface {
+	FindOneByID(id entity.ID) (*entity.Location, *entity.Error)
+	FindMany() ([]*entity.Location, *entity.Error)
+}
+
+//Writer interface
+type writer interface {
+	Create(createLocationDTO CreateLocationDTO) (*entity.ID, *entity.Error)
+}
+
+//UseCase use case interface
+type UseCase interface {
+	reader
+	writer
+}
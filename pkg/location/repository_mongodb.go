@@ -0,0 +1,93 @@
+package location
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db *mongo.Database
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db: db,
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes unique names, the default location holding the stock recorded before locations is created if missing
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("locations")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating locations indexes", err)
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{"name": "Default", "createdAt": time.Now()}}
+	if _, err := coll.UpdateOne(context.TODO(), bson.M{"_id": entity.DefaultLocation}, update, options.Update().SetUpsert(true)); err != nil {
+		log.Println("Error on creating the default location", err)
+	}
+}
+
+//FindOneByID find location by Id
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Location, error) {
+	result := entity.Location{}
+	coll := r.db.Collection("locations")
+	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindMany find all the locations sorted by name
+func (r *MongoRepository) FindMany() ([]*entity.Location, error) {
+	coll := r.db.Collection("locations")
+
+	cur, err := coll.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	locations := []*entity.Location{}
+	if err := cur.All(context.TODO(), &locations); err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
+
+//Create create new location, returns entity.ErrAlreadyExists if the name is used
+func (r *MongoRepository) Create(l *entity.Location) error {
+	coll := r.db.Collection("locations")
+
+	_, err := coll.InsertOne(context.TODO(), l)
+	if mongodb.IsDuplicateKeyError(err) {
+		return entity.ErrAlreadyExists
+	}
+
+	return err
+}
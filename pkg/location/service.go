@@ -0,0 +1,85 @@
+package location
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//Service service interface
+type Service struct {
+	storeRepo StoreRepository
+}
+
+//NewService create new service
+func NewService(storeR StoreRepository) *Service {
+	return &Service{
+		storeRepo: storeR,
+	}
+}
+
+//CreateLocationDTO new location DTO
+type CreateLocationDTO struct {
+	Name    string `json:"name" validate:"required,min=2"`
+	Address string `json:"address,omitempty" validate:"omitempty"`
+}
+
+//Create new location
+func (s *Service) Create(createLocationDTO CreateLocationDTO) (*entity.ID, *entity.Error) {
+	if err := validator.New().Struct(createLocationDTO); err != nil {
+		errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	l := &entity.Location{
+		ID:        entity.NewID(),
+		Name:      createLocationDTO.Name,
+		Address:   createLocationDTO.Address,
+		CreatedAt: time.Now(),
+	}
+
+	err := s.storeRepo.Create(l)
+	switch err {
+	case entity.ErrAlreadyExists:
+		return nil, &entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Name", Error: "Location " + l.Name + " already exists"}}}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return &l.ID, nil
+}
+
+//FindOneByID location
+func (s *Service) FindOneByID(ID entity.ID) (*entity.Location, *entity.Error) {
+	l, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Location with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return l, nil
+}
+
+//FindMany all the locations
+func (s *Service) FindMany() ([]*entity.Location, *entity.Error) {
+	locations, err := s.storeRepo.FindMany()
+	if err != nil {
+		return nil, &entity.Error{Op: "FindMany", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return locations, nil
+}
@@ -0,0 +1,49 @@
+//go:generate mockgen -source interface.go -destination outbox_mock.go -package outbox
+
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//MessagesWriter outbox writer
+type messagesWriter interface {
+	Publish(entries []*entity.OutboxEntry) []error
+}
+
+//MessagesRepository repository interface
+type MessagesRepository interface {
+	messagesWriter
+}
+
+//StoreReader outbox reader interface
+type storeReader interface {
+	FindPending(limit int) ([]*entity.OutboxEntry, error)
+	FindPendingByAggregates(ids []string) ([]*entity.OutboxEntry, error)
+}
+
+//StoreWriter outbox writer interface
+type storeWriter interface {
+	WithContext(ctx context.Context) StoreRepository
+	Append(entries []*entity.OutboxEntry) error
+	MarkSent(ids []entity.ID) error
+	Backoff(aggregateID string, retryAt time.Time) error
+	Claim(aggregateID string, owner entity.ID, lease time.Duration) (bool, error)
+	Release(aggregateIDs []string, owner entity.ID) error
+}
+
+//StoreRepository outbox store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//UseCase use case interface
+type UseCase interface {
+	Relay(limit int) (int, error)
+	Deliver(aggregateID string) error
+	DeliverMany(aggregateIDs []string) error
+}
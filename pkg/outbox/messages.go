@@ -0,0 +1,22 @@
+package outbox
+
+import (
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+)
+
+//StoreMessages append the messages produced by a command to the event store as events of aggregateType
+//and to the outbox to be published to topic by the outbox relay
+//events and entries are bound to the transaction of the aggregate changes
+func StoreMessages(events eventstore.StoreRepository, entries StoreRepository, aggregateType string, topic string, commandID entity.ID, messages []*entity.Message) error {
+	stored := entity.NewStoredEvents(aggregateType, messages)
+	for _, e := range stored {
+		e.CommandID = commandID
+	}
+
+	if err := events.Append(stored); err != nil {
+		return err
+	}
+
+	return entries.Append(entity.NewOutboxEntries(topic, messages))
+}
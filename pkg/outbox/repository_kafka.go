@@ -0,0 +1,77 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"gopkg.in/confluentinc/confluent-kafka-go.v1/kafka"
+)
+
+//ErrDeliveryTimeout no delivery report received in time, the message may or may not be published
+var ErrDeliveryTimeout = errors.New("Delivery report timeout")
+
+//KafkaRepository kafka repo
+type KafkaRepository struct {
+	producer *kafka.Producer
+	timeout  time.Duration
+}
+
+//NewKafkaRepository create new repository, timeout bounds the wait for the delivery reports
+func NewKafkaRepository(p *kafka.Producer, timeout time.Duration) MessagesRepository {
+	return &KafkaRepository{
+		producer: p,
+		timeout:  timeout,
+	}
+}
+
+//Publish Publish entries to kafka and wait for their delivery reports
+//returns an error per entry, nil if the entry was delivered
+func (r *KafkaRepository) Publish(entries []*entity.OutboxEntry) []error {
+	errs := make([]error, len(entries))
+	deliveryChan := make(chan kafka.Event, len(entries))
+	pending := 0
+
+	for i, e := range entries {
+		topic := e.Topic
+		reqBodyBytes := new(bytes.Buffer)
+		json.NewEncoder(reqBodyBytes).Encode(e.Message)
+
+		err := r.producer.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Key:            []byte(e.Message.ID),
+			Value:          reqBodyBytes.Bytes(),
+			Opaque:         i,
+		}, deliveryChan)
+
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		//Marked as timed out until its delivery report is received
+		errs[i] = ErrDeliveryTimeout
+		pending++
+	}
+
+	timeout := time.After(r.timeout)
+
+	for pending > 0 {
+		select {
+		case ev := <-deliveryChan:
+			m, ok := ev.(*kafka.Message)
+			if !ok {
+				continue
+			}
+
+			errs[m.Opaque.(int)] = m.TopicPartition.Error
+			pending--
+		case <-timeout:
+			return errs
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db  *mongo.Database
+	ctx context.Context
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db:  db,
+		ctx: context.TODO(),
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes indexes supporting the pending entries lookups
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("outbox")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "sent", Value: 1}, primitive.E{Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "message.id", Value: 1}, primitive.E{Key: "sent", Value: 1}}},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating outbox indexes", err)
+	}
+}
+
+//WithContext repository bound to ctx, used to append entries in the transaction of the aggregate changes
+func (r *MongoRepository) WithContext(ctx context.Context) StoreRepository {
+	return &MongoRepository{
+		db:  r.db,
+		ctx: ctx,
+	}
+}
+
+//Append persist entries waiting to be published
+func (r *MongoRepository) Append(entries []*entity.OutboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	coll := r.db.Collection("outbox")
+
+	var docs []interface{}
+	for _, e := range entries {
+		docs = append(docs, e)
+	}
+
+	_, err := coll.InsertMany(r.ctx, docs)
+
+	return err
+}
+
+//FindPending find the oldest entries not published yet, in the order they were produced
+//the entries backed off after a failed publish are left out until their retry time
+func (r *MongoRepository) FindPending(limit int) ([]*entity.OutboxEntry, error) {
+	coll := r.db.Collection("outbox")
+
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "createdAt", Value: 1}, primitive.E{Key: "message.version", Value: 1}}).
+		SetLimit(int64(limit))
+
+	filter := bson.M{
+		"sent": false,
+		"$or":  bson.A{bson.M{"retryAt": bson.M{"$exists": false}}, bson.M{"retryAt": bson.M{"$lte": time.Now()}}},
+	}
+
+	cur, err := coll.Find(r.ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	entries := []*entity.OutboxEntry{}
+	if err := cur.All(r.ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+//FindPendingByAggregates find the entries of several aggregates not published yet, in the order they were produced
+func (r *MongoRepository) FindPendingByAggregates(ids []string) ([]*entity.OutboxEntry, error) {
+	coll := r.db.Collection("outbox")
+
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "createdAt", Value: 1}, primitive.E{Key: "message.version", Value: 1}})
+
+	cur, err := coll.Find(r.ctx, bson.M{"sent": false, "message.id": bson.M{"$in": ids}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	entries := []*entity.OutboxEntry{}
+	if err := cur.All(r.ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+//MarkSent mark entries as published
+func (r *MongoRepository) MarkSent(ids []entity.ID) error {
+	coll := r.db.Collection("outbox")
+
+	_, err := coll.UpdateMany(
+		r.ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.D{primitive.E{Key: "$set", Value: bson.M{"sent": true, "sentAt": time.Now()}}},
+	)
+
+	return err
+}
+
+//Backoff count a failed publish on the pending entries of the aggregate and leave them out of FindPending until retryAt
+func (r *MongoRepository) Backoff(aggregateID string, retryAt time.Time) error {
+	coll := r.db.Collection("outbox")
+
+	_, err := coll.UpdateMany(
+		r.ctx,
+		bson.M{"message.id": aggregateID, "sent": false},
+		bson.D{
+			primitive.E{Key: "$set", Value: bson.M{"retryAt": retryAt}},
+			primitive.E{Key: "$inc", Value: bson.M{"attempts": 1}},
+		},
+	)
+
+	return err
+}
+
+//Claim claim the pending entries of an aggregate for owner during lease, a single publisher publishes an aggregate at once
+//returns false if another owner holds a claim that didn't expire
+func (r *MongoRepository) Claim(aggregateID string, owner entity.ID, lease time.Duration) (bool, error) {
+	coll := r.db.Collection("outbox-claims")
+	now := time.Now()
+
+	//A live claim doesn't match, the upsert then collides with it on the id
+	_, err := coll.UpdateOne(
+		r.ctx,
+		bson.M{"_id": aggregateID, "until": bson.M{"$lte": now}},
+		bson.D{primitive.E{Key: "$set", Value: bson.M{"owner": owner, "until": now.Add(lease)}}},
+		options.Update().SetUpsert(true),
+	)
+	if mongodb.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+//Release release the claims of owner on the aggregates
+func (r *MongoRepository) Release(aggregateIDs []string, owner entity.ID) error {
+	coll := r.db.Collection("outbox-claims")
+
+	_, err := coll.DeleteMany(r.ctx, bson.M{"_id": bson.M{"$in": aggregateIDs}, "owner": owner})
+
+	return err
+}
@@ -0,0 +1,251 @@
+package outbox
+
+import (
+	"log"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//ClaimLease time an aggregate stays claimed by a publisher that stopped before releasing it, it outlasts a publish round
+const ClaimLease = time.Minute
+
+//drainRounds times a publisher reads the pending entries of its claimed aggregates again before releasing them,
+//the entries stored while it was publishing are published by it rather than waiting for the relay
+const drainRounds = 3
+
+//retryBackoff delay before the relay picks up again an aggregate whose publish failed,
+//doubled on each failed attempt up to maxRetryBackoff so a failing aggregate doesn't hold back the others
+const retryBackoff = time.Second
+
+//maxRetryBackoff longest delay between two relays of a failing aggregate
+const maxRetryBackoff = 5 * time.Minute
+
+//Service service interface
+type Service struct {
+	storeRepo StoreRepository
+	msgRepo   MessagesRepository
+}
+
+//NewService create new service
+func NewService(storeR StoreRepository, msgR MessagesRepository) *Service {
+	return &Service{
+		storeRepo: storeR,
+		msgRepo:   msgR,
+	}
+}
+
+//Relay publish a batch of pending entries and mark the delivered ones as sent, returns the number of sent entries
+//The aggregates claimed by another publisher are skipped
+func (s *Service) Relay(limit int) (int, error) {
+	entries, err := s.storeRepo.FindPending(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	owner := entity.NewID()
+	claimed, err := s.claim(aggregates(entries), owner)
+	if err != nil {
+		return 0, err
+	}
+	defer s.release(claimed, owner)
+
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+
+	//Read again once claimed, the entries found before may have been published by the previous owner
+	n, err := s.drain(claimed)
+	if err != nil && n == 0 {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+//Deliver publish the pending entries of an aggregate right away and wait for their delivery reports
+//returns the first delivery error, the undelivered entries are left pending for the relay.
+//An aggregate claimed by another publisher is left to it, it reads the pending entries again before releasing it
+func (s *Service) Deliver(aggregateID string) error {
+	return s.DeliverMany([]string{aggregateID})
+}
+
+//DeliverMany publish the pending entries of several aggregates in a single batch, as Deliver does for one
+func (s *Service) DeliverMany(aggregateIDs []string) error {
+	owner := entity.NewID()
+	claimed, err := s.claim(aggregateIDs, owner)
+	if err != nil {
+		return err
+	}
+	defer s.release(claimed, owner)
+
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	_, err = s.drain(claimed)
+
+	return err
+}
+
+//drain publish the pending entries of the claimed aggregates until none is left or drainRounds is reached,
+//returns the number of sent entries and the first error, the undelivered entries are left pending for the relay
+func (s *Service) drain(claimed []string) (int, error) {
+	n := 0
+	for round := 0; round < drainRounds; round++ {
+		entries, err := s.storeRepo.FindPendingByAggregates(claimed)
+		if err != nil {
+			return n, err
+		}
+
+		if len(entries) == 0 {
+			return n, nil
+		}
+
+		sent, err := s.deliver(entries)
+		n += sent
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+//claim claim the aggregates for owner, returns the claimed ones, the others are claimed by another publisher
+func (s *Service) claim(aggregateIDs []string, owner entity.ID) ([]string, error) {
+	var claimed []string
+	for _, id := range aggregateIDs {
+		ok, err := s.storeRepo.Claim(id, owner, ClaimLease)
+		if err != nil {
+			s.release(claimed, owner)
+			return nil, err
+		}
+
+		if ok {
+			claimed = append(claimed, id)
+		}
+	}
+
+	return claimed, nil
+}
+
+//release release the claims of owner, a claim that fails to be released expires with its lease
+func (s *Service) release(aggregateIDs []string, owner entity.ID) {
+	if len(aggregateIDs) == 0 {
+		return
+	}
+
+	if err := s.storeRepo.Release(aggregateIDs, owner); err != nil {
+		log.Println("Error on releasing outbox claims", err)
+	}
+}
+
+//aggregates ids of the aggregates of the entries, in the order of their first entry
+func aggregates(entries []*entity.OutboxEntry) []string {
+	var ids []string
+	seen := make(map[string]bool)
+
+	for _, e := range entries {
+		if !seen[e.Message.ID] {
+			seen[e.Message.ID] = true
+			ids = append(ids, e.Message.ID)
+		}
+	}
+
+	return ids
+}
+
+//deliver publish entries and mark the delivered ones as sent, returns the number of sent entries and the first delivery error
+//the aggregates that failed are backed off, the relay retries them once their backoff elapsed
+func (s *Service) deliver(entries []*entity.OutboxEntry) (int, error) {
+	sent, failed, deliveryErr := s.publish(entries)
+	if len(sent) > 0 {
+		if err := s.storeRepo.MarkSent(sent); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, e := range failed {
+		if err := s.storeRepo.Backoff(e.Message.ID, time.Now().Add(backoff(e.Attempts))); err != nil {
+			log.Println("Error on backing off outbox entries", e.Message.ID, err)
+		}
+	}
+
+	return len(sent), deliveryErr
+}
+
+//backoff delay before retrying an aggregate whose first pending entry already failed attempts times
+func backoff(attempts int) time.Duration {
+	d := retryBackoff
+	for i := 0; i < attempts && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return d
+}
+
+//publish publish entries, returns the ids of the delivered ones, the first failed entry of each failed aggregate
+//and the first delivery error
+//
+//Delivery is at least once, once an entry of an aggregate fails the following entries of the same aggregate
+//are kept pending even if delivered, so they are published again after it and consumers see the versions in order.
+//The aggregates of the entries must be claimed, a single publisher publishes an aggregate at once
+func (s *Service) publish(entries []*entity.OutboxEntry) ([]entity.ID, []*entity.OutboxEntry, error) {
+	errs := s.msgRepo.Publish(entries)
+
+	var sent []entity.ID
+	var failed []*entity.OutboxEntry
+	var deliveryErr error
+	failedAggregates := make(map[string]bool)
+
+	for i, e := range entries {
+		if failedAggregates[e.Message.ID] {
+			continue
+		}
+
+		if errs[i] != nil {
+			log.Println("Error on publishing outbox entry", e.ID, errs[i])
+			failedAggregates[e.Message.ID] = true
+			failed = append(failed, e)
+			if deliveryErr == nil {
+				deliveryErr = errs[i]
+			}
+			continue
+		}
+
+		sent = append(sent, e.ID)
+	}
+
+	return sent, failed, deliveryErr
+}
+
+//Run relay pending entries until stop is closed, sleeps interval whenever there is nothing to relay
+func (s *Service) Run(interval time.Duration, limit int, stop <-chan struct{}) {
+	for {
+		n, err := s.Relay(limit)
+		if err != nil {
+			log.Println("Error on relaying outbox", err)
+		}
+
+		//Keep going without waiting while there is a backlog
+		wait := interval
+		if n > 0 && err == nil {
+			wait = 0
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package outbox_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayKeepsAggregateOrder(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	storeRepo := outbox.NewMockStoreRepository(controller)
+	messagesRepo := outbox.NewMockMessagesRepository(controller)
+
+	service := outbox.NewService(storeRepo, messagesRepo)
+
+	productA := entity.NewID()
+	productB := entity.NewID()
+
+	entries := entity.NewOutboxEntries("products", []*entity.Message{
+		{ID: string(productA), Type: "PRODUCT_CREATED", Version: 1},
+		{ID: string(productA), Type: "PRODUCT_NAME_UPDATED", Version: 2},
+		{ID: string(productB), Type: "PRODUCT_CREATED", Version: 1},
+	})
+
+	storeRepo.EXPECT().FindPending(10).Return(entries, nil)
+	storeRepo.EXPECT().Claim(string(productA), gomock.Any(), outbox.ClaimLease).Return(true, nil)
+	storeRepo.EXPECT().Claim(string(productB), gomock.Any(), outbox.ClaimLease).Return(true, nil)
+	storeRepo.EXPECT().FindPendingByAggregates([]string{string(productA), string(productB)}).Return(entries, nil)
+	storeRepo.EXPECT().Release([]string{string(productA), string(productB)}, gomock.Any()).Return(nil)
+	messagesRepo.EXPECT().Publish(entries).Return([]error{errors.New("Delivery failed"), nil, nil})
+	storeRepo.EXPECT().MarkSent([]entity.ID{entries[2].ID}).Return(nil)
+	// The failed aggregate is left out of the next relays for a while so it doesn't hold back the others
+	storeRepo.EXPECT().Backoff(string(productA), gomock.Any()).Do(func(aggregateID string, retryAt time.Time) {
+		assert.WithinDuration(t, time.Now().Add(time.Second), retryAt, 100*time.Millisecond)
+	}).Return(nil)
+
+	n, err := service.Relay(10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestDeliverReturnsDeliveryError(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	storeRepo := outbox.NewMockStoreRepository(controller)
+	messagesRepo := outbox.NewMockMessagesRepository(controller)
+
+	service := outbox.NewService(storeRepo, messagesRepo)
+
+	productID := string(entity.NewID())
+
+	entries := entity.NewOutboxEntries("products", []*entity.Message{
+		{ID: productID, Type: "PRODUCT_CREATED", Version: 1},
+		{ID: productID, Type: "PRODUCT_NAME_UPDATED", Version: 2},
+	})
+
+	storeRepo.EXPECT().Claim(productID, gomock.Any(), outbox.ClaimLease).Return(true, nil)
+	storeRepo.EXPECT().FindPendingByAggregates([]string{productID}).Return(entries, nil)
+	storeRepo.EXPECT().Release([]string{productID}, gomock.Any()).Return(nil)
+	messagesRepo.EXPECT().Publish(entries).Return([]error{nil, outbox.ErrDeliveryTimeout})
+	storeRepo.EXPECT().MarkSent([]entity.ID{entries[0].ID}).Return(nil)
+	// The backoff doubles with the failed attempts
+	entries[1].Attempts = 3
+	storeRepo.EXPECT().Backoff(productID, gomock.Any()).Do(func(aggregateID string, retryAt time.Time) {
+		assert.WithinDuration(t, time.Now().Add(8*time.Second), retryAt, 100*time.Millisecond)
+	}).Return(nil)
+
+	err := service.Deliver(productID)
+
+	assert.Equal(t, outbox.ErrDeliveryTimeout, err)
+}
+
+func TestDeliverLeavesClaimedAggregate(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	storeRepo := outbox.NewMockStoreRepository(controller)
+	messagesRepo := outbox.NewMockMessagesRepository(controller)
+
+	service := outbox.NewService(storeRepo, messagesRepo)
+
+	productID := string(entity.NewID())
+
+	// The relay is publishing the product, its entries are neither read nor published twice
+	storeRepo.EXPECT().Claim(productID, gomock.Any(), outbox.ClaimLease).Return(false, nil)
+
+	err := service.Deliver(productID)
+
+	assert.Nil(t, err)
+}
+
+func TestDeliverPublishesEntriesStoredMeanwhile(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	storeRepo := outbox.NewMockStoreRepository(controller)
+	messagesRepo := outbox.NewMockMessagesRepository(controller)
+
+	service := outbox.NewService(storeRepo, messagesRepo)
+
+	productID := string(entity.NewID())
+
+	first := entity.NewOutboxEntries("products", []*entity.Message{{ID: productID, Type: "PRODUCT_CREATED", Version: 1}})
+	second := entity.NewOutboxEntries("products", []*entity.Message{{ID: productID, Type: "PRODUCT_NAME_UPDATED", Version: 2}})
+
+	// The entries of a write that found the product claimed are published before releasing it
+	storeRepo.EXPECT().Claim(productID, gomock.Any(), outbox.ClaimLease).Return(true, nil)
+	gomock.InOrder(
+		storeRepo.EXPECT().FindPendingByAggregates([]string{productID}).Return(first, nil),
+		messagesRepo.EXPECT().Publish(first).Return([]error{nil}),
+		storeRepo.EXPECT().MarkSent([]entity.ID{first[0].ID}).Return(nil),
+		storeRepo.EXPECT().FindPendingByAggregates([]string{productID}).Return(second, nil),
+		messagesRepo.EXPECT().Publish(second).Return([]error{nil}),
+		storeRepo.EXPECT().MarkSent([]entity.ID{second[0].ID}).Return(nil),
+		storeRepo.EXPECT().FindPendingByAggregates([]string{productID}).Return([]*entity.OutboxEntry{}, nil),
+		storeRepo.EXPECT().Release([]string{productID}, gomock.Any()).Return(nil),
+	)
+
+	err := service.Deliver(productID)
+
+	assert.Nil(t, err)
+}
+
+func TestStoreMessages(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	storeRepo := outbox.NewMockStoreRepository(controller)
+
+	commandID := entity.NewID()
+	messages := []*entity.Message{{ID: string(entity.NewID()), Type: "PRODUCT_VARIANT_SKU_UPDATED", Version: 2}}
+
+	gomock.InOrder(
+		eventRepo.EXPECT().Append(gomock.Any()).Do(func(events []*entity.StoredEvent) {
+			assert.Equal(t, 1, len(events))
+			assert.Equal(t, "variant", events[0].AggregateType)
+			assert.Equal(t, commandID, events[0].CommandID)
+		}).Return(nil),
+		storeRepo.EXPECT().Append(gomock.Any()).Do(func(entries []*entity.OutboxEntry) {
+			assert.Equal(t, 1, len(entries))
+			assert.Equal(t, "products", entries[0].Topic)
+			assert.Equal(t, messages[0], entries[0].Message)
+		}).Return(nil),
+	)
+
+	assert.Nil(t, outbox.StoreMessages(eventRepo, storeRepo, "variant", "products", commandID, messages))
+
+	// Nothing is added to the outbox once the event store rejected the messages
+	eventRepo.EXPECT().Append(gomock.Any()).Return(entity.ErrVersionConflict)
+
+	assert.Equal(t, entity.ErrVersionConflict, outbox.StoreMessages(eventRepo, storeRepo, "variant", "products", commandID, messages))
+}
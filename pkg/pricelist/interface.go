@@ -0,0 +1,40 @@
+//go:generate mockgen -source interface.go -destination pricelist_mock.go -package pricelist
+
+package pricelist
+
+import "github.com/markus-azer/products-service/pkg/entity"
+
+//StoreReader price list reader interface
+type storeReader interface {
+	FindOneByID(id entity.ID) (*entity.PriceList, error)
+	FindMany() ([]*entity.PriceList, error)
+	FindByCurrency(currency string) ([]*entity.PriceList, error)
+}
+
+//StoreWriter price list writer interface
+type storeWriter interface {
+	Create(l *entity.PriceList) error
+}
+
+//StoreRepository price list store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//Reader interface
+type reader interface {
+	FindOneByID(id entity.ID) (*entity.PriceList, *entity.Error)
+	FindMany() ([]*entity.PriceList, *entity.Error)
+}
+
+//Writer interface
+type writer interface {
+	Create(createPriceListDTO CreatePriceListDTO) (*entity.ID, *entity.Error)
+}
+
+//UseCase use case interface
+type UseCase interface {
+	reader
+	writer
+}
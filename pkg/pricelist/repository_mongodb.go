@@ -0,0 +1,97 @@
+package pricelist
+
+import (
+	"context"
+	"log"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db *mongo.Database
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db: db,
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes unique names and lookups by currency
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("price-lists")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{primitive.E{Key: "currency", Value: 1}}},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating price lists indexes", err)
+	}
+}
+
+//FindOneByID find price list by Id
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.PriceList, error) {
+	result := entity.PriceList{}
+	coll := r.db.Collection("price-lists")
+	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindMany find all the price lists sorted by name
+func (r *MongoRepository) FindMany() ([]*entity.PriceList, error) {
+	return r.find(bson.M{})
+}
+
+//FindByCurrency find the price lists of a currency
+func (r *MongoRepository) FindByCurrency(currency string) ([]*entity.PriceList, error) {
+	return r.find(bson.M{"currency": currency})
+}
+
+func (r *MongoRepository) find(query bson.M) ([]*entity.PriceList, error) {
+	coll := r.db.Collection("price-lists")
+
+	cur, err := coll.Find(context.TODO(), query, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	lists := []*entity.PriceList{}
+	if err := cur.All(context.TODO(), &lists); err != nil {
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+//Create create new price list, returns entity.ErrAlreadyExists if the name is used
+func (r *MongoRepository) Create(l *entity.PriceList) error {
+	coll := r.db.Collection("price-lists")
+
+	_, err := coll.InsertOne(context.TODO(), l)
+	if mongodb.IsDuplicateKeyError(err) {
+		return entity.ErrAlreadyExists
+	}
+
+	return err
+}
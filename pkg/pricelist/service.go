@@ -0,0 +1,99 @@
+package pricelist
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//Service service interface
+type Service struct {
+	storeRepo StoreRepository
+}
+
+//NewService create new service
+func NewService(storeR StoreRepository) *Service {
+	return &Service{
+		storeRepo: storeR,
+	}
+}
+
+//CreatePriceListDTO new price list DTO, regions are ISO-3166 alpha-2 country codes
+type CreatePriceListDTO struct {
+	Name     string   `json:"name" validate:"required,min=2"`
+	Currency string   `json:"currency" validate:"required,len=3"`
+	Regions  []string `json:"regions,omitempty" validate:"omitempty,dive,len=2"`
+	Priority int      `json:"priority,omitempty" validate:"omitempty,min=0"`
+}
+
+//Create new price list
+func (s *Service) Create(createPriceListDTO CreatePriceListDTO) (*entity.ID, *entity.Error) {
+	if err := validator.New().Struct(createPriceListDTO); err != nil {
+		errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	if !entity.IsCurrency(createPriceListDTO.Currency) {
+		return nil, &entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Currency", Error: "Unknown currency " + createPriceListDTO.Currency}}}
+	}
+
+	var regions []string
+	for _, r := range createPriceListDTO.Regions {
+		regions = append(regions, strings.ToUpper(r))
+	}
+
+	l := &entity.PriceList{
+		ID:        entity.NewID(),
+		Name:      createPriceListDTO.Name,
+		Currency:  createPriceListDTO.Currency,
+		Regions:   regions,
+		Priority:  createPriceListDTO.Priority,
+		CreatedAt: time.Now(),
+	}
+
+	err := s.storeRepo.Create(l)
+	switch err {
+	case entity.ErrAlreadyExists:
+		return nil, &entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Name", Error: "Price list " + l.Name + " already exists"}}}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return &l.ID, nil
+}
+
+//FindOneByID price list
+func (s *Service) FindOneByID(ID entity.ID) (*entity.PriceList, *entity.Error) {
+	l, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Price list with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return l, nil
+}
+
+//FindMany all the price lists
+func (s *Service) FindMany() ([]*entity.PriceList, *entity.Error) {
+	lists, err := s.storeRepo.FindMany()
+	if err != nil {
+		return nil, &entity.Error{Op: "FindMany", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return lists, nil
+}
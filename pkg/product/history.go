@@ -0,0 +1,31 @@
+package product
+
+import "github.com/markus-azer/products-service/pkg/entity"
+
+//HistoryEntry command applied to a product with the events it produced
+//events stored before commands were linked to events have no command
+type HistoryEntry struct {
+	Command *entity.Command       `json:"command,omitempty"`
+	Events  []*entity.StoredEvent `json:"events"`
+}
+
+//history group consecutive events of the same command, in version order
+func history(events []*entity.StoredEvent, commands []*entity.Command) []*HistoryEntry {
+	byID := make(map[entity.ID]*entity.Command, len(commands))
+	for _, c := range commands {
+		byID[c.ID] = c
+	}
+
+	entries := []*HistoryEntry{}
+	for _, e := range events {
+		last := len(entries) - 1
+		if last >= 0 && e.CommandID != "" && entries[last].Events[0].CommandID == e.CommandID {
+			entries[last].Events = append(entries[last].Events, e)
+			continue
+		}
+
+		entries = append(entries, &HistoryEntry{Command: byID[e.CommandID], Events: []*entity.StoredEvent{e}})
+	}
+
+	return entries
+}
@@ -4,34 +4,28 @@ package product
 
 import "github.com/markus-azer/products-service/pkg/entity"
 
-//MessagesReader Reader interface
-type messagesReader interface {
-}
-
-//MessagesWriter product writer
-type messagesWriter interface {
-	SendMessage(m *entity.Message)
-	SendMessages(messages []*entity.Message)
-}
-
-//MessagesRepository repository interface
-type MessagesRepository interface {
-	messagesReader
-	messagesWriter
-}
-
 //StoreReader product reader interface
 type storeReader interface {
+	FindCommands(ids []entity.ID) ([]*entity.Command, error)
 	FindOneByID(id entity.ID) (*entity.Product, error)
+	FindMany(f *Filter, limit int) ([]*entity.Product, error)
+	Search(text string, f *Filter, skip int, limit int) (*SearchResult, error)
+	FindVariantsByProduct(id entity.ID) ([]*entity.Variant, error)
 }
 
 //StoreWriter product writer interface
 type storeWriter interface {
+	WithTransaction(fn func(tx StoreRepository) error) error
 	StoreCommand(c *entity.Command) (*entity.ID, error)
+	StoreMessages(commandID entity.ID, messages []*entity.Message) error
 	Create(p *entity.Product) (*entity.ID, error)
 	UpdateOne(id entity.ID, p *entity.Product, v entity.Version) (int, error)
 	UpdateOneP(id entity.ID, p *entity.UpdateProduct, v entity.Version) (int, error)
 	DeleteOne(id entity.ID, v entity.Version) (int, error)
+	UnsetBrand(id entity.ID, v entity.Version) (int, error)
+	ReplaceOne(p *entity.Product) error
+	RemoveOne(id entity.ID) error
+	RemoveAll() error
 }
 
 //StoreRepository product store repository interface
@@ -42,6 +36,11 @@ type StoreRepository interface {
 
 //Reader interface
 type reader interface {
+	FindOneByID(id entity.ID, embed []string) (*ProductDTO, *entity.Error)
+	FindOneAt(id entity.ID, findOneAtDTO FindOneAtDTO) (*entity.Product, *entity.Error)
+	History(id entity.ID) ([]*HistoryEntry, *entity.Error)
+	FindMany(listProductsDTO ListProductsDTO) ([]*entity.Product, string, *entity.Error)
+	Search(searchProductsDTO SearchProductsDTO) (*SearchResult, *entity.Error)
 }
 
 //Writer interface
@@ -49,6 +48,11 @@ type writer interface {
 	Create(createProductDTO CreateProductDTO) (*entity.ID, *entity.Version, error)
 	UpdateOne(id entity.ID, v int32, updateProductDTO UpdateProductDTO) (*int32, *entity.Error)
 	Delete(id entity.ID, version int32) *entity.Error
+	RemoveBrand(name string) *entity.Error
+	RenameBrand(from string, to string) *entity.Error
+	Rebuild(id entity.ID) (*entity.Product, *entity.Error)
+	Project(id entity.ID, events []*entity.StoredEvent) (*entity.Product, *entity.Error)
+	RemoveAll() *entity.Error
 }
 
 //UseCase use case interface
@@ -0,0 +1,91 @@
+package product
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//Filter products listing filter
+type Filter struct {
+	Brand string
+	//Categories the filtered category and its descendants
+	Categories []string
+	Seller     string
+	Status     string
+	MinPrice   int64
+	MaxPrice   int64
+	Currency   string
+	Sort       string
+	Desc       bool
+	After      *Cursor
+}
+
+//ErrInvalidCursor cursor can't be decoded or doesn't match the requested sort
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+//Cursor position of the last product returned in a sorted listing
+//the _id is used as a tie breaker so the order is stable for equal sort values
+type Cursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d"`
+	Value string    `json:"v"`
+	ID    entity.ID `json:"id"`
+}
+
+//NewCursor create a cursor pointing after the given product
+func NewCursor(p *entity.Product, sort string, desc bool) *Cursor {
+	c := &Cursor{Sort: sort, Desc: desc, ID: p.ID}
+
+	switch sort {
+	case "name":
+		c.Value = p.Name
+	case "price":
+		c.Value = strconv.FormatInt(p.Price.Amount, 10)
+	default:
+		c.Value = p.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return c
+}
+
+//SortValue typed value of the cursor sort field
+func (c *Cursor) SortValue() (interface{}, error) {
+	switch c.Sort {
+	case "name":
+		return c.Value, nil
+	case "price":
+		return strconv.ParseInt(c.Value, 10, 64)
+	default:
+		return time.Parse(time.RFC3339Nano, c.Value)
+	}
+}
+
+//Encode encode cursor as an opaque string
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//DecodeCursor decode an opaque cursor string
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := &Cursor{}
+	if err := json.Unmarshal(b, c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	if _, err := c.SortValue(); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -0,0 +1,96 @@
+package product
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//SnapshotEvent type of the event holding the product document stored before the event store
+const SnapshotEvent = "PRODUCT_SNAPSHOT_TAKEN"
+
+//NewSnapshot create the snapshot event of a product stored before the event store
+func NewSnapshot(p *entity.Product, at time.Time) *entity.StoredEvent {
+	return &entity.StoredEvent{
+		ID:            entity.NewID(),
+		AggregateType: "product",
+		AggregateID:   string(p.ID),
+		Type:          SnapshotEvent,
+		Version:       p.Version,
+		Payload:       map[string]interface{}{"product": p},
+		Timestamp:     at,
+	}
+}
+
+//Rehydrate rebuild a product by folding its events in version order
+//returns entity.ErrNotFound without events and entity.ErrAggregateDeleted if the product is deleted
+func Rehydrate(events []*entity.StoredEvent) (*entity.Product, error) {
+	if len(events) == 0 {
+		return nil, entity.ErrNotFound
+	}
+
+	p := &entity.Product{}
+
+	for i, e := range events {
+		//A snapshot seeds the aggregate stored before its events were recorded, it starts at the document version
+		if i == 0 && e.Type == SnapshotEvent {
+			if err := e.Decode("product", p); err != nil {
+				return nil, err
+			}
+			p.Version = e.Version
+			continue
+		}
+
+		if e.Version != p.Version+1 {
+			return nil, fmt.Errorf("Product %s expected version %d got %d", e.AggregateID, p.Version+1, e.Version)
+		}
+
+		if e.Type == "PRODUCT_DELETED" {
+			return nil, entity.ErrAggregateDeleted
+		}
+
+		apply(p, e)
+		p.Version = e.Version
+	}
+
+	return p, nil
+}
+
+//apply apply a single event to the product
+func apply(p *entity.Product, e *entity.StoredEvent) {
+	switch e.Type {
+	case "PRODUCT_DRAFT_CREATED":
+		p.ID = entity.ID(e.AggregateID)
+		p.Seller = e.String("seller")
+		p.Status = "unpublish"
+		p.CreatedAt = e.Timestamp
+	case "PRODUCT_NAME_UPDATED":
+		p.Name = e.String("name")
+	case "PRODUCT_DESCRIPTION_UPDATED":
+		p.Description = e.String("description")
+	case "PRODUCT_SLUG_UPDATED":
+		p.Slug = e.String("slug")
+	case "PRODUCT_Location_UPDATED":
+		p.Location = e.String("location")
+	case "PRODUCT_IMAGE_UPDATED":
+		p.Image = e.String("image")
+	case "PRODUCT_BRAND_UPDATED":
+		p.Brand = e.String("brand")
+	case "PRODUCT_BRAND_REMOVED":
+		p.Brand = ""
+	case "PRODUCT_CATEGORY_UPDATED":
+		p.Category = e.String("category")
+	case "PRODUCT_PRICE_UPDATED":
+		p.Price = e.Money("price")
+	case "PRODUCT_PUBLISHED", "PRODUCT_UNPUBLISHED":
+		p.Status = e.String("status")
+	case "PRODUCT_LOW_STOCK_THRESHOLD_UPDATED":
+		threshold := int(e.Int("lowStockThreshold"))
+		p.LowStockThreshold = &threshold
+	case "PRODUCT_OPTIONS_UPDATED":
+		p.Options = e.Options("options")
+	case "PRODUCT_TYPE_UPDATED":
+		p.Type = entity.ID(e.String("type"))
+	}
+}
@@ -0,0 +1,119 @@
+package product_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRehydrate(t *testing.T) {
+	ID := entity.NewID()
+	createdAt := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	created := &entity.StoredEvent{AggregateID: string(ID), Type: "PRODUCT_DRAFT_CREATED", Version: 1, Payload: map[string]interface{}{"seller": "seller-1"}, Timestamp: createdAt}
+	threshold := 3
+
+	tests := []struct {
+		name   string
+		events []*entity.StoredEvent
+		want   func(p *entity.Product)
+	}{
+		{"draft created", nil, func(p *entity.Product) {}},
+		{"name", []*entity.StoredEvent{{Type: "PRODUCT_NAME_UPDATED", Payload: map[string]interface{}{"name": "Shirt"}}}, func(p *entity.Product) { p.Name = "Shirt" }},
+		{"description", []*entity.StoredEvent{{Type: "PRODUCT_DESCRIPTION_UPDATED", Payload: map[string]interface{}{"description": "Cotton"}}}, func(p *entity.Product) { p.Description = "Cotton" }},
+		{"slug", []*entity.StoredEvent{{Type: "PRODUCT_SLUG_UPDATED", Payload: map[string]interface{}{"slug": "shirt"}}}, func(p *entity.Product) { p.Slug = "shirt" }},
+		{"location", []*entity.StoredEvent{{Type: "PRODUCT_Location_UPDATED", Payload: map[string]interface{}{"location": "Cairo"}}}, func(p *entity.Product) { p.Location = "Cairo" }},
+		{"image", []*entity.StoredEvent{{Type: "PRODUCT_IMAGE_UPDATED", Payload: map[string]interface{}{"image": "https://cdn/shirt.png"}}}, func(p *entity.Product) { p.Image = "https://cdn/shirt.png" }},
+		{"brand", []*entity.StoredEvent{{Type: "PRODUCT_BRAND_UPDATED", Payload: map[string]interface{}{"brand": "Acme"}}}, func(p *entity.Product) { p.Brand = "Acme" }},
+		{"brand removed", []*entity.StoredEvent{
+			{Type: "PRODUCT_BRAND_UPDATED", Payload: map[string]interface{}{"brand": "Acme"}},
+			{Type: "PRODUCT_BRAND_REMOVED"},
+		}, func(p *entity.Product) {}},
+		{"category", []*entity.StoredEvent{{Type: "PRODUCT_CATEGORY_UPDATED", Payload: map[string]interface{}{"category": "shirts"}}}, func(p *entity.Product) { p.Category = "shirts" }},
+		{"price", []*entity.StoredEvent{{Type: "PRODUCT_PRICE_UPDATED", Payload: map[string]interface{}{"price": primitive.M{"amount": int64(2500), "currency": "EUR"}}}}, func(p *entity.Product) {
+			p.Price = &entity.Money{Amount: 2500, Currency: "EUR"}
+		}},
+		{"published", []*entity.StoredEvent{{Type: "PRODUCT_PUBLISHED", Payload: map[string]interface{}{"status": "publish"}}}, func(p *entity.Product) { p.Status = "publish" }},
+		{"unpublished", []*entity.StoredEvent{
+			{Type: "PRODUCT_PUBLISHED", Payload: map[string]interface{}{"status": "publish"}},
+			{Type: "PRODUCT_UNPUBLISHED", Payload: map[string]interface{}{"status": "unpublish"}},
+		}, func(p *entity.Product) {}},
+		{"low stock threshold", []*entity.StoredEvent{{Type: "PRODUCT_LOW_STOCK_THRESHOLD_UPDATED", Payload: map[string]interface{}{"lowStockThreshold": float64(3)}}}, func(p *entity.Product) {
+			p.LowStockThreshold = &threshold
+		}},
+		{"options", []*entity.StoredEvent{{Type: "PRODUCT_OPTIONS_UPDATED", Payload: map[string]interface{}{"options": primitive.A{primitive.M{"name": "Size", "values": primitive.A{"S", "M"}}}}}}, func(p *entity.Product) {
+			p.Options = []*entity.Option{{Name: "Size", Values: []string{"S", "M"}}}
+		}},
+		{"type", []*entity.StoredEvent{{Type: "PRODUCT_TYPE_UPDATED", Payload: map[string]interface{}{"type": "apparel"}}}, func(p *entity.Product) { p.Type = "apparel" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []*entity.StoredEvent{created}
+			for i, e := range tt.events {
+				e.AggregateID = string(ID)
+				e.Version = entity.Version(i + 2)
+				events = append(events, e)
+			}
+
+			want := &entity.Product{ID: ID, Version: entity.Version(len(events)), Seller: "seller-1", Status: "unpublish", CreatedAt: createdAt}
+			tt.want(want)
+
+			p, err := product.Rehydrate(events)
+
+			assert.Nil(t, err)
+			assert.Equal(t, want, p)
+		})
+	}
+}
+
+func TestRehydrateSnapshot(t *testing.T) {
+	ID := entity.NewID()
+	createdAt := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	stored := &entity.Product{ID: ID, Version: 4, Name: "Shirt", Seller: "seller-1", Status: "publish", Category: "shirts", Price: &entity.Money{Amount: 2500, Currency: "EUR"}, CreatedAt: createdAt}
+
+	//Snapshots are read back from the store as documents
+	b, err := bson.Marshal(stored)
+	assert.Nil(t, err)
+	var document primitive.D
+	assert.Nil(t, bson.Unmarshal(b, &document))
+
+	snapshot := product.NewSnapshot(stored, createdAt.Add(time.Hour))
+	snapshot.Payload["product"] = document
+
+	p, err := product.Rehydrate([]*entity.StoredEvent{
+		snapshot,
+		{AggregateID: string(ID), Type: "PRODUCT_NAME_UPDATED", Version: 5, Payload: map[string]interface{}{"name": "Cotton Shirt"}},
+	})
+
+	want := *stored
+	want.Version = 5
+	want.Name = "Cotton Shirt"
+
+	assert.Nil(t, err)
+	assert.Equal(t, &want, p)
+}
+
+func TestRehydrateErrors(t *testing.T) {
+	ID := string(entity.NewID())
+
+	_, err := product.Rehydrate(nil)
+	assert.Equal(t, entity.ErrNotFound, err)
+
+	_, err = product.Rehydrate([]*entity.StoredEvent{
+		{AggregateID: ID, Type: "PRODUCT_DRAFT_CREATED", Version: 1},
+		{AggregateID: ID, Type: "PRODUCT_DELETED", Version: 2},
+	})
+	assert.Equal(t, entity.ErrAggregateDeleted, err)
+
+	// A missing version can't be folded over
+	_, err = product.Rehydrate([]*entity.StoredEvent{
+		{AggregateID: ID, Type: "PRODUCT_DRAFT_CREATED", Version: 1},
+		{AggregateID: ID, Type: "PRODUCT_NAME_UPDATED", Version: 3},
+	})
+	assert.NotNil(t, err)
+}
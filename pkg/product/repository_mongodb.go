@@ -2,32 +2,92 @@ package product
 
 import (
 	"context"
+	"log"
 
 	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/outbox"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //MongoRepository mongodb repo
 type MongoRepository struct {
-	db *mongo.Database
+	db     *mongo.Database
+	ctx    context.Context
+	events eventstore.StoreRepository
+	outbox outbox.StoreRepository
 }
 
 //NewMongoRepository create new repository
-func NewMongoRepository(db *mongo.Database) StoreRepository {
-	return &MongoRepository{
-		db: db,
+func NewMongoRepository(db *mongo.Database, events eventstore.StoreRepository, outbox outbox.StoreRepository) StoreRepository {
+	r := &MongoRepository{
+		db:     db,
+		ctx:    context.TODO(),
+		events: events,
+		outbox: outbox,
 	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes indexes supporting the products listing filters and sorts
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("products")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "createdAt", Value: -1}, primitive.E{Key: "_id", Value: -1}}},
+		{Keys: bson.D{primitive.E{Key: "name", Value: 1}, primitive.E{Key: "_id", Value: 1}}},
+		{Keys: bson.D{primitive.E{Key: "price.currency", Value: 1}, primitive.E{Key: "price.amount", Value: 1}, primitive.E{Key: "_id", Value: 1}}},
+	}
+
+	//Weighted text index used by the products search
+	models = append(models, mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "name", Value: "text"}, primitive.E{Key: "slug", Value: "text"}, primitive.E{Key: "description", Value: "text"}},
+		Options: options.Index().SetName("products_text").SetWeights(bson.M{"name": 10, "slug": 5, "description": 1}),
+	})
+
+	for _, field := range []string{"brand", "category", "seller", "status"} {
+		models = append(models,
+			mongo.IndexModel{Keys: bson.D{primitive.E{Key: field, Value: 1}, primitive.E{Key: "createdAt", Value: -1}, primitive.E{Key: "_id", Value: -1}}},
+			mongo.IndexModel{Keys: bson.D{primitive.E{Key: field, Value: 1}, primitive.E{Key: "price.currency", Value: 1}, primitive.E{Key: "price.amount", Value: 1}, primitive.E{Key: "_id", Value: 1}}},
+		)
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating products indexes", err)
+	}
+}
+
+//WithTransaction run fn in a transaction, the repository passed to fn is bound to the transaction session
+func (r *MongoRepository) WithTransaction(fn func(tx StoreRepository) error) error {
+	session, err := r.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(r.ctx)
+
+	_, err = session.WithTransaction(r.ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&MongoRepository{db: r.db, ctx: sessCtx, events: r.events.WithContext(sessCtx), outbox: r.outbox.WithContext(sessCtx)})
+	})
+
+	return err
 }
 
-//TODO: USE Sessions
+//StoreMessages append messages produced by a command to the event store and persistence them in the outbox,
+//they are published to kafka by the outbox relay
+func (r *MongoRepository) StoreMessages(commandID entity.ID, messages []*entity.Message) error {
+	return outbox.StoreMessages(r.events, r.outbox, "product", "products", commandID, messages)
+}
 
 //FindOneByID find product by Id
 func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Product, error) {
 	result := entity.Product{}
 	coll := r.db.Collection("products")
-	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+	err := coll.FindOne(r.ctx, bson.M{"_id": id}).Decode(&result)
 
 	switch err {
 	case nil:
@@ -39,27 +99,220 @@ func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Product, error) {
 	}
 }
 
-//StoreCommand persistence commands
+//filterQuery build the mongo query matching the filter fields
+func filterQuery(f *Filter) bson.M {
+	query := bson.M{}
+	if f.Brand != "" {
+		query["brand"] = f.Brand
+	}
+	if len(f.Categories) > 0 {
+		query["category"] = bson.M{"$in": f.Categories}
+	}
+	if f.Seller != "" {
+		query["seller"] = f.Seller
+	}
+	if f.Status != "" {
+		query["status"] = f.Status
+	}
+
+	if f.Currency != "" {
+		query["price.currency"] = f.Currency
+	}
+
+	price := bson.M{}
+	if f.MinPrice != 0 {
+		price["$gte"] = f.MinPrice
+	}
+	if f.MaxPrice != 0 {
+		price["$lte"] = f.MaxPrice
+	}
+	if len(price) > 0 {
+		query["price.amount"] = price
+	}
+
+	return query
+}
+
+//FindMany find products matching the filter, sorted by filter.Sort then _id
+func (r *MongoRepository) FindMany(f *Filter, limit int) ([]*entity.Product, error) {
+	coll := r.db.Collection("products")
+
+	query := filterQuery(f)
+
+	//Unpriced products can't be positioned by a price cursor, leave them out of price sorted listings
+	sort := f.Sort
+	if f.Sort == "price" {
+		sort = "price.amount"
+		price, _ := query[sort].(bson.M)
+		if price == nil {
+			price = bson.M{}
+		}
+		price["$exists"] = true
+		query[sort] = price
+	}
+
+	order, op := 1, "$gt"
+	if f.Desc {
+		order, op = -1, "$lt"
+	}
+
+	if f.After != nil {
+		value, err := f.After.SortValue()
+		if err != nil {
+			return nil, err
+		}
+
+		query["$or"] = bson.A{
+			bson.M{sort: bson.M{op: value}},
+			bson.M{sort: value, "_id": bson.M{op: f.After.ID}},
+		}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: sort, Value: order}, primitive.E{Key: "_id", Value: order}}).
+		SetLimit(int64(limit))
+
+	cur, err := coll.Find(r.ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	products := []*entity.Product{}
+	if err := cur.All(r.ctx, &products); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+//Search full text search products matching the filter, ordered by relevance
+//facets are computed over all the matching products not only the returned page
+func (r *MongoRepository) Search(text string, f *Filter, skip int, limit int) (*SearchResult, error) {
+	coll := r.db.Collection("products")
+
+	match := filterQuery(f)
+	match["$text"] = bson.M{"$search": text}
+
+	//Lower bound of the price bucket, highest first so the top bucket takes every price above its bound
+	branches := bson.A{}
+	for i := len(priceBuckets) - 1; i >= 0; i-- {
+		branches = append(branches, bson.M{"case": bson.M{"$gte": bson.A{"$price.amount", priceBuckets[i]}}, "then": priceBuckets[i]})
+	}
+
+	pipeline := mongo.Pipeline{
+		{primitive.E{Key: "$match", Value: match}},
+		{primitive.E{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{primitive.E{Key: "$facet", Value: bson.M{
+			"products": bson.A{
+				bson.M{"$sort": bson.D{primitive.E{Key: "score", Value: -1}, primitive.E{Key: "_id", Value: 1}}},
+				bson.M{"$skip": skip},
+				bson.M{"$limit": limit},
+			},
+			"total":      bson.A{bson.M{"$count": "count"}},
+			"brands":     bson.A{bson.M{"$match": bson.M{"brand": bson.M{"$exists": true}}}, bson.M{"$sortByCount": "$brand"}},
+			"categories": bson.A{bson.M{"$match": bson.M{"category": bson.M{"$exists": true}}}, bson.M{"$sortByCount": "$category"}},
+			//Bucketed per currency, amounts of different currencies don't add up
+			"prices": bson.A{
+				//Unpriced products are left out rather than counted as free
+				bson.M{"$match": bson.M{"price.amount": bson.M{"$type": "number"}}},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"currency": "$price.currency", "min": bson.M{"$switch": bson.M{"branches": branches, "default": priceBuckets[0]}}},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$project": bson.M{"_id": 0, "currency": "$_id.currency", "min": "$_id.min", "count": 1}},
+				bson.M{"$sort": bson.D{primitive.E{Key: "currency", Value: 1}, primitive.E{Key: "min", Value: 1}}},
+			},
+		}}},
+	}
+
+	cur, err := coll.Aggregate(r.ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	var agg []struct {
+		Products   []*SearchHit  `bson:"products"`
+		Total      []FacetCount  `bson:"total"`
+		Brands     []FacetCount  `bson:"brands"`
+		Categories []FacetCount  `bson:"categories"`
+		Prices     []PriceBucket `bson:"prices"`
+	}
+	if err := cur.All(r.ctx, &agg); err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{Hits: []*SearchHit{}}
+	if len(agg) == 0 {
+		return result, nil
+	}
+
+	result.Hits = agg[0].Products
+	result.Facets = Facets{Brands: agg[0].Brands, Categories: agg[0].Categories, Prices: agg[0].Prices}
+	if len(agg[0].Total) > 0 {
+		result.Total = agg[0].Total[0].Count
+	}
+
+	return result, nil
+}
+
+//FindVariantsByProduct find all variants of a product
+func (r *MongoRepository) FindVariantsByProduct(id entity.ID) ([]*entity.Variant, error) {
+	coll := r.db.Collection("variants")
+
+	cur, err := coll.Find(r.ctx, bson.M{"product": id})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	variants := []*entity.Variant{}
+	if err := cur.All(r.ctx, &variants); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+//StoreCommand persistence commands, returns the command id
 func (r *MongoRepository) StoreCommand(c *entity.Command) (*entity.ID, error) {
 	coll := r.db.Collection("commands-product")
 
-	result, err := coll.InsertOne(context.TODO(), c)
+	if c.ID == "" {
+		c.ID = entity.NewID()
+	}
+
+	if _, err := coll.InsertOne(r.ctx, c); err != nil {
+		return nil, err
+	}
+
+	return &c.ID, nil
+}
+
+//FindCommands find commands by id
+func (r *MongoRepository) FindCommands(ids []entity.ID) ([]*entity.Command, error) {
+	coll := r.db.Collection("commands-product")
 
+	cur, err := coll.Find(r.ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(r.ctx)
 
-	str := result.InsertedID.(primitive.ObjectID).String()
-	id := entity.ID(str)
+	commands := []*entity.Command{}
+	if err := cur.All(r.ctx, &commands); err != nil {
+		return nil, err
+	}
 
-	return &id, nil
+	return commands, nil
 }
 
 //Create create new Product
 func (r *MongoRepository) Create(p *entity.Product) (*entity.ID, error) {
 	coll := r.db.Collection("products")
 
-	result, err := coll.InsertOne(context.TODO(), p)
+	result, err := coll.InsertOne(r.ctx, p)
 
 	if err != nil {
 		return nil, err
@@ -77,7 +330,7 @@ func (r *MongoRepository) UpdateOne(id entity.ID, p *entity.Product, v entity.Ve
 	coll := r.db.Collection("products")
 
 	result, err := coll.UpdateOne(
-		context.TODO(),
+		r.ctx,
 		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: v}},
 		bson.D{primitive.E{Key: "$set", Value: p}},
 	)
@@ -94,7 +347,7 @@ func (r *MongoRepository) UpdateOneP(id entity.ID, p *entity.UpdateProduct, v en
 	coll := r.db.Collection("products")
 
 	result, err := coll.UpdateOne(
-		context.TODO(),
+		r.ctx,
 		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: v}},
 		bson.D{primitive.E{Key: "$set", Value: p}},
 	)
@@ -106,12 +359,29 @@ func (r *MongoRepository) UpdateOneP(id entity.ID, p *entity.UpdateProduct, v en
 	return int(result.ModifiedCount), nil
 }
 
+//UnsetBrand remove the brand of an existing product and bump its version
+func (r *MongoRepository) UnsetBrand(id entity.ID, v entity.Version) (int, error) {
+	coll := r.db.Collection("products")
+
+	result, err := coll.UpdateOne(
+		r.ctx,
+		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: v}},
+		bson.D{primitive.E{Key: "$unset", Value: bson.M{"brand": ""}}, primitive.E{Key: "$set", Value: bson.M{"_V": v + 1}}},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
 //DeleteOne update an existing Product
 func (r *MongoRepository) DeleteOne(id entity.ID, v entity.Version) (int, error) {
 	coll := r.db.Collection("products")
 
 	result, err := coll.DeleteOne(
-		context.TODO(),
+		r.ctx,
 		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: v}},
 	)
 
@@ -122,3 +392,30 @@ func (r *MongoRepository) DeleteOne(id entity.ID, v entity.Version) (int, error)
 	return int(result.DeletedCount), nil
 
 }
+
+//ReplaceOne replace the stored product with a rebuilt one, inserts it if missing
+func (r *MongoRepository) ReplaceOne(p *entity.Product) error {
+	coll := r.db.Collection("products")
+
+	_, err := coll.ReplaceOne(r.ctx, bson.M{"_id": p.ID}, p, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+//RemoveOne remove the stored product whatever its version
+func (r *MongoRepository) RemoveOne(id entity.ID) error {
+	coll := r.db.Collection("products")
+
+	_, err := coll.DeleteOne(r.ctx, bson.M{"_id": id})
+
+	return err
+}
+
+//RemoveAll remove all the stored products
+func (r *MongoRepository) RemoveAll() error {
+	coll := r.db.Collection("products")
+
+	_, err := coll.DeleteMany(r.ctx, bson.M{})
+
+	return err
+}
@@ -0,0 +1,95 @@
+package product
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//priceBuckets lower bounds in minor units of the price facet buckets, the last bucket has no upper bound
+var priceBuckets = []int64{0, 2500, 5000, 10000, 25000, 50000, 100000}
+
+//SearchHit product matching a search with its relevance score
+type SearchHit struct {
+	entity.Product `bson:",inline"`
+	Score          float64           `json:"score" bson:"score"`
+	Highlights     map[string]string `json:"highlights,omitempty" bson:"-"`
+}
+
+//FacetCount number of matching products sharing a value
+type FacetCount struct {
+	Value string `json:"value" bson:"_id"`
+	Count int    `json:"count" bson:"count"`
+}
+
+//PriceBucket number of matching products priced in Currency in a price range [Min, Max), Max is 0 for the open-ended top bucket
+type PriceBucket struct {
+	Currency string `json:"currency" bson:"currency"`
+	Min      int64  `json:"min" bson:"min"`
+	Max      int64  `json:"max,omitempty" bson:"-"`
+	Count    int    `json:"count" bson:"count"`
+}
+
+//Facets search facet counts
+type Facets struct {
+	Brands     []FacetCount  `json:"brands"`
+	Categories []FacetCount  `json:"categories"`
+	Prices     []PriceBucket `json:"prices"`
+}
+
+//SearchResult page of search hits with the facets of all matching products
+type SearchResult struct {
+	Hits   []*SearchHit `json:"products"`
+	Facets Facets       `json:"facets"`
+	Total  int          `json:"total"`
+}
+
+//searchTerms terms of a text search query, negated terms are left out
+func searchTerms(query string) []string {
+	var terms []string
+
+	for _, t := range strings.Fields(query) {
+		if strings.HasPrefix(t, "-") {
+			continue
+		}
+
+		t = strings.Trim(t, `"`)
+		if t != "" {
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
+
+//highlight wrap the terms found in text with <em></em>, returns "" if none is found
+//The text is HTML escaped, the highlights are safe to render as HTML
+func highlight(text string, terms []string) string {
+	if text == "" || len(terms) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	matches := re.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		b.WriteString("<em>" + html.EscapeString(text[m[0]:m[1]]) + "</em>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
@@ -2,13 +2,19 @@ package product
 
 import (
 	"fmt"
+	"log"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/fatih/structs"
 	"github.com/go-playground/validator"
 	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/category"
 	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/markus-azer/products-service/pkg/producttype"
 	"github.com/sirupsen/logrus"
 )
 
@@ -30,31 +36,392 @@ import (
 
 //Service service interface
 type Service struct {
-	msgRepo   MessagesRepository
-	storeRepo StoreRepository
-	brandRepo brand.StoreRepository
+	storeRepo    StoreRepository
+	brandRepo    brand.StoreRepository
+	categoryRepo category.StoreRepository
+	typeRepo     producttype.StoreRepository
+	eventRepo    eventstore.StoreRepository
+	outbox       outbox.UseCase
 }
 
 //NewService create new service
-func NewService(msgR MessagesRepository, storeR StoreRepository, brandR brand.StoreRepository) *Service {
+func NewService(storeR StoreRepository, brandR brand.StoreRepository, categoryR category.StoreRepository, typeR producttype.StoreRepository, eventR eventstore.StoreRepository, outboxU outbox.UseCase) *Service {
 	return &Service{
-		msgRepo:   msgR,
-		storeRepo: storeR,
-		brandRepo: brandR,
+		storeRepo:    storeR,
+		brandRepo:    brandR,
+		categoryRepo: categoryR,
+		typeRepo:     typeR,
+		eventRepo:    eventR,
+		outbox:       outboxU,
 	}
 }
 
+//ProductDTO product read DTO with the optional embedded resources
+type ProductDTO struct {
+	*entity.Product
+	Variants     []*entity.Variant `json:"variants,omitempty"`
+	BrandDetails *entity.Brand     `json:"brandDetails,omitempty"`
+}
+
+//FindOneByID find product by id, embed accepts "variants" and "brand"
+func (s *Service) FindOneByID(ID entity.ID, embed []string) (*ProductDTO, *entity.Error) {
+	var errs []entity.ErrorField
+	embedVariants, embedBrand := false, false
+
+	for _, e := range embed {
+		switch e {
+		case "variants":
+			embedVariants = true
+		case "brand":
+			embedBrand = true
+		default:
+			errs = append(errs, entity.ErrorField{Field: "embed", Error: "Unknown embed " + e})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: errs}
+	}
+
+	p, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	dto := &ProductDTO{Product: p}
+
+	if embedVariants {
+		variants, err := s.storeRepo.FindVariantsByProduct(ID)
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		now := time.Now()
+		for _, v := range variants {
+			v.SetSalePrice(now)
+		}
+		dto.Variants = variants
+	}
+
+	if embedBrand && p.Brand != "" {
+		b, err := s.brandRepo.FindOneByName(p.Brand)
+		switch err {
+		case nil:
+			dto.BrandDetails = b
+		case entity.ErrNotFound:
+			//Brand replica may lag behind the brands service, return the product without it
+		default:
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return dto, nil
+}
+
+//FindOneAtDTO point in time of a product read, either a version or a timestamp
+type FindOneAtDTO struct {
+	Version int32     `validate:"omitempty,min=1"`
+	AsOf    time.Time `validate:"required_without=Version"`
+}
+
+//FindOneAt find the product as it was at a version or at a point in time, rebuilt from its events
+func (s *Service) FindOneAt(ID entity.ID, findOneAtDTO FindOneAtDTO) (*entity.Product, *entity.Error) {
+	if err := validator.New().Struct(findOneAtDTO); err != nil {
+		var errs []entity.ErrorField
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs = append(errs, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: errs}
+	}
+
+	if findOneAtDTO.Version != 0 && !findOneAtDTO.AsOf.IsZero() {
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "AsOf", Error: "Provide either a version or a timestamp"}}}
+	}
+
+	events, err := s.eventRepo.FindByAggregate(string(ID))
+	if err != nil {
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	if len(events) == 0 {
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	}
+
+	//Events are ordered by version, keep the ones up to the requested point
+	n := 0
+	for _, e := range events {
+		if findOneAtDTO.Version != 0 && e.Version > entity.Version(findOneAtDTO.Version) {
+			break
+		}
+		if !findOneAtDTO.AsOf.IsZero() && e.Timestamp.After(findOneAtDTO.AsOf) {
+			break
+		}
+		n++
+	}
+
+	if findOneAtDTO.Version != 0 && entity.Version(findOneAtDTO.Version) > events[len(events)-1].Version {
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage(fmt.Sprintf("Product with id %s has no version %d", ID, findOneAtDTO.Version)), Severity: logrus.InfoLevel}
+	}
+
+	if n == 0 && events[0].Type == SnapshotEvent {
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " has no history before " + events[0].Timestamp.Format(time.RFC3339)), Severity: logrus.InfoLevel}
+	}
+
+	p, err := Rehydrate(events[:n])
+	switch err {
+	case nil:
+		return p, nil
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " didn't exist at " + findOneAtDTO.AsOf.Format(time.RFC3339)), Severity: logrus.InfoLevel}
+	case entity.ErrAggregateDeleted:
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " was deleted"), Severity: logrus.InfoLevel}
+	default:
+		return nil, &entity.Error{Op: "FindOneAt", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+}
+
+//History list the events of a product in version order, grouped by the command that produced them
+func (s *Service) History(ID entity.ID) ([]*HistoryEntry, *entity.Error) {
+	events, err := s.eventRepo.FindByAggregate(string(ID))
+	if err != nil {
+		return nil, &entity.Error{Op: "History", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	if len(events) == 0 {
+		return nil, &entity.Error{Op: "History", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	}
+
+	var ids []entity.ID
+	seen := make(map[entity.ID]bool)
+	for _, e := range events {
+		if e.CommandID != "" && !seen[e.CommandID] {
+			seen[e.CommandID] = true
+			ids = append(ids, e.CommandID)
+		}
+	}
+
+	var commands []*entity.Command
+	if len(ids) > 0 {
+		commands, err = s.storeRepo.FindCommands(ids)
+		if err != nil {
+			return nil, &entity.Error{Op: "History", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return history(events, commands), nil
+}
+
+//ListProductsDTO list products query DTO
+type ListProductsDTO struct {
+	Brand    string `validate:"omitempty"`
+	Category string `validate:"omitempty"`
+	Seller   string `validate:"omitempty"`
+	Status   string `validate:"omitempty,oneof=publish unpublish"`
+	MinPrice int64  `validate:"omitempty,min=1"`
+	MaxPrice int64  `validate:"omitempty,min=1,gtefield=MinPrice"`
+	Currency string `validate:"required_with=MinPrice MaxPrice,omitempty,len=3"`
+	Sort     string `validate:"omitempty,oneof=createdAt name price"`
+	Order    string `validate:"omitempty,oneof=asc desc"`
+	Limit    int    `validate:"omitempty,min=1,max=100"`
+	Cursor   string `validate:"omitempty"`
+}
+
+//FindMany list products page by page, returns the cursor of the next page if any
+func (s *Service) FindMany(listProductsDTO ListProductsDTO) ([]*entity.Product, string, *entity.Error) {
+	if err := validator.New().Struct(listProductsDTO); err != nil {
+		var errs []entity.ErrorField
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs = append(errs, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, "", &entity.Error{Op: "FindMany", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: errs}
+	}
+
+	categories, e := s.categoryTree("FindMany", listProductsDTO.Category)
+	if e != nil {
+		return nil, "", e
+	}
+
+	f := &Filter{
+		Brand:      listProductsDTO.Brand,
+		Categories: categories,
+		Seller:     listProductsDTO.Seller,
+		Status:     listProductsDTO.Status,
+		MinPrice:   listProductsDTO.MinPrice,
+		MaxPrice:   listProductsDTO.MaxPrice,
+		Currency:   listProductsDTO.Currency,
+		Sort:       listProductsDTO.Sort,
+		Desc:       listProductsDTO.Order == "desc",
+	}
+
+	//Amounts of different currencies can't be compared
+	if f.Sort == "price" && f.Currency == "" {
+		return nil, "", &entity.Error{Op: "FindMany", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Currency", Error: "Currency is required to sort by price"}}}
+	}
+
+	// Newest first by default
+	if f.Sort == "" {
+		f.Sort = "createdAt"
+		f.Desc = listProductsDTO.Order != "asc"
+	}
+
+	limit := listProductsDTO.Limit
+	if limit == 0 {
+		limit = 20
+	}
+
+	if listProductsDTO.Cursor != "" {
+		c, err := DecodeCursor(listProductsDTO.Cursor)
+		if err != nil || c.Sort != f.Sort || c.Desc != f.Desc {
+			return nil, "", &entity.Error{Op: "FindMany", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Cursor", Error: "Invalid cursor"}}}
+		}
+		f.After = c
+	}
+
+	// Fetch one extra product to know if there is a next page
+	products, err := s.storeRepo.FindMany(f, limit+1)
+	if err != nil {
+		return nil, "", &entity.Error{Op: "FindMany", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	next := ""
+	if len(products) > limit {
+		products = products[:limit]
+		next = NewCursor(products[limit-1], f.Sort, f.Desc).Encode()
+	}
+
+	return products, next, nil
+}
+
+//categoryTree ids of the category and of its descendants, the products of a subcategory are in its parent category
+//an unknown category is kept as is so it matches no product
+func (s *Service) categoryTree(op string, id string) ([]string, *entity.Error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	c, err := s.categoryRepo.FindOneByID(entity.ID(id))
+	switch err {
+	case nil:
+	case entity.ErrNotFound:
+		return []string{id}, nil
+	default:
+		return nil, &entity.Error{Op: entity.Op(op), Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	descendants, err := s.categoryRepo.FindDescendants(c.Path)
+	if err != nil {
+		return nil, &entity.Error{Op: entity.Op(op), Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	ids := []string{string(c.ID)}
+	for _, d := range descendants {
+		ids = append(ids, string(d.ID))
+	}
+
+	return ids, nil
+}
+
+//SearchProductsDTO search products query DTO
+type SearchProductsDTO struct {
+	Query    string `validate:"required,min=2"`
+	Brand    string `validate:"omitempty"`
+	Category string `validate:"omitempty"`
+	Seller   string `validate:"omitempty"`
+	Status   string `validate:"omitempty,oneof=publish unpublish"`
+	MinPrice int64  `validate:"omitempty,min=1"`
+	MaxPrice int64  `validate:"omitempty,min=1,gtefield=MinPrice"`
+	Currency string `validate:"required_with=MinPrice MaxPrice,omitempty,len=3"`
+	Page     int    `validate:"omitempty,min=1"`
+	Limit    int    `validate:"omitempty,min=1,max=100"`
+}
+
+//Search full text search products by name, description and slug
+func (s *Service) Search(searchProductsDTO SearchProductsDTO) (*SearchResult, *entity.Error) {
+	if err := validator.New().Struct(searchProductsDTO); err != nil {
+		var errs []entity.ErrorField
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs = append(errs, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &entity.Error{Op: "Search", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel, Errors: errs}
+	}
+
+	categories, e := s.categoryTree("Search", searchProductsDTO.Category)
+	if e != nil {
+		return nil, e
+	}
+
+	f := &Filter{
+		Brand:      searchProductsDTO.Brand,
+		Categories: categories,
+		Seller:     searchProductsDTO.Seller,
+		Status:     searchProductsDTO.Status,
+		MinPrice:   searchProductsDTO.MinPrice,
+		MaxPrice:   searchProductsDTO.MaxPrice,
+		Currency:   searchProductsDTO.Currency,
+	}
+
+	page, limit := searchProductsDTO.Page, searchProductsDTO.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	result, err := s.storeRepo.Search(searchProductsDTO.Query, f, (page-1)*limit, limit)
+	if err != nil {
+		return nil, &entity.Error{Op: "Search", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	terms := searchTerms(searchProductsDTO.Query)
+	for _, hit := range result.Hits {
+		hit.Highlights = make(map[string]string)
+		for field, text := range map[string]string{"name": hit.Name, "description": hit.Description, "slug": hit.Slug} {
+			if h := highlight(text, terms); h != "" {
+				hit.Highlights[field] = h
+			}
+		}
+	}
+
+	for i := range result.Facets.Prices {
+		for j, b := range priceBuckets[:len(priceBuckets)-1] {
+			if result.Facets.Prices[i].Min == b {
+				result.Facets.Prices[i].Max = priceBuckets[j+1]
+			}
+		}
+	}
+
+	return result, nil
+}
+
 //CreateProductDTO new product DTO
 type CreateProductDTO struct {
-	Name        string `json:"name" validate:"required,min=3" structs:"name,omitempty"`
-	Description string `json:"description,omitempty" validate:"omitempty,min=20" structs:"description,omitempty"`
-	Slug        string `json:"slug,omitempty" validate:"omitempty" structs:"slug,omitempty"` //TODO: find slug validation
-	Location    string `json:"location,omitempty" validate:"omitempty" structs:"location,omitempty"`
-	Image       string `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
-	Brand       string `json:"brand,omitempty" validate:"omitempty" structs:"brand,omitempty"`
-	Category    string `json:"category,omitempty" validate:"omitempty" structs:"category,omitempty"`
-	Price       int8   `json:"price,omitempty" validate:"omitempty,min=1" structs:"price,omitempty"`
-	Seller      string `json:"seller,omitempty" validate:"required" structs:"seller,omitempty"`
+	Name        string        `json:"name" validate:"required,min=3" structs:"name,omitempty"`
+	Description string        `json:"description,omitempty" validate:"omitempty,min=20" structs:"description,omitempty"`
+	Slug        string        `json:"slug,omitempty" validate:"omitempty" structs:"slug,omitempty"` //TODO: find slug validation
+	Location    string        `json:"location,omitempty" validate:"omitempty" structs:"location,omitempty"`
+	Image       string        `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
+	Brand       string        `json:"brand,omitempty" validate:"omitempty" structs:"brand,omitempty"`
+	Category    string        `json:"category,omitempty" validate:"omitempty" structs:"category,omitempty"`
+	Price       *entity.Money `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
+	Seller      string        `json:"seller,omitempty" validate:"required" structs:"seller,omitempty"`
+	//LowStockThreshold override the global variants low stock threshold
+	LowStockThreshold *int `json:"lowStockThreshold,omitempty" validate:"omitempty,min=0" structs:"lowStockThreshold,omitempty"`
+	//Options options the variants matrix is generated from, up to 3 as variant attributes
+	Options []*entity.Option `json:"options,omitempty" validate:"omitempty,max=3,dive" structs:"options,omitempty"`
+	//Type product type declaring the attributes of the variants
+	Type string `json:"type,omitempty" validate:"omitempty" structs:"type,omitempty"`
 }
 
 //Create new product
@@ -198,6 +565,16 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 			if value.String() != "" {
 				version++
 
+				_, err := s.categoryRepo.FindOneByID(entity.ID(value.String()))
+				switch err {
+				case entity.ErrNotFound:
+					errs = append(errs, entity.ErrorField{Field: value.String(), Error: "Category Not found"})
+				default:
+					if err != nil {
+						return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Service Error", Severity: logrus.ErrorLevel, Err: err}
+					}
+				}
+
 				payload := make(map[string]interface{})
 				payload["category"] = value.String()
 
@@ -209,11 +586,15 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 					Timestamp: Timestamp})
 			}
 		case "Price":
-			if value.Int() != 0 {
+			if createProductDTO.Price != nil {
 				version++
 
+				if !entity.IsCurrency(createProductDTO.Price.Currency) {
+					errs = append(errs, entity.ErrorField{Field: "Currency", Error: "Unknown currency " + createProductDTO.Price.Currency})
+				}
+
 				payload := make(map[string]interface{})
-				payload["price"] = value.Int()
+				payload["price"] = createProductDTO.Price
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
@@ -222,6 +603,36 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
+		case "LowStockThreshold":
+			if createProductDTO.LowStockThreshold != nil {
+				version++
+
+				messages = append(messages, lowStockThresholdMessage(ID, version, *createProductDTO.LowStockThreshold, Timestamp))
+			}
+		case "Options":
+			if createProductDTO.Options != nil {
+				version++
+
+				errs = append(errs, normalizeOptions(createProductDTO.Options)...)
+
+				messages = append(messages, optionsMessage(ID, version, createProductDTO.Options, Timestamp))
+			}
+		case "Type":
+			if value.String() != "" {
+				version++
+
+				_, err := s.typeRepo.FindOneByID(entity.ID(value.String()))
+				switch err {
+				case entity.ErrNotFound:
+					errs = append(errs, entity.ErrorField{Field: "Type", Error: "Product type " + value.String() + " Not found"})
+				default:
+					if err != nil {
+						return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Service Error", Severity: logrus.ErrorLevel, Err: err}
+					}
+				}
+
+				messages = append(messages, typeMessage(ID, version, value.String(), Timestamp))
+			}
 		}
 	}
 
@@ -230,17 +641,22 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 	}
 
 	p := &entity.Product{
-		ID:          ID,
-		Version:     version,
-		Name:        createProductDTO.Name,
-		Description: createProductDTO.Description,
-		Slug:        createProductDTO.Slug,
-		Image:       createProductDTO.Image,
-		Brand:       createProductDTO.Brand,
-		Category:    createProductDTO.Category,
-		Price:       createProductDTO.Price,
-		Status:      "unpublish", // Init the product as unpublish
-		CreatedAt:   Timestamp,
+		ID:                ID,
+		Version:           version,
+		Name:              createProductDTO.Name,
+		Description:       createProductDTO.Description,
+		Slug:              createProductDTO.Slug,
+		Location:          createProductDTO.Location,
+		Image:             createProductDTO.Image,
+		Brand:             createProductDTO.Brand,
+		Category:          createProductDTO.Category,
+		Price:             createProductDTO.Price,
+		Status:            "unpublish", // Init the product as unpublish
+		Seller:            createProductDTO.Seller,
+		CreatedAt:         Timestamp,
+		LowStockThreshold: createProductDTO.LowStockThreshold,
+		Options:           createProductDTO.Options,
+		Type:              entity.ID(createProductDTO.Type),
 	}
 
 	// data, err := json.Marshal(p)
@@ -253,17 +669,27 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 	// err = json.Unmarshal(data, &newMap) // Convert to a map
 
 	c := &entity.Command{AggregateID: string(ID), Type: "CreateProduct", Payload: structs.Map(createProductDTO), Timestamp: Timestamp}
-	_, err := s.storeRepo.StoreCommand(c)
-	if err != nil {
-		return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Service Error", Severity: logrus.ErrorLevel, Err: err}
-	}
 
-	_, err = s.storeRepo.Create(p)
+	//Command, product and messages are stored atomically, the messages are delivered once committed
+	err := s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Create(p); err != nil {
+			return err
+		}
+
+		return tx.StoreMessages(*commandID, messages)
+	})
 	if err != nil {
 		return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Service Error", Severity: logrus.ErrorLevel, Err: err}
 	}
 
-	s.msgRepo.SendMessages(messages)
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &ID, &p.Version, &entity.Error{Op: "Create", Kind: entity.DeliveryFailed, ErrorMessage: "Created, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
 
 	return &ID, &p.Version, nil
 }
@@ -271,15 +697,21 @@ func (s *Service) Create(createProductDTO CreateProductDTO) (*entity.ID, *entity
 //UpdateProductDTO new product DTO
 type UpdateProductDTO struct {
 	// Version		entity.Version `json:"_V,omitempty" validate:"omitempty,required,min=3"`
-	Name        string `json:"name,omitempty" validate:"omitempty,min=3" structs:"name,omitempty"`
-	Description string `json:"description,omitempty" validate:"omitempty,min=20" structs:"description,omitempty"`
-	Slug        string `json:"slug,omitempty" validate:"omitempty" structs:"slug,omitempty"` //TODO: find slug validation
-	Location    string `json:"location,omitempty" bson:"location,omitempty"`
-	Image       string `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
-	Brand       string `json:"brand,omitempty" validate:"omitempty" structs:"brand,omitempty"`
-	Category    string `json:"category,omitempty" validate:"omitempty" structs:"category,omitempty"`
-	Status      string `json:"status,omitempty" validate:"omitempty,oneof=publish unpublish" structs:"status,omitempty"`
-	Price       int8   `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
+	Name        string        `json:"name,omitempty" validate:"omitempty,min=3" structs:"name,omitempty"`
+	Description string        `json:"description,omitempty" validate:"omitempty,min=20" structs:"description,omitempty"`
+	Slug        string        `json:"slug,omitempty" validate:"omitempty" structs:"slug,omitempty"` //TODO: find slug validation
+	Location    string        `json:"location,omitempty" bson:"location,omitempty"`
+	Image       string        `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
+	Brand       string        `json:"brand,omitempty" validate:"omitempty" structs:"brand,omitempty"`
+	Category    string        `json:"category,omitempty" validate:"omitempty" structs:"category,omitempty"`
+	Status      string        `json:"status,omitempty" validate:"omitempty,oneof=publish unpublish" structs:"status,omitempty"`
+	Price       *entity.Money `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
+	//LowStockThreshold override the global variants low stock threshold
+	LowStockThreshold *int `json:"lowStockThreshold,omitempty" validate:"omitempty,min=0" structs:"lowStockThreshold,omitempty"`
+	//Options options the variants matrix is generated from, up to 3 as variant attributes
+	Options []*entity.Option `json:"options,omitempty" validate:"omitempty,max=3,dive" structs:"options,omitempty"`
+	//Type product type declaring the attributes of the variants
+	Type string `json:"type,omitempty" validate:"omitempty" structs:"type,omitempty"`
 }
 
 //UpdateOne product
@@ -431,6 +863,16 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateProductDTO UpdateProduc
 				}
 				version++
 
+				_, err := s.categoryRepo.FindOneByID(entity.ID(value.String()))
+				switch err {
+				case entity.ErrNotFound:
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Category " + value.String() + " Not found"})
+				default:
+					if err != nil {
+						return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+					}
+				}
+
 				payload := make(map[string]interface{})
 				payload["category"] = value.String()
 
@@ -469,14 +911,17 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateProductDTO UpdateProduc
 				}
 			}
 		case "Price":
-			if value.Int() != 0 {
-				if p.Price == updateProductDTO.Price {
+			if updateProductDTO.Price != nil {
+				if p.Price.Equal(updateProductDTO.Price) {
 					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Price already updated"})
 				}
+				if !entity.IsCurrency(updateProductDTO.Price.Currency) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Unknown currency " + updateProductDTO.Price.Currency})
+				}
 				version++
 
 				payload := make(map[string]interface{})
-				payload["price"] = value.Int()
+				payload["price"] = updateProductDTO.Price
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
@@ -485,6 +930,44 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateProductDTO UpdateProduc
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
+		case "LowStockThreshold":
+			if updateProductDTO.LowStockThreshold != nil {
+				if p.LowStockThreshold != nil && *p.LowStockThreshold == *updateProductDTO.LowStockThreshold {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Low stock threshold already updated"})
+				}
+				version++
+
+				messages = append(messages, lowStockThresholdMessage(ID, version, *updateProductDTO.LowStockThreshold, Timestamp))
+			}
+		case "Options":
+			if updateProductDTO.Options != nil {
+				errs.Errors = append(errs.Errors, normalizeOptions(updateProductDTO.Options)...)
+				if reflect.DeepEqual(p.Options, updateProductDTO.Options) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Options already updated"})
+				}
+				version++
+
+				messages = append(messages, optionsMessage(ID, version, updateProductDTO.Options, Timestamp))
+			}
+		case "Type":
+			if value.String() != "" {
+				if string(p.Type) == updateProductDTO.Type {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Type already updated"})
+				}
+				version++
+
+				_, err := s.typeRepo.FindOneByID(entity.ID(value.String()))
+				switch err {
+				case entity.ErrNotFound:
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Product type " + value.String() + " Not found"})
+				default:
+					if err != nil {
+						return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+					}
+				}
+
+				messages = append(messages, typeMessage(ID, version, value.String(), Timestamp))
+			}
 		}
 	}
 
@@ -497,45 +980,115 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateProductDTO UpdateProduc
 	}
 
 	c := &entity.Command{AggregateID: string(ID), Type: "UpdateProduct", Payload: structs.Map(updateProductDTO), Timestamp: Timestamp}
-	_, err = s.storeRepo.StoreCommand(c)
-	if err != nil {
-		return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
-	}
 
 	up := &entity.UpdateProduct{
-		Version:     version,
-		Name:        updateProductDTO.Name,
-		Description: updateProductDTO.Description,
-		Slug:        updateProductDTO.Slug,
-		Image:       updateProductDTO.Image,
-		Brand:       updateProductDTO.Brand,
-		Category:    updateProductDTO.Category,
-		Status:      updateProductDTO.Status,
-		Price:       updateProductDTO.Price,
-	}
-
-	updatedNum, err := s.storeRepo.UpdateOneP(ID, up, entity.Version(v))
-	if err != nil {
-		return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		Version:           version,
+		Name:              updateProductDTO.Name,
+		Description:       updateProductDTO.Description,
+		Slug:              updateProductDTO.Slug,
+		Image:             updateProductDTO.Image,
+		Brand:             updateProductDTO.Brand,
+		Category:          updateProductDTO.Category,
+		Status:            updateProductDTO.Status,
+		Price:             updateProductDTO.Price,
+		LowStockThreshold: updateProductDTO.LowStockThreshold,
+		Options:           updateProductDTO.Options,
+		Type:              entity.ID(updateProductDTO.Type),
 	}
 
-	if updatedNum != 1 {
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updatedNum, err := tx.UpdateOneP(ID, up, entity.Version(v))
+		if err != nil {
+			return err
+		}
+
+		if updatedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case entity.ErrVersionConflict:
 		return nil, &entity.Error{Op: "UpdateOne", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		}
 	}
 
-	//TODO:handle failure cases
-	s.msgRepo.SendMessages(messages)
-
 	Version := int32(version)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &Version, &entity.Error{Op: "UpdateOne", Kind: entity.DeliveryFailed, ErrorMessage: "Updated, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
 	return &Version, nil
 }
 
+//lowStockThresholdMessage low stock threshold updated event of the product
+func lowStockThresholdMessage(ID entity.ID, version entity.Version, threshold int, t time.Time) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["lowStockThreshold"] = threshold
+
+	return &entity.Message{ID: string(ID), Type: "PRODUCT_LOW_STOCK_THRESHOLD_UPDATED", Version: version, Payload: payload, Timestamp: t}
+}
+
+//optionsMessage options updated event of the product
+func optionsMessage(ID entity.ID, version entity.Version, options []*entity.Option, t time.Time) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["options"] = options
+
+	return &entity.Message{ID: string(ID), Type: "PRODUCT_OPTIONS_UPDATED", Version: version, Payload: payload, Timestamp: t}
+}
+
+//typeMessage product type updated event of the product
+func typeMessage(ID entity.ID, version entity.Version, productType string, t time.Time) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["type"] = productType
+
+	return &entity.Message{ID: string(ID), Type: "PRODUCT_TYPE_UPDATED", Version: version, Payload: payload, Timestamp: t}
+}
+
+//normalizeOptions lower case the options names and values as the variants attributes are, and check they are unique
+func normalizeOptions(options []*entity.Option) []entity.ErrorField {
+	var errs []entity.ErrorField
+
+	names := make(map[string]bool)
+	for _, o := range options {
+		o.Name = strings.ToLower(o.Name)
+		if names[o.Name] {
+			errs = append(errs, entity.ErrorField{Field: "Options", Error: "Duplicated option " + o.Name})
+		}
+		names[o.Name] = true
+
+		values := make(map[string]bool)
+		for i, v := range o.Values {
+			o.Values[i] = strings.ToLower(v)
+			if values[o.Values[i]] {
+				errs = append(errs, entity.ErrorField{Field: "Options", Error: "Duplicated value " + o.Values[i] + " of option " + o.Name})
+			}
+			values[o.Values[i]] = true
+		}
+	}
+
+	if n := entity.OptionCombinations(options); n > entity.MaxOptionCombinations {
+		errs = append(errs, entity.ErrorField{Field: "Options", Error: fmt.Sprintf("Options make more than %d combinations", entity.MaxOptionCombinations)})
+	}
+
+	return errs
+}
+
 //Delete product
 func (s *Service) Delete(ID entity.ID, v int32) *entity.Error {
 	Timestamp := time.Now()
 	version := entity.Version(v)
 
-	//TODO check Transactions
 	p, err := s.storeRepo.FindOneByID(ID)
 	switch err {
 	case entity.ErrNotFound:
@@ -552,15 +1105,180 @@ func (s *Service) Delete(ID entity.ID, v int32) *entity.Error {
 	}
 
 	c := &entity.Command{AggregateID: string(ID), Type: "DeleteProduct", Timestamp: Timestamp}
-	s.storeRepo.StoreCommand(c)
+	m := &entity.Message{ID: string(ID), Type: "PRODUCT_DELETED", Version: version + 1, Timestamp: Timestamp}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		deletedNum, err := tx.DeleteOne(ID, version)
+		if err != nil {
+			return err
+		}
+
+		if deletedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		return tx.StoreMessages(*commandID, []*entity.Message{m})
+	})
+	switch err {
+	case entity.ErrVersionConflict:
+		return &entity.Error{Op: "Delete", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return &entity.Error{Op: "Delete", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		}
+	}
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &entity.Error{Op: "Delete", Kind: entity.DeliveryFailed, ErrorMessage: "Deleted, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return nil
+}
+
+//RemoveBrand remove a deleted brand from all the products referencing it
+func (s *Service) RemoveBrand(name string) *entity.Error {
+	return s.replaceBrand("RemoveBrand", name, "")
+}
+
+//RenameBrand point the products referencing a renamed brand to its new name
+func (s *Service) RenameBrand(from string, to string) *entity.Error {
+	return s.replaceBrand("RenameBrand", from, to)
+}
+
+//replaceBrand replace the brand of all the products referencing it, removes it if to is empty
+func (s *Service) replaceBrand(op string, from string, to string) *entity.Error {
+	f := &Filter{Brand: from, Sort: "createdAt"}
+
+	for {
+		products, err := s.storeRepo.FindMany(f, 100)
+		if err != nil {
+			return &entity.Error{Op: entity.Op(op), Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if len(products) == 0 {
+			return nil
+		}
 
-	s.storeRepo.DeleteOne(ID, version)
+		for _, p := range products {
+			if err := s.replaceProductBrand(op, p, from, to); err != nil {
+				return err
+			}
+		}
 
-	version++
+		f.After = NewCursor(products[len(products)-1], f.Sort, f.Desc)
+	}
+}
 
-	//TODO:handle failure cases
-	m := &entity.Message{ID: string(ID), Type: "PRODUCT_DELETED", Version: version, Timestamp: Timestamp}
-	s.msgRepo.SendMessage(m)
+//replaceProductBrand replace the brand of a product, retries if the product is concurrently modified
+func (s *Service) replaceProductBrand(op string, p *entity.Product, from string, to string) *entity.Error {
+	for retry := 0; retry < 3; retry++ {
+		Timestamp := time.Now()
+
+		c := &entity.Command{AggregateID: string(p.ID), Type: op, Payload: map[string]interface{}{"brand": from}, Timestamp: Timestamp}
+		m := &entity.Message{ID: string(p.ID), Type: "PRODUCT_BRAND_REMOVED", Version: p.Version + 1, Payload: map[string]interface{}{"brand": from}, Timestamp: Timestamp}
+		if to != "" {
+			c.Payload["to"] = to
+			m.Type = "PRODUCT_BRAND_UPDATED"
+			m.Payload = map[string]interface{}{"brand": to}
+		}
+
+		err := s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+			commandID, err := tx.StoreCommand(c)
+			if err != nil {
+				return err
+			}
+
+			var updatedNum int
+			if to == "" {
+				updatedNum, err = tx.UnsetBrand(p.ID, p.Version)
+			} else {
+				updatedNum, err = tx.UpdateOneP(p.ID, &entity.UpdateProduct{Version: p.Version + 1, Brand: to}, p.Version)
+			}
+			if err != nil {
+				return err
+			}
+
+			if updatedNum != 1 {
+				return entity.ErrVersionConflict
+			}
+
+			return tx.StoreMessages(*commandID, []*entity.Message{m})
+		})
+		switch err {
+		case nil:
+			//Undelivered events stay in the outbox for the relay
+			if err := s.outbox.Deliver(string(p.ID)); err != nil {
+				log.Println("Error on delivering brand events", p.ID, err)
+			}
+			return nil
+		case entity.ErrVersionConflict:
+		default:
+			return &entity.Error{Op: entity.Op(op), Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		p, err = s.storeRepo.FindOneByID(p.ID)
+		switch err {
+		case entity.ErrNotFound:
+			return nil
+		default:
+			if err != nil {
+				return &entity.Error{Op: entity.Op(op), Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+			}
+		}
+
+		if p.Brand != from {
+			return nil
+		}
+	}
+
+	return &entity.Error{Op: entity.Op(op), Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+}
+
+//Rebuild recompute the stored product by folding its events from the event store
+//returns nil without error for a deleted product
+func (s *Service) Rebuild(ID entity.ID) (*entity.Product, *entity.Error) {
+	events, err := s.eventRepo.FindByAggregate(string(ID))
+	if err != nil {
+		return nil, &entity.Error{Op: "Rebuild", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return s.Project(ID, events)
+}
+
+//Project replace the stored product by the fold of its events, the stored product is removed if it is deleted
+//returns nil without error for a deleted product
+func (s *Service) Project(ID entity.ID, events []*entity.StoredEvent) (*entity.Product, *entity.Error) {
+	p, err := Rehydrate(events)
+	switch err {
+	case nil:
+		if err := s.storeRepo.ReplaceOne(p); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		return p, nil
+	case entity.ErrAggregateDeleted:
+		if err := s.storeRepo.RemoveOne(ID); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		return nil, nil
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "Project", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+}
+
+//RemoveAll remove all the stored products, used before rebuilding them
+func (s *Service) RemoveAll() *entity.Error {
+	if err := s.storeRepo.RemoveAll(); err != nil {
+		return &entity.Error{Op: "RemoveAll", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
 
 	return nil
 }
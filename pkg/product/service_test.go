@@ -1,13 +1,19 @@
 package product_test
 
 import (
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/markus-azer/products-service/pkg/brand"
+	"github.com/markus-azer/products-service/pkg/category"
 	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/outbox"
 	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/producttype"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,27 +23,36 @@ func TestCreate(t *testing.T) {
 
 	productRepo := product.NewMockStoreRepository(controller)
 	brandRepo := brand.NewMockStoreRepository(controller)
-	messagesRepo := product.NewMockMessagesRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
 
-	service := product.NewService(messagesRepo, productRepo, brandRepo)
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
 
 	ID := entity.NewID()
 	storeID := entity.NewID()
 
 	cp := product.CreateProductDTO{
 		Name:   "Test Product",
-		Price:  20,
+		Price:  &entity.Money{Amount: 2000, Currency: "USD"},
 		Seller: "test",
 	}
 
 	invalidCP := product.CreateProductDTO{
-		Price:  20,
+		Price:  &entity.Money{Amount: 2000, Currency: "USD"},
 		Seller: "test",
 	}
 
+	productRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(product.StoreRepository) error) error {
+		return fn(productRepo)
+	})
 	productRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
 	productRepo.EXPECT().Create(gomock.Any()).Return(&ID, nil)
-	messagesRepo.EXPECT().SendMessages(gomock.Any())
+	productRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 3, len(messages))
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(gomock.Any()).Return(nil)
 
 	id, v, err := service.Create(cp)
 	// https://godoc.org/golang.org/x/tools/cmd/godoc
@@ -71,37 +86,510 @@ func TestUpdate(t *testing.T) {
 
 	productRepo := product.NewMockStoreRepository(controller)
 	brandRepo := brand.NewMockStoreRepository(controller)
-	messagesRepo := product.NewMockMessagesRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
 
-	service := product.NewService(messagesRepo, productRepo, brandRepo)
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
 
 	ID := entity.NewID()
 	storeID := entity.NewID()
 
-	product := product.UpdateProductDTO{
+	updateProductDTO := product.UpdateProductDTO{
 		Name:  "Updated Test Product",
-		Price: 25,
+		Price: &entity.Money{Amount: 2500, Currency: "USD"},
 	}
 
 	createdProduct := entity.Product{
 		ID:      ID,
 		Version: 3,
 		Name:    "Test Product",
-		Price:   20,
+		Price:   &entity.Money{Amount: 2000, Currency: "USD"},
 	}
 
 	productRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil).MaxTimes(2)
 	productRepo.EXPECT().FindOneByID(gomock.Any()).Return(&createdProduct, nil).MaxTimes(2)
+	productRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(product.StoreRepository) error) error {
+		return fn(productRepo)
+	})
 	productRepo.EXPECT().UpdateOneP(gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil)
-	messagesRepo.EXPECT().SendMessages(gomock.Any())
+	productRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
 
-	v, err := service.UpdateOne(ID, 3, product)
+	v, err := service.UpdateOne(ID, 3, updateProductDTO)
 
 	assert.Nil(t, err)
 	assert.Equal(t, int32(5), *v)
 
-	v, err = service.UpdateOne(ID, 1, product)
+	v, err = service.UpdateOne(ID, 1, updateProductDTO)
 
 	assert.NotNil(t, err)
 	assert.Equal(t, entity.ConcurrentModification, err.Kind)
 }
+
+func TestCreateDeliveryFailed(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+
+	productRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(product.StoreRepository) error) error {
+		return fn(productRepo)
+	})
+	productRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	productRepo.EXPECT().Create(gomock.Any()).Return(&ID, nil)
+	productRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Return(nil)
+	outboxService.EXPECT().Deliver(gomock.Any()).Return(outbox.ErrDeliveryTimeout)
+
+	id, v, err := service.Create(product.CreateProductDTO{Name: "Test Product", Seller: "test"})
+
+	//The product is stored, only its events are pending
+	assert.NotNil(t, id)
+	assert.Equal(t, entity.Version(2), *v)
+	assert.Equal(t, entity.DeliveryFailed, entity.KindE(err))
+}
+
+func TestFindOneByID(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	ID := entity.NewID()
+
+	storedProduct := entity.Product{
+		ID:      ID,
+		Version: 3,
+		Name:    "Test Product",
+		Brand:   "Test Brand",
+	}
+
+	variants := []*entity.Variant{{ID: entity.NewID(), Product: ID, Version: 1}}
+	storedBrand := entity.Brand{ID: entity.NewID(), Name: "Test Brand"}
+
+	productRepo.EXPECT().FindOneByID(ID).Return(&storedProduct, nil)
+	productRepo.EXPECT().FindVariantsByProduct(ID).Return(variants, nil)
+	brandRepo.EXPECT().FindOneByName("Test Brand").Return(&storedBrand, nil)
+
+	p, err := service.FindOneByID(ID, []string{"variants", "brand"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.Version(3), p.Version)
+	assert.Equal(t, 1, len(p.Variants))
+	assert.Equal(t, storedBrand.ID, p.BrandDetails.ID)
+
+	p, err = service.FindOneByID(ID, []string{"seller"})
+
+	assert.Nil(t, p)
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+
+	productRepo.EXPECT().FindOneByID(gomock.Any()).Return(nil, entity.ErrNotFound)
+
+	p, err = service.FindOneByID(entity.NewID(), nil)
+
+	assert.Nil(t, p)
+	assert.Equal(t, entity.NotFound, err.Kind)
+}
+
+func TestFindMany(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	products := []*entity.Product{
+		{ID: entity.NewID(), Name: "A", Price: &entity.Money{Amount: 1000, Currency: "USD"}},
+		{ID: entity.NewID(), Name: "B", Price: &entity.Money{Amount: 2000, Currency: "USD"}},
+		{ID: entity.NewID(), Name: "C", Price: &entity.Money{Amount: 3000, Currency: "USD"}},
+	}
+
+	productRepo.EXPECT().FindMany(gomock.Any(), 3).DoAndReturn(func(f *product.Filter, limit int) ([]*entity.Product, error) {
+		assert.Equal(t, "price", f.Sort)
+		assert.Equal(t, "USD", f.Currency)
+		assert.False(t, f.Desc)
+		assert.Nil(t, f.After)
+		return products, nil
+	})
+
+	// Amounts of different currencies can't be ordered together
+	_, _, err := service.FindMany(product.ListProductsDTO{Sort: "price", Limit: 2})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+
+	page, next, err := service.FindMany(product.ListProductsDTO{Sort: "price", Currency: "USD", Limit: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(page))
+	assert.NotEqual(t, "", next)
+
+	productRepo.EXPECT().FindMany(gomock.Any(), 3).DoAndReturn(func(f *product.Filter, limit int) ([]*entity.Product, error) {
+		assert.Equal(t, products[1].ID, f.After.ID)
+		assert.Equal(t, "2000", f.After.Value)
+		return products[2:], nil
+	})
+
+	page, next, err = service.FindMany(product.ListProductsDTO{Sort: "price", Currency: "USD", Limit: 2, Cursor: next})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(page))
+	assert.Equal(t, "", next)
+
+	// A cursor can't be reused with another sort
+	cursor := product.NewCursor(products[0], "price", false).Encode()
+	_, _, err = service.FindMany(product.ListProductsDTO{Sort: "name", Cursor: cursor})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+}
+
+func TestFindManyCategoryTree(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	clothes := &entity.Category{ID: entity.NewID(), Name: "Clothes"}
+	clothes.Path = entity.CategoryPath("", clothes.ID)
+	shirts := &entity.Category{ID: entity.NewID(), Name: "Shirts", Parent: clothes.ID}
+	shirts.Path = entity.CategoryPath(clothes.Path, shirts.ID)
+
+	// The products of the subcategories are listed under their parent category
+	categoryRepo.EXPECT().FindOneByID(clothes.ID).Return(clothes, nil)
+	categoryRepo.EXPECT().FindDescendants(clothes.Path).Return([]*entity.Category{shirts}, nil)
+	productRepo.EXPECT().FindMany(gomock.Any(), 21).DoAndReturn(func(f *product.Filter, limit int) ([]*entity.Product, error) {
+		assert.Equal(t, []string{string(clothes.ID), string(shirts.ID)}, f.Categories)
+		return []*entity.Product{}, nil
+	})
+
+	_, _, err := service.FindMany(product.ListProductsDTO{Category: string(clothes.ID)})
+
+	assert.Nil(t, err)
+
+	// An unknown category matches no product
+	unknown := entity.NewID()
+	categoryRepo.EXPECT().FindOneByID(unknown).Return(nil, entity.ErrNotFound)
+	productRepo.EXPECT().FindMany(gomock.Any(), 21).DoAndReturn(func(f *product.Filter, limit int) ([]*entity.Product, error) {
+		assert.Equal(t, []string{string(unknown)}, f.Categories)
+		return []*entity.Product{}, nil
+	})
+
+	_, _, err = service.FindMany(product.ListProductsDTO{Category: string(unknown)})
+
+	assert.Nil(t, err)
+}
+
+func TestSearch(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	result := &product.SearchResult{
+		Hits: []*product.SearchHit{
+			{Product: entity.Product{ID: entity.NewID(), Name: "Red Shirt", Description: "A <b>cotton</b> shirt <script>alert(1)</script>"}, Score: 1.5},
+		},
+		Facets: product.Facets{Prices: []product.PriceBucket{{Currency: "USD", Min: 2500, Count: 1}, {Currency: "USD", Min: 100000, Count: 2}}},
+		Total:  1,
+	}
+
+	productRepo.EXPECT().Search("shirt -blue", gomock.Any(), 20, 20).Return(result, nil)
+
+	r, err := service.Search(product.SearchProductsDTO{Query: "shirt -blue", Page: 2})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, r.Total)
+	assert.Equal(t, "Red <em>Shirt</em>", r.Hits[0].Highlights["name"])
+	// Only the highlight tags are HTML, the stored text is escaped
+	assert.Equal(t, "A &lt;b&gt;cotton&lt;/b&gt; <em>shirt</em> &lt;script&gt;alert(1)&lt;/script&gt;", r.Hits[0].Highlights["description"])
+	assert.Equal(t, int64(5000), r.Facets.Prices[0].Max)
+	// The top bucket has no upper bound
+	assert.Equal(t, int64(0), r.Facets.Prices[1].Max)
+
+	_, err = service.Search(product.SearchProductsDTO{})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+}
+
+func TestCreateUnknownCategory(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	shoes := entity.NewID()
+	categoryRepo.EXPECT().FindOneByID(shoes).Return(nil, entity.ErrNotFound)
+
+	id, v, err := service.Create(product.CreateProductDTO{Name: "Test Product", Category: string(shoes), Seller: "test"})
+
+	assert.Nil(t, id)
+	assert.Nil(t, v)
+	e, _ := err.(*entity.Error)
+	assert.Equal(t, entity.ValidationFailed, e.Kind)
+	assert.Equal(t, "Category Not found", e.Errors[0].Error)
+}
+
+func TestRemoveBrand(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	storedProduct := &entity.Product{ID: entity.NewID(), Version: 2, Brand: "Deleted Brand"}
+	storeID := entity.NewID()
+
+	gomock.InOrder(
+		productRepo.EXPECT().FindMany(gomock.Any(), 100).Return([]*entity.Product{storedProduct}, nil),
+		productRepo.EXPECT().FindMany(gomock.Any(), 100).Return([]*entity.Product{}, nil),
+	)
+	productRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	productRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(product.StoreRepository) error) error {
+		return fn(productRepo)
+	})
+	productRepo.EXPECT().UnsetBrand(storedProduct.ID, entity.Version(2)).Return(1, nil)
+	productRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, "PRODUCT_BRAND_REMOVED", messages[0].Type)
+		assert.Equal(t, entity.Version(3), messages[0].Version)
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(storedProduct.ID)).Return(nil)
+
+	err := service.RemoveBrand("Deleted Brand")
+
+	assert.Nil(t, err)
+}
+
+func TestRenameBrand(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	storedProduct := &entity.Product{ID: entity.NewID(), Version: 2, Brand: "Acme"}
+	storeID := entity.NewID()
+
+	gomock.InOrder(
+		productRepo.EXPECT().FindMany(gomock.Any(), 100).DoAndReturn(func(f *product.Filter, limit int) ([]*entity.Product, error) {
+			assert.Equal(t, "Acme", f.Brand)
+			return []*entity.Product{storedProduct}, nil
+		}),
+		productRepo.EXPECT().FindMany(gomock.Any(), 100).Return([]*entity.Product{}, nil),
+	)
+	productRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	productRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(product.StoreRepository) error) error {
+		return fn(productRepo)
+	})
+	productRepo.EXPECT().UpdateOneP(storedProduct.ID, &entity.UpdateProduct{Version: 3, Brand: "Acme Tools"}, entity.Version(2)).Return(1, nil)
+	productRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, "PRODUCT_BRAND_UPDATED", messages[0].Type)
+		assert.Equal(t, "Acme Tools", messages[0].Payload["brand"])
+		assert.Equal(t, entity.Version(3), messages[0].Version)
+	}).Return(nil)
+	// A failed delivery is left to the relay, the other products are still renamed
+	outboxService.EXPECT().Deliver(string(storedProduct.ID)).Return(errors.New("Delivery failed"))
+
+	err := service.RenameBrand("Acme", "Acme Tools")
+
+	assert.Nil(t, err)
+}
+
+func TestRebuild(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	ID := entity.NewID()
+	createdAt := time.Now()
+
+	events := entity.NewStoredEvents("product", []*entity.Message{
+		{ID: string(ID), Type: "PRODUCT_DRAFT_CREATED", Version: 1, Payload: map[string]interface{}{"seller": "test"}, Timestamp: createdAt},
+		{ID: string(ID), Type: "PRODUCT_NAME_UPDATED", Version: 2, Payload: map[string]interface{}{"name": "Test Product"}},
+		{ID: string(ID), Type: "PRODUCT_BRAND_UPDATED", Version: 3, Payload: map[string]interface{}{"brand": "Test Brand"}},
+		{ID: string(ID), Type: "PRODUCT_PRICE_UPDATED", Version: 4, Payload: map[string]interface{}{"price": int32(20)}},
+		{ID: string(ID), Type: "PRODUCT_BRAND_REMOVED", Version: 5, Payload: map[string]interface{}{"brand": "Test Brand"}},
+	})
+
+	// Prices stored before money carried a currency are read as USD major units
+	expected := &entity.Product{ID: ID, Version: 5, Name: "Test Product", Price: &entity.Money{Amount: 2000, Currency: "USD"}, Status: "unpublish", Seller: "test", CreatedAt: createdAt}
+
+	eventRepo.EXPECT().FindByAggregate(string(ID)).Return(events, nil)
+	productRepo.EXPECT().ReplaceOne(expected).Return(nil)
+
+	p, err := service.Rebuild(ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, expected, p)
+
+	deleted := append(events, entity.NewStoredEvents("product", []*entity.Message{{ID: string(ID), Type: "PRODUCT_DELETED", Version: 6}})...)
+
+	eventRepo.EXPECT().FindByAggregate(string(ID)).Return(deleted, nil)
+	productRepo.EXPECT().RemoveOne(ID).Return(nil)
+
+	p, err = service.Rebuild(ID)
+
+	assert.Nil(t, err)
+	assert.Nil(t, p)
+}
+
+func TestFindOneAt(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	ID := entity.NewID()
+	createdAt := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(48 * time.Hour)
+
+	events := entity.NewStoredEvents("product", []*entity.Message{
+		{ID: string(ID), Type: "PRODUCT_DRAFT_CREATED", Version: 1, Payload: map[string]interface{}{"seller": "test"}, Timestamp: createdAt},
+		{ID: string(ID), Type: "PRODUCT_NAME_UPDATED", Version: 2, Payload: map[string]interface{}{"name": "Test Product"}, Timestamp: createdAt},
+		{ID: string(ID), Type: "PRODUCT_NAME_UPDATED", Version: 3, Payload: map[string]interface{}{"name": "Renamed Product"}, Timestamp: updatedAt},
+	})
+
+	eventRepo.EXPECT().FindByAggregate(string(ID)).Return(events, nil).Times(4)
+
+	p, err := service.FindOneAt(ID, product.FindOneAtDTO{Version: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Product", p.Name)
+	assert.Equal(t, entity.Version(2), p.Version)
+
+	p, err = service.FindOneAt(ID, product.FindOneAtDTO{AsOf: updatedAt.Add(-time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, "Test Product", p.Name)
+
+	p, err = service.FindOneAt(ID, product.FindOneAtDTO{AsOf: updatedAt})
+	assert.Nil(t, err)
+	assert.Equal(t, "Renamed Product", p.Name)
+	assert.Equal(t, entity.Version(3), p.Version)
+
+	_, err = service.FindOneAt(ID, product.FindOneAtDTO{AsOf: createdAt.Add(-time.Hour)})
+	assert.Equal(t, entity.NotFound, err.Kind)
+
+	_, err = service.FindOneAt(ID, product.FindOneAtDTO{})
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+
+	//Products stored before the event store start at their snapshot
+	migratedAt := updatedAt.Add(time.Hour)
+	snapshot := product.NewSnapshot(&entity.Product{ID: ID, Version: 3, Name: "Renamed Product", Seller: "test", Status: "unpublish", CreatedAt: createdAt}, migratedAt)
+	eventRepo.EXPECT().FindByAggregate(string(ID)).Return([]*entity.StoredEvent{snapshot}, nil).Times(2)
+
+	p, err = service.FindOneAt(ID, product.FindOneAtDTO{Version: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, "Renamed Product", p.Name)
+
+	_, err = service.FindOneAt(ID, product.FindOneAtDTO{Version: 2})
+	assert.Equal(t, entity.NotFound, err.Kind)
+	assert.Equal(t, entity.ErrorMessage("Product with id "+string(ID)+" has no history before "+migratedAt.Format(time.RFC3339)), err.ErrorMessage)
+}
+
+func TestHistory(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	productRepo := product.NewMockStoreRepository(controller)
+	brandRepo := brand.NewMockStoreRepository(controller)
+	categoryRepo := category.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := product.NewService(productRepo, brandRepo, categoryRepo, typeRepo, eventRepo, outboxService)
+
+	ID := entity.NewID()
+	create := &entity.Command{ID: entity.NewID(), AggregateID: string(ID), Type: "CreateProduct"}
+	update := &entity.Command{ID: entity.NewID(), AggregateID: string(ID), Type: "UpdateProduct"}
+
+	events := entity.NewStoredEvents("product", []*entity.Message{
+		{ID: string(ID), Type: "PRODUCT_DRAFT_CREATED", Version: 1},
+		{ID: string(ID), Type: "PRODUCT_NAME_UPDATED", Version: 2},
+		{ID: string(ID), Type: "PRODUCT_PRICE_UPDATED", Version: 3},
+	})
+	events[0].CommandID = create.ID
+	events[1].CommandID = create.ID
+	events[2].CommandID = update.ID
+
+	eventRepo.EXPECT().FindByAggregate(string(ID)).Return(events, nil)
+	productRepo.EXPECT().FindCommands([]entity.ID{create.ID, update.ID}).Return([]*entity.Command{update, create}, nil)
+
+	entries, err := service.History(ID)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, create, entries[0].Command)
+	assert.Equal(t, 2, len(entries[0].Events))
+	assert.Equal(t, update, entries[1].Command)
+	assert.Equal(t, entity.Version(3), entries[1].Events[0].Version)
+}
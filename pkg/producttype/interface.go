@@ -0,0 +1,39 @@
+//go:generate mockgen -source interface.go -destination producttype_mock.go -package producttype
+
+package producttype
+
+import "github.com/markus-azer/products-service/pkg/entity"
+
+//StoreReader product type reader interface
+type storeReader interface {
+	FindOneByID(id entity.ID) (*entity.ProductType, error)
+	FindMany() ([]*entity.ProductType, error)
+}
+
+//StoreWriter product type writer interface
+type storeWriter interface {
+	Create(t *entity.ProductType) error
+}
+
+//StoreRepository product type store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//Reader interface
+type reader interface {
+	FindOneByID(id entity.ID) (*entity.ProductType, *entity.Error)
+	FindMany() ([]*entity.ProductType, *entity.Error)
+}
+
+//Writer interface
+type writer interface {
+	Create(createProductTypeDTO CreateProductTypeDTO) (*entity.ID, *entity.Error)
+}
+
+//UseCase use case interface
+type UseCase interface {
+	reader
+	writer
+}
@@ -0,0 +1,87 @@
+package producttype
+
+import (
+	"context"
+	"log"
+
+	"github.com/markus-azer/products-service/lib/mongodb"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db *mongo.Database
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db: db,
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes unique names
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("product-types")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating product types indexes", err)
+	}
+}
+
+//FindOneByID find product type by Id
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.ProductType, error) {
+	result := entity.ProductType{}
+	coll := r.db.Collection("product-types")
+	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindMany find all the product types sorted by name
+func (r *MongoRepository) FindMany() ([]*entity.ProductType, error) {
+	coll := r.db.Collection("product-types")
+
+	cur, err := coll.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	types := []*entity.ProductType{}
+	if err := cur.All(context.TODO(), &types); err != nil {
+		return nil, err
+	}
+
+	return types, nil
+}
+
+//Create create new product type, returns entity.ErrAlreadyExists if the name is used
+func (r *MongoRepository) Create(t *entity.ProductType) error {
+	coll := r.db.Collection("product-types")
+
+	_, err := coll.InsertOne(context.TODO(), t)
+	if mongodb.IsDuplicateKeyError(err) {
+		return entity.ErrAlreadyExists
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+package producttype
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//Service service interface
+type Service struct {
+	storeRepo StoreRepository
+}
+
+//NewService create new service
+func NewService(storeR StoreRepository) *Service {
+	return &Service{
+		storeRepo: storeR,
+	}
+}
+
+//CreateProductTypeDTO new product type DTO
+type CreateProductTypeDTO struct {
+	Name       string                    `json:"name" validate:"required,min=2"`
+	Attributes []*entity.AttributeSchema `json:"attributes" validate:"required,min=1,dive"`
+	//SKUTemplate e.g. {brand}-{product-slug}-{attr.size}
+	SKUTemplate string `json:"skuTemplate,omitempty" validate:"omitempty"`
+}
+
+//Create new product type, attribute keys and enum values are lower cased as the variants attributes are
+func (s *Service) Create(createProductTypeDTO CreateProductTypeDTO) (*entity.ID, *entity.Error) {
+	if err := validator.New().Struct(createProductTypeDTO); err != nil {
+		errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+	keys := make(map[string]bool)
+	for _, a := range createProductTypeDTO.Attributes {
+		a.Key = strings.ToLower(a.Key)
+		if keys[a.Key] {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Attributes", Error: "Duplicated attribute " + a.Key})
+		}
+		keys[a.Key] = true
+
+		for i, v := range a.Values {
+			a.Values[i] = strings.ToLower(v)
+		}
+		a.Unit = strings.ToLower(a.Unit)
+
+		switch {
+		case a.Type == entity.AttributeEnum && len(a.Values) == 0:
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Values", Error: "Attribute " + a.Key + " must declare its values"})
+		case a.Type != entity.AttributeEnum && len(a.Values) > 0:
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Values", Error: "Only enum attributes declare values"})
+		case a.Type != entity.AttributeNumber && a.Unit != "":
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Unit", Error: "Only number attributes declare a unit"})
+		}
+	}
+
+	for _, placeholder := range entity.UnknownSKUPlaceholders(createProductTypeDTO.SKUTemplate) {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "SKUTemplate", Error: "Unknown placeholder " + placeholder})
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, &errs
+	}
+
+	t := &entity.ProductType{
+		ID:          entity.NewID(),
+		Name:        createProductTypeDTO.Name,
+		Attributes:  createProductTypeDTO.Attributes,
+		CreatedAt:   time.Now(),
+		SKUTemplate: createProductTypeDTO.SKUTemplate,
+	}
+
+	err := s.storeRepo.Create(t)
+	switch err {
+	case entity.ErrAlreadyExists:
+		return nil, &entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Name", Error: "Product type " + t.Name + " already exists"}}}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return &t.ID, nil
+}
+
+//FindOneByID product type
+func (s *Service) FindOneByID(ID entity.ID) (*entity.ProductType, *entity.Error) {
+	t, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product type with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return t, nil
+}
+
+//FindMany all the product types
+func (s *Service) FindMany() ([]*entity.ProductType, *entity.Error) {
+	types, err := s.storeRepo.FindMany()
+	if err != nil {
+		return nil, &entity.Error{Op: "FindMany", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return types, nil
+}
@@ -0,0 +1,72 @@
+package variant
+
+import (
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//DefaultLowStockThreshold available quantity at or below which a variant is low on stock when none is configured
+const DefaultLowStockThreshold = 5
+
+//threshold low stock threshold of the product, the service one if the product has none
+func (s *Service) threshold(product entity.ID) (int, error) {
+	p, err := s.productRepo.FindOneByID(product)
+	switch err {
+	case nil:
+		if p.LowStockThreshold != nil {
+			return *p.LowStockThreshold, nil
+		}
+	case entity.ErrNotFound:
+	default:
+		return 0, err
+	}
+
+	return s.lowStockThreshold, nil
+}
+
+//stockAlert event type of the available quantity crossing the threshold downwards, empty if it didn't cross it
+func stockAlert(before int, after int, threshold int) string {
+	switch {
+	case after <= 0 && before > 0:
+		return "PRODUCT_VARIANT_OUT_OF_STOCK"
+	case after <= threshold && before > threshold:
+		return "PRODUCT_VARIANT_LOW_STOCK"
+	default:
+		return ""
+	}
+}
+
+//alert the stock alert of the variant available quantity moving by delta to its updated quantity, nil without alert
+//The alert is an event of its own, the variant version is bumped within the transaction of the change.
+func alert(tx StoreRepository, updated *entity.Variant, delta int, threshold int, t time.Time) (*entity.Message, error) {
+	eventType := stockAlert(updated.Quantity-delta, updated.Quantity, threshold)
+	if eventType == "" {
+		return nil, nil
+	}
+
+	updatedNum, err := tx.BumpVersion(updated.ID, updated.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if updatedNum != 1 {
+		return nil, entity.ErrVersionConflict
+	}
+
+	payload := make(map[string]interface{})
+	payload["product"] = string(updated.Product)
+	payload["quantity"] = updated.Quantity
+	payload["threshold"] = threshold
+
+	return &entity.Message{ID: string(updated.ID), Type: eventType, Version: updated.Version + 1, Payload: payload, Timestamp: t}, nil
+}
+
+//notify call the webhooks registered to the stock alerts, calls are made in the background
+func (s *Service) notify(alerts []*entity.Message) {
+	if s.webhooks == nil || len(alerts) == 0 {
+		return
+	}
+
+	go s.webhooks.Notify(alerts)
+}
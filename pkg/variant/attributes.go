@@ -0,0 +1,70 @@
+package variant
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//ErrDuplicateAttributes another variant of the product has the same attributes
+var ErrDuplicateAttributes = errors.New("Duplicate attributes")
+
+//MaxAttributes attributes limit of the variants whose product has no type
+const MaxAttributes = 3
+
+//checkAttributes check the variant attributes against the product type schema and the product options values,
+//the attributes of a product without type are only limited in number
+func (s *Service) checkAttributes(p *entity.Product, attributes map[string]string) ([]entity.ErrorField, error) {
+	var errs []entity.ErrorField
+
+	if p.Type != "" {
+		t, err := s.typeRepo.FindOneByID(p.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		errs = append(errs, t.ValidateAttributes(attributes)...)
+	} else if len(attributes) > MaxAttributes {
+		errs = append(errs, entity.ErrorField{Field: "Attributes", Error: "Max Attributes is 3"})
+	}
+
+	for k, v := range attributes {
+		if o := p.Option(k); o != nil && !o.Allows(v) {
+			errs = append(errs, entity.ErrorField{Field: "Attributes", Error: "Attribute " + k + " " + v + " is not an option value of the product"})
+		}
+	}
+
+	return errs, nil
+}
+
+//mergeAttributes the attributes after the updates, lower cased as on create, an empty value removes the attribute
+func mergeAttributes(attributes map[string]string, updates map[string]string) map[string]string {
+	result := make(map[string]string, len(attributes)+len(updates))
+	for k, v := range attributes {
+		result[k] = v
+	}
+
+	for k, v := range updates {
+		k, v = strings.ToLower(k), strings.ToLower(v)
+		if v == "" {
+			delete(result, k)
+			continue
+		}
+
+		result[k] = v
+	}
+
+	return result
+}
+
+//duplicateAttributes validation error of attributes taken concurrently by another variant of the product
+func (s *Service) duplicateAttributes(op entity.Op, product entity.ID, attributes map[string]string) *entity.Error {
+	e := entity.ErrorField{Field: "Attributes", Error: "Variant Attributes Duplication"}
+	if duplicatedVariant, err := s.storeRepo.FindOneByAttribute(product, attributes); err == nil {
+		e.Error += " with ID " + string(duplicatedVariant.ID)
+	}
+
+	return &entity.Error{Op: op, Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{e}}
+}
@@ -0,0 +1,75 @@
+package variant
+
+import (
+	"errors"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//ErrDuplicateBarcode another variant has the same barcode
+var ErrDuplicateBarcode = errors.New("Duplicate barcode")
+
+//FindOneByBarcode find variant by EAN-8, UPC-A, EAN-13 or GTIN-14 barcode, the same product is found by any of them
+func (s *Service) FindOneByBarcode(barcode string) (*entity.Variant, *entity.Error) {
+	if _, err := entity.DetectBarcode(barcode); err != nil {
+		return nil, &entity.Error{Op: "FindOneByBarcode", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Barcode", Error: err.Error()}}}
+	}
+
+	v, err := s.storeRepo.FindOneByGTIN(entity.GTIN(barcode))
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByBarcode", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with barcode " + barcode + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByBarcode", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	v.SetSalePrice(time.Now())
+
+	return v, nil
+}
+
+//checkBarcode validate the barcode check digit and that it isn't used by another variant than ID,
+//returns the barcode type and the validation errors
+func (s *Service) checkBarcode(barcode string, ID entity.ID) (string, []entity.ErrorField, error) {
+	barcodeType, err := entity.DetectBarcode(barcode)
+	if err != nil {
+		return "", []entity.ErrorField{{Field: "Barcode", Error: err.Error()}}, nil
+	}
+
+	v, err := s.storeRepo.FindOneByGTIN(entity.GTIN(barcode))
+	switch err {
+	case nil:
+		if v.ID != ID {
+			return barcodeType, []entity.ErrorField{{Field: "Barcode", Error: "Barcode " + barcode + " already used by variant " + string(v.ID)}}, nil
+		}
+	case entity.ErrNotFound:
+	default:
+		return "", nil, err
+	}
+
+	return barcodeType, nil, nil
+}
+
+//duplicateBarcode validation error of a barcode taken concurrently by another variant
+func (s *Service) duplicateBarcode(op entity.Op, barcode string) *entity.Error {
+	e := entity.ErrorField{Field: "Barcode", Error: "Barcode " + barcode + " already used"}
+	if v, err := s.storeRepo.FindOneByGTIN(entity.GTIN(barcode)); err == nil {
+		e.Error += " by variant " + string(v.ID)
+	}
+
+	return &entity.Error{Op: op, Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{e}}
+}
+
+//barcodeMessage barcode updated event of the variant
+func barcodeMessage(ID entity.ID, version entity.Version, barcode string, barcodeType string, t time.Time) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["barcode"] = barcode
+	payload["barcodeType"] = barcodeType
+	payload["gtin"] = entity.GTIN(barcode)
+
+	return &entity.Message{ID: string(ID), Type: "PRODUCT_VARIANT_BARCODE_UPDATED", Version: version, Payload: payload, Timestamp: t}
+}
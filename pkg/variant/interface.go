@@ -2,36 +2,51 @@
 
 package variant
 
-import "github.com/markus-azer/products-service/pkg/entity"
+import (
+	"time"
 
-//MessagesReader Reader interface
-type messagesReader interface {
-}
-
-//MessagesWriter variant writer
-type messagesWriter interface {
-	SendMessage(m *entity.Message)
-	SendMessages(messages []*entity.Message)
-}
-
-//MessagesRepository repository interface
-type MessagesRepository interface {
-	messagesReader
-	messagesWriter
-}
+	"github.com/markus-azer/products-service/pkg/entity"
+)
 
 //StoreReader variant reader interface
 type storeReader interface {
+	FindCommands(ids []entity.ID) ([]*entity.Command, error)
 	FindOneByID(id entity.ID) (*entity.Variant, error)
 	FindOneByAttribute(product entity.ID, attributes map[string]string) (*entity.Variant, error)
+	FindOneBySKU(sku string) (*entity.Variant, error)
+	FindOneByGTIN(gtin string) (*entity.Variant, error)
+	FindDueSalePrices(now time.Time, limit int) ([]*entity.Variant, error)
+	FindPriceHistory(id entity.ID, priceList entity.ID, from time.Time, to time.Time) ([]*entity.PriceChange, error)
+	FindReservation(id entity.ID) (*entity.Reservation, error)
+	FindExpiredReservations(now time.Time, limit int) ([]*entity.Reservation, error)
+	FindMovements(id entity.ID, skip int, limit int) ([]*entity.StockMovement, error)
 }
 
 //StoreWriter variant writer interface
 type storeWriter interface {
+	WithTransaction(fn func(tx StoreRepository) error) error
 	StoreCommand(c *entity.Command) (*entity.ID, error)
+	StoreMessages(commandID entity.ID, messages []*entity.Message) error
 	Create(variant *entity.Variant) (*entity.ID, error)
+	CreateMany(variants []*entity.Variant) error
 	UpdateOne(id entity.ID, variant *entity.UpdateVariant, version entity.Version) (int, error)
+	UpdatePrice(id entity.ID, priceList entity.ID, price *entity.Money, version entity.Version) (int, error)
+	UpdateSalePrices(id entity.ID, salePrices []*entity.SalePrice, version entity.Version, to entity.Version, edited bool) (int, error)
 	DeleteOne(id entity.ID, version entity.Version) (int, error)
+	AppendPriceHistory(changes []*entity.PriceChange) error
+	ReserveStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error)
+	ReleaseStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error)
+	CommitStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error)
+	MoveStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error)
+	CreateMovement(m *entity.StockMovement) error
+	BumpVersion(id entity.ID, version entity.Version) (int, error)
+	ReplaceMovements(id entity.ID, movements []*entity.StockMovement) error
+	CreateReservation(r *entity.Reservation) error
+	UpdateReservationStatus(id entity.ID, from string, to string) (int, error)
+	ReplacePriceHistory(id entity.ID, changes []*entity.PriceChange) error
+	ReplaceOne(variant *entity.Variant) error
+	RemoveOne(id entity.ID) error
+	RemoveAll() error
 }
 
 //StoreRepository product store repository interface
@@ -42,13 +57,32 @@ type StoreRepository interface {
 
 //Reader interface
 type reader interface {
+	FindOneByID(id entity.ID) (*entity.Variant, *entity.Error)
+	FindOneByBarcode(barcode string) (*entity.Variant, *entity.Error)
+	EffectivePrice(id entity.ID, effectivePriceDTO EffectivePriceDTO) (*EffectivePrice, *entity.Error)
+	LowestPrice(id entity.ID, lowestPriceDTO LowestPriceDTO) (*LowestPrice, *entity.Error)
+	FindReservation(id entity.ID) (*entity.Reservation, *entity.Error)
+	FindStock(id entity.ID) (*Availability, *entity.Error)
+	FindMovements(id entity.ID, listMovementsDTO ListMovementsDTO) ([]*entity.StockMovement, *entity.Error)
 }
 
 //Writer interface
 type writer interface {
 	Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32, *entity.Error)
+	GenerateVariants(generateVariantsDTO GenerateVariantsDTO) ([]entity.ID, *entity.Error)
 	UpdateOne(id entity.ID, version int32, updateVariantDTO UpdateVariantDTO) (*int32, *entity.Error)
+	SetPrice(id entity.ID, version int32, setPriceDTO SetPriceDTO) (*int32, *entity.Error)
+	ScheduleSalePrice(id entity.ID, version int32, scheduleSalePriceDTO ScheduleSalePriceDTO) (*entity.ID, *int32, *entity.Error)
+	ApplySalePrices(now time.Time, limit int) (int, *entity.Error)
+	Reserve(reserveDTO ReserveDTO) (*entity.Reservation, *entity.Error)
+	Commit(reservationID entity.ID) *entity.Error
+	Release(reservationID entity.ID) *entity.Error
+	ReleaseExpired(now time.Time, limit int) (int, *entity.Error)
+	PostMovement(id entity.ID, postMovementDTO PostMovementDTO) (*entity.StockMovement, *entity.Error)
 	Delete(id entity.ID, version int32) *entity.Error
+	Rebuild(id entity.ID) (*entity.Variant, *entity.Error)
+	Project(id entity.ID, events []*entity.StoredEvent) (*entity.Variant, *entity.Error)
+	RemoveAll() *entity.Error
 }
 
 //UseCase use case interface
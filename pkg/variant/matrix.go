@@ -0,0 +1,210 @@
+package variant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//GenerateVariantsDTO variants matrix DTO, the price is set on every generated variant
+type GenerateVariantsDTO struct {
+	Product entity.ID     `json:"product" validate:"required" structs:"product"`
+	Price   *entity.Money `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
+}
+
+//GenerateVariants create a variant for every combination of the product options values that has no variant yet with
+//a SKU from the template, the variants and their events are stored in a single transaction and delivered in a single batch
+func (s *Service) GenerateVariants(generateVariantsDTO GenerateVariantsDTO) ([]entity.ID, *entity.Error) {
+	if err := validator.New().Struct(generateVariantsDTO); err != nil {
+		errs := entity.Error{Op: "GenerateVariants", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	Timestamp := time.Now()
+
+	p, err := s.productRepo.FindOneByID(generateVariantsDTO.Product)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Product with id " + string(generateVariantsDTO.Product) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	errs := entity.Error{Op: "GenerateVariants", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+	if len(p.Options) == 0 {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Product", Error: "Product has no options"})
+	}
+
+	//Options stored before the bound may make more, the whole matrix is built and stored at once
+	if n := entity.OptionCombinations(p.Options); n > entity.MaxOptionCombinations {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Product", Error: fmt.Sprintf("Product options make more than %d combinations", entity.MaxOptionCombinations)})
+	}
+
+	if price := generateVariantsDTO.Price; price != nil && !entity.IsCurrency(price.Currency) {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Unknown currency " + price.Currency})
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, &errs
+	}
+
+	existing, err := s.productRepo.FindVariantsByProduct(p.ID)
+	if err != nil {
+		return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	exists := make(map[string]bool)
+	for _, v := range existing {
+		exists[entity.AttributesSignature(v.Attributes)] = true
+	}
+
+	var variants []*entity.Variant
+	var messages []*entity.Message
+	var changes []*entity.PriceChange
+	var IDs []entity.ID
+	skus := make(map[string]bool)
+
+	for _, attributes := range combinations(p.Options) {
+		if exists[entity.AttributesSignature(attributes)] {
+			continue
+		}
+
+		attributesErrs, err := s.checkAttributes(p, attributes)
+		if err != nil {
+			return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if len(attributesErrs) > 0 {
+			return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: attributesErrs}
+		}
+
+		ID := entity.NewID()
+		var version entity.Version = 1
+
+		payload := make(map[string]interface{})
+		payload["product"] = string(p.ID)
+		payload["attributes"] = attributes
+
+		variantMessages := []*entity.Message{{ID: string(ID), Type: "PRODUCT_VARIANT_DRAFT_CREATED", Version: version, Payload: payload, Timestamp: Timestamp}}
+
+		sku, err := s.generateSKU(p, attributes)
+		if err != nil {
+			return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if sku != "" {
+			sku, err = s.uniqueSKU(sku, skus)
+			if err != nil {
+				return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+			}
+			skus[sku] = true
+			version++
+
+			payload := make(map[string]interface{})
+			payload["sku"] = sku
+
+			variantMessages = append(variantMessages, &entity.Message{ID: string(ID), Type: "PRODUCT_VARIANT_SKU_UPDATED", Version: version, Payload: payload, Timestamp: Timestamp})
+		}
+
+		if generateVariantsDTO.Price != nil {
+			version++
+
+			payload := make(map[string]interface{})
+			payload["price"] = generateVariantsDTO.Price
+
+			variantMessages = append(variantMessages, &entity.Message{ID: string(ID), Type: "PRODUCT_VARIANT_PRICE_UPDATED", Version: version, Payload: payload, Timestamp: Timestamp})
+		}
+
+		variants = append(variants, &entity.Variant{
+			ID:         ID,
+			Version:    version,
+			Product:    p.ID,
+			SKU:        sku,
+			Price:      generateVariantsDTO.Price,
+			Attributes: attributes,
+			Signature:  entity.AttributesSignature(attributes),
+			CreatedAt:  Timestamp,
+			Edited:     version,
+		})
+		messages = append(messages, variantMessages...)
+		changes = append(changes, priceHistory(&entity.Variant{}, entity.NewStoredEvents("variant", variantMessages))...)
+		IDs = append(IDs, ID)
+	}
+
+	if len(variants) == 0 {
+		return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.NoUpdates, ErrorMessage: "All combinations have variants", Severity: logrus.InfoLevel}
+	}
+
+	c := &entity.Command{AggregateID: string(p.ID), Type: "GenerateVariants", Payload: structs.Map(generateVariantsDTO), Timestamp: Timestamp}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.CreateMany(variants); err != nil {
+			return err
+		}
+
+		if err := tx.AppendPriceHistory(changes); err != nil {
+			return err
+		}
+
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case ErrDuplicateAttributes, ErrDuplicateSKU, ErrDuplicateBarcode:
+		return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.ConcurrentModification, ErrorMessage: "Variants created concurrently, generate again", Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "GenerateVariants", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	aggregateIDs := make([]string, len(IDs))
+	for i, ID := range IDs {
+		aggregateIDs[i] = string(ID)
+	}
+
+	if err := s.outbox.DeliverMany(aggregateIDs); err != nil {
+		return IDs, &entity.Error{Op: "GenerateVariants", Kind: entity.DeliveryFailed, ErrorMessage: "Created, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return IDs, nil
+}
+
+//combinations cartesian product of the options values as variant attributes, in the options order
+func combinations(options []*entity.Option) []map[string]string {
+	result := []map[string]string{{}}
+
+	for _, o := range options {
+		var next []map[string]string
+		for _, partial := range result {
+			for _, value := range o.Values {
+				attributes := make(map[string]string, len(partial)+1)
+				for k, v := range partial {
+					attributes[k] = v
+				}
+				attributes[o.Name] = value
+
+				next = append(next, attributes)
+			}
+		}
+		result = next
+	}
+
+	return result
+}
@@ -0,0 +1,214 @@
+package variant
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//SetPriceDTO variant price in a price list DTO
+type SetPriceDTO struct {
+	PriceList entity.ID     `json:"priceList" validate:"required" structs:"priceList"`
+	Price     *entity.Money `json:"price" validate:"required" structs:"price"`
+}
+
+//SetPrice set the variant price in a price list, the price currency must be the list currency
+func (s *Service) SetPrice(ID entity.ID, v int32, setPriceDTO SetPriceDTO) (*int32, *entity.Error) {
+	if err := validator.New().Struct(setPriceDTO); err != nil {
+		errs := entity.Error{Op: "SetPrice", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	Timestamp := time.Now()
+	version := entity.Version(v)
+
+	variant, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "SetPrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "SetPrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	if variant.Stale(version) {
+		return nil, &entity.Error{Op: "SetPrice", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	}
+	version = variant.Version
+
+	errs := entity.Error{Op: "SetPrice", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+	priceList, err := s.priceListRepo.FindOneByID(setPriceDTO.PriceList)
+	switch err {
+	case nil:
+		if priceList.Currency != setPriceDTO.Price.Currency {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Price list " + priceList.Name + " prices are in " + priceList.Currency})
+		}
+	case entity.ErrNotFound:
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "PriceList", Error: "Price list with ID " + string(setPriceDTO.PriceList) + " doesn't Exist"})
+	default:
+		return nil, &entity.Error{Op: "SetPrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	if variant.Prices[string(setPriceDTO.PriceList)].Equal(setPriceDTO.Price) {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Price", Error: "Price already updated"})
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, &errs
+	}
+
+	version++
+
+	payload := make(map[string]interface{})
+	payload["price"] = setPriceDTO.Price
+	payload["priceList"] = string(setPriceDTO.PriceList)
+
+	m := &entity.Message{ID: string(ID), Type: "PRODUCT_VARIANT_PRICE_UPDATED", Version: version, Payload: payload, Timestamp: Timestamp}
+	c := &entity.Command{AggregateID: string(ID), Type: "SetVariantPrice", Payload: structs.Map(setPriceDTO), Timestamp: Timestamp}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updatedNum, err := tx.UpdatePrice(ID, setPriceDTO.PriceList, setPriceDTO.Price, variant.Version)
+		if err != nil {
+			return err
+		}
+
+		if updatedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		if err := tx.AppendPriceHistory(priceHistory(variant, entity.NewStoredEvents("variant", []*entity.Message{m}))); err != nil {
+			return err
+		}
+
+		return tx.StoreMessages(*commandID, []*entity.Message{m})
+	})
+	switch err {
+	case entity.ErrVersionConflict:
+		return nil, &entity.Error{Op: "SetPrice", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "SetPrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	Version := int32(version)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &Version, &entity.Error{Op: "SetPrice", Kind: entity.DeliveryFailed, ErrorMessage: "Updated, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return &Version, nil
+}
+
+//EffectivePriceDTO price resolution DTO, region is an ISO-3166 alpha-2 country code
+type EffectivePriceDTO struct {
+	Region   string `validate:"omitempty,len=2"`
+	Currency string `validate:"required,len=3"`
+}
+
+//EffectivePrice price a variant sells at in a region and currency, PriceList is nil for the variant base price
+//Price is the sale price while a sale is effective and the list price otherwise
+type EffectivePrice struct {
+	Variant   entity.ID         `json:"variant"`
+	Region    string            `json:"region,omitempty"`
+	PriceList *entity.PriceList `json:"priceList,omitempty"`
+	ListPrice *entity.Money     `json:"listPrice"`
+	SalePrice *entity.SalePrice `json:"salePrice,omitempty"`
+	Price     *entity.Money     `json:"price"`
+}
+
+//EffectivePrice resolve the variant price in a region and currency
+//
+//The price lists of the currency applying to the region are tried, lists restricted to the region first then by priority,
+//the variant base price is used if none of them prices the variant and it's in the currency.
+func (s *Service) EffectivePrice(ID entity.ID, effectivePriceDTO EffectivePriceDTO) (*EffectivePrice, *entity.Error) {
+	if err := validator.New().Struct(effectivePriceDTO); err != nil {
+		errs := entity.Error{Op: "EffectivePrice", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	region := strings.ToUpper(effectivePriceDTO.Region)
+
+	variant, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "EffectivePrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "EffectivePrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	lists, err := s.priceListRepo.FindByCurrency(effectivePriceDTO.Currency)
+	if err != nil {
+		return nil, &entity.Error{Op: "EffectivePrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	p := resolvePrice(variant, lists, region, effectivePriceDTO.Currency, time.Now())
+	if p == nil {
+		return nil, &entity.Error{Op: "EffectivePrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " has no price in " + effectivePriceDTO.Currency), Severity: logrus.InfoLevel}
+	}
+
+	return p, nil
+}
+
+//resolvePrice pick the variant price from the lists of the currency and apply the sale effective at now,
+//nil if the variant has no price in the currency
+func resolvePrice(v *entity.Variant, lists []*entity.PriceList, region string, currency string, now time.Time) *EffectivePrice {
+	var candidates []*entity.PriceList
+	for _, l := range lists {
+		if l.Currency == currency && l.AppliesTo(region) && v.Prices[string(l.ID)] != nil {
+			candidates = append(candidates, l)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Specific() != candidates[j].Specific() {
+			return candidates[i].Specific()
+		}
+
+		return candidates[i].Priority > candidates[j].Priority
+	})
+
+	var p *EffectivePrice
+	switch {
+	case len(candidates) > 0:
+		p = &EffectivePrice{Variant: v.ID, Region: region, PriceList: candidates[0], ListPrice: v.Prices[string(candidates[0].ID)]}
+		p.SalePrice = v.ActiveSalePrice(candidates[0].ID, now)
+	case v.Price != nil && v.Price.Currency == currency:
+		p = &EffectivePrice{Variant: v.ID, Region: region, ListPrice: v.Price}
+		p.SalePrice = v.ActiveSalePrice("", now)
+	default:
+		return nil
+	}
+
+	p.Price = p.ListPrice
+	if p.SalePrice != nil {
+		p.Price = p.SalePrice.Price
+	}
+
+	return p
+}
@@ -0,0 +1,192 @@
+package variant
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//priceHistory the effective price changes caused by folding events over the variant, the variant is left untouched
+//
+//The effective price of a price list is its list price or the lowest started sale price of the list.
+func priceHistory(v *entity.Variant, events []*entity.StoredEvent) []*entity.PriceChange {
+	var changes []*entity.PriceChange
+
+	v = clone(v)
+	prices := effectivePrices(v)
+
+	for _, e := range events {
+		apply(v, e)
+		v.Version = e.Version
+
+		after := effectivePrices(v)
+
+		var priceLists []string
+		for priceList := range after {
+			priceLists = append(priceLists, priceList)
+		}
+		sort.Strings(priceLists)
+
+		for _, priceList := range priceLists {
+			p := after[priceList]
+			if p.Equal(prices[priceList]) {
+				continue
+			}
+
+			changes = append(changes, &entity.PriceChange{
+				ID:        entity.NewID(),
+				Variant:   v.ID,
+				PriceList: entity.ID(priceList),
+				Price:     p,
+				Sale:      !p.Equal(listPrice(v, entity.ID(priceList))),
+				At:        e.Timestamp,
+				Version:   e.Version,
+			})
+		}
+
+		prices = after
+	}
+
+	return changes
+}
+
+//effectivePrices price list id to effective price, "" for the base price
+func effectivePrices(v *entity.Variant) map[string]*entity.Money {
+	prices := map[string]*entity.Money{}
+	if v.Price != nil {
+		prices[""] = v.Price
+	}
+	for priceList, p := range v.Prices {
+		prices[priceList] = p
+	}
+
+	for _, sale := range v.SalePrices {
+		p, ok := prices[string(sale.PriceList)]
+		if ok && sale.Status == entity.SalePriceActive && sale.Price.Amount < p.Amount {
+			prices[string(sale.PriceList)] = sale.Price
+		}
+	}
+
+	return prices
+}
+
+//listPrice variant price in the price list, the base price for ""
+func listPrice(v *entity.Variant, priceList entity.ID) *entity.Money {
+	if priceList == "" {
+		return v.Price
+	}
+
+	return v.Prices[string(priceList)]
+}
+
+//clone copy of the variant prices and sale prices that can be folded without changing v
+func clone(v *entity.Variant) *entity.Variant {
+	c := *v
+
+	c.Prices = map[string]*entity.Money{}
+	for priceList, p := range v.Prices {
+		c.Prices[priceList] = p
+	}
+
+	c.SalePrices = nil
+	for _, sale := range v.SalePrices {
+		s := *sale
+		c.SalePrices = append(c.SalePrices, &s)
+	}
+
+	return &c
+}
+
+//LowestPriceDTO lowest price DTO, without price list the variant base price is used
+type LowestPriceDTO struct {
+	PriceList entity.ID `validate:"omitempty"`
+	Days      int       `validate:"omitempty,min=1,max=365"`
+}
+
+//LowestPrice variant current price with the lowest price applied in the days before the current sale started,
+//or before now without sale, as required when advertising a price reduction
+type LowestPrice struct {
+	Variant   entity.ID         `json:"variant"`
+	PriceList entity.ID         `json:"priceList,omitempty"`
+	ListPrice *entity.Money     `json:"listPrice"`
+	SalePrice *entity.SalePrice `json:"salePrice,omitempty"`
+	Price     *entity.Money     `json:"price"`
+	Lowest    *entity.Money     `json:"lowest"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+}
+
+//LowestPrice current variant price and its lowest price over the previous days, 30 days by default
+func (s *Service) LowestPrice(ID entity.ID, lowestPriceDTO LowestPriceDTO) (*LowestPrice, *entity.Error) {
+	if err := validator.New().Struct(lowestPriceDTO); err != nil {
+		errs := entity.Error{Op: "LowestPrice", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	days := lowestPriceDTO.Days
+	if days == 0 {
+		days = 30
+	}
+
+	v, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "LowestPrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "LowestPrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	now := time.Now()
+	l := &LowestPrice{Variant: ID, PriceList: lowestPriceDTO.PriceList, ListPrice: listPrice(v, lowestPriceDTO.PriceList), To: now}
+	if l.ListPrice == nil {
+		return nil, &entity.Error{Op: "LowestPrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " has no price"), Severity: logrus.InfoLevel}
+	}
+
+	l.Price = l.ListPrice
+	if sale := v.ActiveSalePrice(lowestPriceDTO.PriceList, now); sale != nil {
+		l.SalePrice = sale
+		l.Price = sale.Price
+		l.To = sale.ValidFrom
+	}
+	l.From = l.To.AddDate(0, 0, -days)
+
+	changes, err := s.storeRepo.FindPriceHistory(ID, lowestPriceDTO.PriceList, l.From, l.To)
+	if err != nil {
+		return nil, &entity.Error{Op: "LowestPrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	l.Lowest = lowest(changes, l.ListPrice)
+
+	return l, nil
+}
+
+//lowest lowest price of the changes in the currency of current, current without changes
+func lowest(changes []*entity.PriceChange, current *entity.Money) *entity.Money {
+	var low *entity.Money
+	for _, c := range changes {
+		if c.Price.Currency != current.Currency {
+			continue
+		}
+
+		if low == nil || c.Price.Amount < low.Amount {
+			low = c.Price
+		}
+	}
+
+	if low == nil {
+		return current
+	}
+
+	return low
+}
@@ -0,0 +1,177 @@
+package variant
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//SnapshotEvent type of the event holding the variant document stored before the event store
+const SnapshotEvent = "PRODUCT_VARIANT_SNAPSHOT_TAKEN"
+
+//NewSnapshot create the snapshot event of a variant stored before the event store
+func NewSnapshot(v *entity.Variant, at time.Time) *entity.StoredEvent {
+	return &entity.StoredEvent{
+		ID:            entity.NewID(),
+		AggregateType: "variant",
+		AggregateID:   string(v.ID),
+		Type:          SnapshotEvent,
+		Version:       v.Version,
+		Payload:       map[string]interface{}{"variant": v},
+		Timestamp:     at,
+	}
+}
+
+//Rehydrate rebuild a variant by folding its events in version order
+//returns entity.ErrNotFound without events and entity.ErrAggregateDeleted if the variant is deleted
+func Rehydrate(events []*entity.StoredEvent) (*entity.Variant, error) {
+	if len(events) == 0 {
+		return nil, entity.ErrNotFound
+	}
+
+	v := &entity.Variant{}
+
+	for i, e := range events {
+		//A snapshot seeds the aggregate stored before its events were recorded, it starts at the document version
+		if i == 0 && e.Type == SnapshotEvent {
+			if err := e.Decode("variant", v); err != nil {
+				return nil, err
+			}
+			v.Version = e.Version
+			continue
+		}
+
+		if e.Version != v.Version+1 {
+			return nil, fmt.Errorf("Variant %s expected version %d got %d", e.AggregateID, v.Version+1, e.Version)
+		}
+
+		if e.Type == "PRODUCT_VARIANT_DELETED" {
+			return nil, entity.ErrAggregateDeleted
+		}
+
+		apply(v, e)
+		v.Version = e.Version
+		if !systemEvent(e.Type) {
+			v.Edited = e.Version
+		}
+	}
+
+	return v, nil
+}
+
+//apply apply a single event to the variant
+func apply(v *entity.Variant, e *entity.StoredEvent) {
+	switch e.Type {
+	case "PRODUCT_VARIANT_DRAFT_CREATED":
+		v.ID = entity.ID(e.AggregateID)
+		v.Product = entity.ID(e.String("product"))
+		v.Attributes = attributes(e.Payload["attributes"])
+		v.Signature = entity.AttributesSignature(v.Attributes)
+		v.CreatedAt = e.Timestamp
+	case "PRODUCT_VARIANT_SKU_UPDATED":
+		v.SKU = e.String("sku")
+	case "PRODUCT_VARIANT_QUANTITY_UPDATED":
+		v.Quantity = int(e.Int("quantity"))
+		stockAt(v, entity.DefaultLocation).Quantity = v.Quantity
+	case "PRODUCT_VARIANT_STOCK_MOVED":
+		v.Quantity += int(e.Int("quantity"))
+		stockAt(v, eventLocation(e)).Quantity += int(e.Int("quantity"))
+	case "PRODUCT_VARIANT_PRICE_UPDATED":
+		if priceList := e.String("priceList"); priceList != "" {
+			if v.Prices == nil {
+				v.Prices = map[string]*entity.Money{}
+			}
+			v.Prices[priceList] = e.Money("price")
+		} else {
+			v.Price = e.Money("price")
+		}
+	case "PRODUCT_VARIANT_IMAGE_UPDATED":
+		v.Image = e.String("image")
+	case "PRODUCT_VARIANT_ATTRIBUTES_UPDATED":
+		v.Attributes = attributes(e.Payload["attributes"])
+		v.Signature = entity.AttributesSignature(v.Attributes)
+	case "PRODUCT_VARIANT_BARCODE_UPDATED":
+		v.Barcode = e.String("barcode")
+		v.BarcodeType = e.String("barcodeType")
+		v.GTIN = e.String("gtin")
+	case "PRODUCT_VARIANT_STOCK_RESERVED":
+		stock := stockAt(v, eventLocation(e))
+		v.Quantity -= int(e.Int("quantity"))
+		v.Reserved += int(e.Int("quantity"))
+		stock.Quantity -= int(e.Int("quantity"))
+		stock.Reserved += int(e.Int("quantity"))
+	case "PRODUCT_VARIANT_STOCK_RELEASED":
+		stock := stockAt(v, eventLocation(e))
+		v.Quantity += int(e.Int("quantity"))
+		v.Reserved -= int(e.Int("quantity"))
+		stock.Quantity += int(e.Int("quantity"))
+		stock.Reserved -= int(e.Int("quantity"))
+	case "PRODUCT_VARIANT_STOCK_COMMITTED":
+		v.Reserved -= int(e.Int("quantity"))
+		stockAt(v, eventLocation(e)).Reserved -= int(e.Int("quantity"))
+	case "PRODUCT_VARIANT_SALE_PRICE_SCHEDULED":
+		v.SalePrices = append(v.SalePrices, &entity.SalePrice{
+			ID:        entity.ID(e.String("id")),
+			PriceList: entity.ID(e.String("priceList")),
+			Price:     e.Money("price"),
+			ValidFrom: e.Time("validFrom"),
+			ValidTo:   e.Time("validTo"),
+			Status:    entity.SalePriceScheduled,
+		})
+	case "PRODUCT_VARIANT_SALE_PRICE_STARTED":
+		for _, sale := range v.SalePrices {
+			if sale.ID == entity.ID(e.String("id")) {
+				sale.Status = entity.SalePriceActive
+			}
+		}
+	case "PRODUCT_VARIANT_SALE_PRICE_ENDED":
+		var salePrices []*entity.SalePrice
+		for _, sale := range v.SalePrices {
+			if sale.ID != entity.ID(e.String("id")) {
+				salePrices = append(salePrices, sale)
+			}
+		}
+		v.SalePrices = salePrices
+	}
+}
+
+//systemEvent reports whether the event changes the stock only or is a scheduled sale price transition,
+//they aren't merchandising edits
+func systemEvent(eventType string) bool {
+	switch eventType {
+	case "PRODUCT_VARIANT_STOCK_RESERVED", "PRODUCT_VARIANT_STOCK_RELEASED", "PRODUCT_VARIANT_STOCK_COMMITTED",
+		"PRODUCT_VARIANT_STOCK_MOVED", "PRODUCT_VARIANT_LOW_STOCK", "PRODUCT_VARIANT_OUT_OF_STOCK",
+		"PRODUCT_VARIANT_SALE_PRICE_STARTED", "PRODUCT_VARIANT_SALE_PRICE_ENDED":
+		return true
+	}
+
+	return false
+}
+
+//attributes attributes payload as decoded from the store or from json messages
+func attributes(value interface{}) map[string]string {
+	result := map[string]string{}
+
+	switch a := value.(type) {
+	case map[string]string:
+		for k, v := range a {
+			result[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range a {
+			result[k], _ = v.(string)
+		}
+	case primitive.M:
+		for k, v := range a {
+			result[k], _ = v.(string)
+		}
+	case primitive.D:
+		for _, e := range a {
+			result[e.Key], _ = e.Value.(string)
+		}
+	}
+
+	return result
+}
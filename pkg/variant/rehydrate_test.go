@@ -0,0 +1,209 @@
+package variant_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/variant"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRehydrate(t *testing.T) {
+	ID := entity.NewID()
+	productID := entity.NewID()
+	createdAt := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	validFrom := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	validTo := time.Date(2020, 7, 8, 0, 0, 0, 0, time.UTC)
+	created := &entity.StoredEvent{
+		AggregateID: string(ID),
+		Type:        "PRODUCT_VARIANT_DRAFT_CREATED",
+		Version:     1,
+		Payload:     map[string]interface{}{"product": string(productID), "attributes": primitive.M{"size": "M"}},
+		Timestamp:   createdAt,
+	}
+	scheduled := &entity.StoredEvent{Type: "PRODUCT_VARIANT_SALE_PRICE_SCHEDULED", Payload: map[string]interface{}{
+		"id":        "sale-1",
+		"priceList": "retail",
+		"price":     primitive.M{"amount": int64(900), "currency": "USD"},
+		"validFrom": primitive.NewDateTimeFromTime(validFrom),
+		"validTo":   validTo.Format(time.RFC3339),
+	}}
+	sale := func(status string) []*entity.SalePrice {
+		// Store dates are decoded in local time
+		return []*entity.SalePrice{{ID: "sale-1", PriceList: "retail", Price: &entity.Money{Amount: 900, Currency: "USD"}, ValidFrom: validFrom.Local(), ValidTo: validTo, Status: status}}
+	}
+	quantity := func(q int) *entity.StoredEvent {
+		return &entity.StoredEvent{Type: "PRODUCT_VARIANT_QUANTITY_UPDATED", Payload: map[string]interface{}{"quantity": int32(q)}}
+	}
+
+	// edited is the version of the last merchandising event, stock events and sale price transitions don't move it
+	tests := []struct {
+		name   string
+		events []*entity.StoredEvent
+		edited entity.Version
+		want   func(v *entity.Variant)
+	}{
+		{"draft created", nil, 1, func(v *entity.Variant) {}},
+		{"sku", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_SKU_UPDATED", Payload: map[string]interface{}{"sku": "ACME-SHIRT-M"}}}, 2, func(v *entity.Variant) {
+			v.SKU = "ACME-SHIRT-M"
+		}},
+		{"quantity", []*entity.StoredEvent{quantity(10)}, 2, func(v *entity.Variant) {
+			v.Quantity = 10
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 10}}
+		}},
+		{"stock moved", []*entity.StoredEvent{
+			quantity(10),
+			{Type: "PRODUCT_VARIANT_STOCK_MOVED", Payload: map[string]interface{}{"quantity": float64(4), "location": "cairo"}},
+		}, 2, func(v *entity.Variant) {
+			v.Quantity = 14
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 10}, "cairo": {Quantity: 4}}
+		}},
+		{"price", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_PRICE_UPDATED", Payload: map[string]interface{}{"price": primitive.M{"amount": int64(1500), "currency": "USD"}}}}, 2, func(v *entity.Variant) {
+			v.Price = &entity.Money{Amount: 1500, Currency: "USD"}
+		}},
+		{"price list price", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_PRICE_UPDATED", Payload: map[string]interface{}{"priceList": "retail", "price": primitive.M{"amount": int64(1200), "currency": "USD"}}}}, 2, func(v *entity.Variant) {
+			v.Prices = map[string]*entity.Money{"retail": {Amount: 1200, Currency: "USD"}}
+		}},
+		{"legacy price", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_PRICE_UPDATED", Payload: map[string]interface{}{"price": int32(15)}}}, 2, func(v *entity.Variant) {
+			v.Price = entity.LegacyMoney(15)
+		}},
+		{"legacy zero price", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_PRICE_UPDATED", Payload: map[string]interface{}{"price": int32(0)}}}, 2, func(v *entity.Variant) {}},
+		{"image", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_IMAGE_UPDATED", Payload: map[string]interface{}{"image": "https://cdn/m.png"}}}, 2, func(v *entity.Variant) {
+			v.Image = "https://cdn/m.png"
+		}},
+		{"attributes", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_ATTRIBUTES_UPDATED", Payload: map[string]interface{}{"attributes": primitive.D{{Key: "size", Value: "L"}, {Key: "color", Value: "Red"}}}}}, 2, func(v *entity.Variant) {
+			v.Attributes = map[string]string{"size": "L", "color": "Red"}
+			v.Signature = entity.AttributesSignature(v.Attributes)
+		}},
+		{"barcode", []*entity.StoredEvent{{Type: "PRODUCT_VARIANT_BARCODE_UPDATED", Payload: map[string]interface{}{"barcode": "4006381333931", "barcodeType": "EAN-13", "gtin": "04006381333931"}}}, 2, func(v *entity.Variant) {
+			v.Barcode = "4006381333931"
+			v.BarcodeType = "EAN-13"
+			v.GTIN = "04006381333931"
+		}},
+		{"stock reserved", []*entity.StoredEvent{
+			quantity(10),
+			{Type: "PRODUCT_VARIANT_STOCK_RESERVED", Payload: map[string]interface{}{"quantity": int64(3)}},
+		}, 2, func(v *entity.Variant) {
+			v.Quantity = 7
+			v.Reserved = 3
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 7, Reserved: 3}}
+		}},
+		{"stock released", []*entity.StoredEvent{
+			quantity(10),
+			{Type: "PRODUCT_VARIANT_STOCK_RESERVED", Payload: map[string]interface{}{"quantity": int64(3)}},
+			{Type: "PRODUCT_VARIANT_STOCK_RELEASED", Payload: map[string]interface{}{"quantity": int64(2)}},
+		}, 2, func(v *entity.Variant) {
+			v.Quantity = 9
+			v.Reserved = 1
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 9, Reserved: 1}}
+		}},
+		{"stock committed", []*entity.StoredEvent{
+			quantity(10),
+			{Type: "PRODUCT_VARIANT_STOCK_RESERVED", Payload: map[string]interface{}{"quantity": int64(3)}},
+			{Type: "PRODUCT_VARIANT_STOCK_COMMITTED", Payload: map[string]interface{}{"quantity": int64(3)}},
+		}, 2, func(v *entity.Variant) {
+			v.Quantity = 7
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 7}}
+		}},
+		{"stock alerts", []*entity.StoredEvent{
+			quantity(1),
+			{Type: "PRODUCT_VARIANT_LOW_STOCK", Payload: map[string]interface{}{"quantity": int64(1)}},
+			{Type: "PRODUCT_VARIANT_OUT_OF_STOCK", Payload: map[string]interface{}{"quantity": int64(0)}},
+		}, 2, func(v *entity.Variant) {
+			v.Quantity = 1
+			v.Stock = map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 1}}
+		}},
+		{"sale price scheduled", []*entity.StoredEvent{scheduled}, 2, func(v *entity.Variant) {
+			v.SalePrices = sale(entity.SalePriceScheduled)
+		}},
+		{"sale price started", []*entity.StoredEvent{
+			scheduled,
+			{Type: "PRODUCT_VARIANT_SALE_PRICE_STARTED", Payload: map[string]interface{}{"id": "sale-1"}},
+		}, 2, func(v *entity.Variant) {
+			v.SalePrices = sale(entity.SalePriceActive)
+		}},
+		{"sale price ended", []*entity.StoredEvent{
+			scheduled,
+			{Type: "PRODUCT_VARIANT_SALE_PRICE_ENDED", Payload: map[string]interface{}{"id": "sale-1"}},
+		}, 2, func(v *entity.Variant) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []*entity.StoredEvent{created}
+			for i, e := range tt.events {
+				e := *e
+				e.AggregateID = string(ID)
+				e.Version = entity.Version(i + 2)
+				events = append(events, &e)
+			}
+
+			want := &entity.Variant{
+				ID:         ID,
+				Product:    productID,
+				Version:    entity.Version(len(events)),
+				Attributes: map[string]string{"size": "M"},
+				Signature:  entity.AttributesSignature(map[string]string{"size": "M"}),
+				CreatedAt:  createdAt,
+				Edited:     tt.edited,
+			}
+			tt.want(want)
+
+			v, err := variant.Rehydrate(events)
+
+			assert.Nil(t, err)
+			assert.Equal(t, want, v)
+		})
+	}
+}
+
+func TestRehydrateSnapshot(t *testing.T) {
+	ID := entity.NewID()
+	createdAt := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	stored := &entity.Variant{ID: ID, Product: entity.NewID(), Version: 6, Edited: 4, SKU: "ACME-SHIRT-M", Quantity: 10, Attributes: map[string]string{"size": "M"}, CreatedAt: createdAt}
+
+	//Snapshots are read back from the store as documents
+	b, err := bson.Marshal(stored)
+	assert.Nil(t, err)
+	var document primitive.D
+	assert.Nil(t, bson.Unmarshal(b, &document))
+
+	snapshot := variant.NewSnapshot(stored, createdAt.Add(time.Hour))
+	snapshot.Payload["variant"] = document
+
+	v, err := variant.Rehydrate([]*entity.StoredEvent{
+		snapshot,
+		{AggregateID: string(ID), Type: "PRODUCT_VARIANT_STOCK_MOVED", Version: 7, Payload: map[string]interface{}{"quantity": int64(2), "location": "cairo"}},
+	})
+
+	want := *stored
+	want.Version = 7
+	want.Quantity = 12
+	want.Stock = map[string]*entity.Stock{"cairo": {Quantity: 2}}
+
+	assert.Nil(t, err)
+	assert.Equal(t, &want, v)
+}
+
+func TestRehydrateErrors(t *testing.T) {
+	ID := string(entity.NewID())
+
+	_, err := variant.Rehydrate(nil)
+	assert.Equal(t, entity.ErrNotFound, err)
+
+	_, err = variant.Rehydrate([]*entity.StoredEvent{
+		{AggregateID: ID, Type: "PRODUCT_VARIANT_DRAFT_CREATED", Version: 1},
+		{AggregateID: ID, Type: "PRODUCT_VARIANT_DELETED", Version: 2},
+	})
+	assert.Equal(t, entity.ErrAggregateDeleted, err)
+
+	// A missing version can't be folded over
+	_, err = variant.Rehydrate([]*entity.StoredEvent{
+		{AggregateID: ID, Type: "PRODUCT_VARIANT_DRAFT_CREATED", Version: 1},
+		{AggregateID: ID, Type: "PRODUCT_VARIANT_SKU_UPDATED", Version: 3},
+	})
+	assert.NotNil(t, err)
+}
@@ -2,32 +2,151 @@ package variant
 
 import (
 	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/markus-azer/products-service/lib/mongodb"
 	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/outbox"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //MongoRepository mongodb repo
 type MongoRepository struct {
-	db *mongo.Database
+	db     *mongo.Database
+	ctx    context.Context
+	events eventstore.StoreRepository
+	outbox outbox.StoreRepository
 }
 
-//NewMongoRepository create new repository
-func NewMongoRepository(db *mongo.Database) StoreRepository {
-	return &MongoRepository{
-		db: db,
+//NewMongoRepository create new repository, exits if a unique index can't be created
+//the service doesn't run without the uniqueness of attributes, SKUs and barcodes enforced by the database
+func NewMongoRepository(db *mongo.Database, events eventstore.StoreRepository, outbox outbox.StoreRepository) StoreRepository {
+	r := &MongoRepository{
+		db:     db,
+		ctx:    context.TODO(),
+		events: events,
+		outbox: outbox,
 	}
+	if err := r.createIndexes(); err != nil {
+		log.Fatalln("Error on creating variants unique indexes, run cmd/migrate to report the duplicates", err)
+	}
+
+	return r
+}
+
+//signatureIndex unique attributes signature per product, variants not migrated yet have no signature
+const signatureIndex = "product_signature"
+
+//SignatureIndex the unique attributes signature per product index
+//cmd/migrate creates it before signing the variants so the duplicates are reported instead of signed
+func SignatureIndex() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{primitive.E{Key: "product", Value: 1}, primitive.E{Key: "signature", Value: 1}},
+		Options: options.Index().SetName(signatureIndex).SetUnique(true).SetPartialFilterExpression(bson.M{"signature": bson.M{"$exists": true}}),
+	}
+}
+
+//skuIndex unique SKUs, variants may have no SKU
+const skuIndex = "sku"
+
+//gtinIndex unique barcodes, variants may have no barcode
+const gtinIndex = "gtin"
+
+//createIndexes indexes supporting the sale prices scheduler, the unique attributes per product, SKUs and barcodes
+//returns the errors of the unique indexes, each one is created on its own so a failing one doesn't prevent the others
+func (r *MongoRepository) createIndexes() error {
+	coll := r.db.Collection("variants")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "salePrices.validFrom", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{primitive.E{Key: "salePrices.validTo", Value: 1}}, Options: options.Index().SetSparse(true)},
+	}
+
+	if _, err := coll.Indexes().CreateMany(r.ctx, models); err != nil {
+		log.Println("Error on creating variants indexes", err)
+	}
+
+	unique := []mongo.IndexModel{
+		SignatureIndex(),
+		{Keys: bson.D{primitive.E{Key: "sku", Value: 1}}, Options: options.Index().SetName(skuIndex).SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{primitive.E{Key: "gtin", Value: 1}}, Options: options.Index().SetName(gtinIndex).SetUnique(true).SetSparse(true)},
+	}
+
+	var failed []string
+	for _, model := range unique {
+		if _, err := coll.Indexes().CreateOne(r.ctx, model); err != nil {
+			failed = append(failed, *model.Options.Name+": "+err.Error())
+		}
+	}
+
+	//A variant event changes at most one effective price
+	history := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "variant", Value: 1}, primitive.E{Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{primitive.E{Key: "variant", Value: 1}, primitive.E{Key: "priceList", Value: 1}, primitive.E{Key: "at", Value: 1}}},
+	}
+
+	if _, err := r.db.Collection("variant-price-history").Indexes().CreateMany(r.ctx, history); err != nil {
+		log.Println("Error on creating variant price history indexes", err)
+	}
+
+	//Pending reservations are swept once expired
+	reservations := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "status", Value: 1}, primitive.E{Key: "expiresAt", Value: 1}}},
+	}
+
+	if _, err := r.db.Collection("reservations").Indexes().CreateMany(r.ctx, reservations); err != nil {
+		log.Println("Error on creating reservations indexes", err)
+	}
+
+	//The ledger is listed per variant latest first
+	movements := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "variant", Value: 1}, primitive.E{Key: "version", Value: -1}}},
+	}
+
+	if _, err := r.db.Collection("stock-movements").Indexes().CreateMany(r.ctx, movements); err != nil {
+		log.Println("Error on creating stock movements indexes", err)
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+
+	return nil
 }
 
-//TODO: USE Sessions
+//WithTransaction run fn in a transaction, the repository passed to fn is bound to the transaction session
+func (r *MongoRepository) WithTransaction(fn func(tx StoreRepository) error) error {
+	session, err := r.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(r.ctx)
+
+	_, err = session.WithTransaction(r.ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(&MongoRepository{db: r.db, ctx: sessCtx, events: r.events.WithContext(sessCtx), outbox: r.outbox.WithContext(sessCtx)})
+	})
+
+	return err
+}
+
+//StoreMessages append messages produced by a command to the event store and persistence them in the outbox,
+//they are published to kafka by the outbox relay
+func (r *MongoRepository) StoreMessages(commandID entity.ID, messages []*entity.Message) error {
+	return outbox.StoreMessages(r.events, r.outbox, "variant", "products", commandID, messages)
+}
 
 //FindOneByID find Variant by Id
 func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Variant, error) {
 	result := entity.Variant{}
 	coll := r.db.Collection("variants")
-	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+	err := coll.FindOne(r.ctx, bson.M{"_id": id}).Decode(&result)
 
 	switch err {
 	case nil:
@@ -39,19 +158,47 @@ func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Variant, error) {
 	}
 }
 
-//FindOneByAttribute find Variant by product id and attributes
+//FindOneByAttribute find the Variant of the product with exactly the attributes
 func (r *MongoRepository) FindOneByAttribute(product entity.ID, attributes map[string]string) (*entity.Variant, error) {
 	result := entity.Variant{}
 	coll := r.db.Collection("variants")
 
 	query := bson.M{}
 	query["product"] = product
-	// query["$size"] = bson.M{"$objectToArray": "$purchase_record"}
-	//TODO: Add attributes
-	for k, v := range attributes {
-		query["attributes."+k] = v
+	query["signature"] = entity.AttributesSignature(attributes)
+	err := coll.FindOne(r.ctx, query).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindOneBySKU find Variant by SKU
+func (r *MongoRepository) FindOneBySKU(sku string) (*entity.Variant, error) {
+	result := entity.Variant{}
+	coll := r.db.Collection("variants")
+	err := coll.FindOne(r.ctx, bson.M{"sku": sku}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
 	}
-	err := coll.FindOne(context.TODO(), query).Decode(&result)
+}
+
+//FindOneByGTIN find Variant by its barcode padded to 14 digits
+func (r *MongoRepository) FindOneByGTIN(gtin string) (*entity.Variant, error) {
+	result := entity.Variant{}
+	coll := r.db.Collection("variants")
+	err := coll.FindOne(r.ctx, bson.M{"gtin": gtin}).Decode(&result)
 
 	switch err {
 	case nil:
@@ -63,27 +210,105 @@ func (r *MongoRepository) FindOneByAttribute(product entity.ID, attributes map[s
 	}
 }
 
-//StoreCommand persistence commands
+//FindDueSalePrices find variants with a scheduled sale price to start or an active one to end at now
+func (r *MongoRepository) FindDueSalePrices(now time.Time, limit int) ([]*entity.Variant, error) {
+	coll := r.db.Collection("variants")
+
+	query := bson.M{"salePrices": bson.M{"$elemMatch": bson.M{"$or": bson.A{
+		bson.M{"status": entity.SalePriceScheduled, "validFrom": bson.M{"$lte": now}},
+		bson.M{"validTo": bson.M{"$lte": now}},
+	}}}}
+
+	cur, err := coll.Find(r.ctx, query, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	variants := []*entity.Variant{}
+	if err := cur.All(r.ctx, &variants); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+//FindPriceHistory find the price changes of a variant price list between from and to, with the change in effect at from
+func (r *MongoRepository) FindPriceHistory(id entity.ID, priceList entity.ID, from time.Time, to time.Time) ([]*entity.PriceChange, error) {
+	coll := r.db.Collection("variant-price-history")
+
+	changes := []*entity.PriceChange{}
+
+	previous := entity.PriceChange{}
+	err := coll.FindOne(r.ctx, bson.M{"variant": id, "priceList": priceList, "at": bson.M{"$lte": from}}, options.FindOne().SetSort(bson.D{primitive.E{Key: "at", Value: -1}, primitive.E{Key: "version", Value: -1}})).Decode(&previous)
+	switch err {
+	case nil:
+		changes = append(changes, &previous)
+	case mongo.ErrNoDocuments:
+	default:
+		return nil, err
+	}
+
+	cur, err := coll.Find(r.ctx, bson.M{"variant": id, "priceList": priceList, "at": bson.M{"$gt": from, "$lt": to}}, options.Find().SetSort(bson.M{"at": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	window := []*entity.PriceChange{}
+	if err := cur.All(r.ctx, &window); err != nil {
+		return nil, err
+	}
+
+	return append(changes, window...), nil
+}
+
+//StoreCommand persistence commands, returns the command id
 func (r *MongoRepository) StoreCommand(c *entity.Command) (*entity.ID, error) {
 	coll := r.db.Collection("commands-variant")
 
-	result, err := coll.InsertOne(context.TODO(), c)
+	if c.ID == "" {
+		c.ID = entity.NewID()
+	}
+
+	if _, err := coll.InsertOne(r.ctx, c); err != nil {
+		return nil, err
+	}
+
+	return &c.ID, nil
+}
+
+//FindCommands find commands by id
+func (r *MongoRepository) FindCommands(ids []entity.ID) ([]*entity.Command, error) {
+	coll := r.db.Collection("commands-variant")
 
+	cur, err := coll.Find(r.ctx, bson.M{"_id": bson.M{"$in": ids}})
 	if err != nil {
 		return nil, err
 	}
+	defer cur.Close(r.ctx)
 
-	str := result.InsertedID.(primitive.ObjectID).String()
-	id := entity.ID(str)
+	commands := []*entity.Command{}
+	if err := cur.All(r.ctx, &commands); err != nil {
+		return nil, err
+	}
 
-	return &id, nil
+	return commands, nil
 }
 
 //Create create new Variant
 func (r *MongoRepository) Create(variant *entity.Variant) (*entity.ID, error) {
 	coll := r.db.Collection("variants")
 
-	result, err := coll.InsertOne(context.TODO(), variant)
+	result, err := coll.InsertOne(r.ctx, variant)
+	switch {
+	case mongodb.IsDuplicateKeyErrorOn(err, signatureIndex):
+		return nil, ErrDuplicateAttributes
+	case mongodb.IsDuplicateKeyErrorOn(err, skuIndex):
+		return nil, ErrDuplicateSKU
+	case mongodb.IsDuplicateKeyErrorOn(err, gtinIndex):
+		return nil, ErrDuplicateBarcode
+	}
 
 	if err != nil {
 		return nil, err
@@ -95,16 +320,46 @@ func (r *MongoRepository) Create(variant *entity.Variant) (*entity.ID, error) {
 	return &id, err
 }
 
+//CreateMany create several variants at once
+func (r *MongoRepository) CreateMany(variants []*entity.Variant) error {
+	coll := r.db.Collection("variants")
+
+	var docs []interface{}
+	for _, v := range variants {
+		docs = append(docs, v)
+	}
+
+	_, err := coll.InsertMany(r.ctx, docs)
+	switch {
+	case mongodb.IsDuplicateKeyErrorOn(err, signatureIndex):
+		return ErrDuplicateAttributes
+	case mongodb.IsDuplicateKeyErrorOn(err, skuIndex):
+		return ErrDuplicateSKU
+	case mongodb.IsDuplicateKeyErrorOn(err, gtinIndex):
+		return ErrDuplicateBarcode
+	}
+
+	return err
+}
+
 //UpdateOne update an existing Variant
 func (r *MongoRepository) UpdateOne(id entity.ID, variant *entity.UpdateVariant, version entity.Version) (int, error) {
 
 	coll := r.db.Collection("variants")
 
 	result, err := coll.UpdateOne(
-		context.TODO(),
+		r.ctx,
 		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: version}},
 		bson.D{primitive.E{Key: "$set", Value: variant}},
 	)
+	switch {
+	case mongodb.IsDuplicateKeyErrorOn(err, signatureIndex):
+		return 0, ErrDuplicateAttributes
+	case mongodb.IsDuplicateKeyErrorOn(err, skuIndex):
+		return 0, ErrDuplicateSKU
+	case mongodb.IsDuplicateKeyErrorOn(err, gtinIndex):
+		return 0, ErrDuplicateBarcode
+	}
 
 	if err != nil {
 		return int(result.ModifiedCount), err
@@ -113,12 +368,56 @@ func (r *MongoRepository) UpdateOne(id entity.ID, variant *entity.UpdateVariant,
 	return int(result.ModifiedCount), nil
 }
 
+//UpdatePrice set the variant price in a price list, version is the current version and is incremented, the change is a merchandising edit
+func (r *MongoRepository) UpdatePrice(id entity.ID, priceList entity.ID, price *entity.Money, version entity.Version) (int, error) {
+	coll := r.db.Collection("variants")
+
+	result, err := coll.UpdateOne(
+		r.ctx,
+		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: version}},
+		bson.D{primitive.E{Key: "$set", Value: bson.M{"prices." + string(priceList): price, "_V": version + 1, "_E": version + 1}}},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+//UpdateSalePrices replace the variant sale prices, version is the current version and to the new one
+//edited for a merchandising change, the scheduler transitions only bump the version like the stock changes
+func (r *MongoRepository) UpdateSalePrices(id entity.ID, salePrices []*entity.SalePrice, version entity.Version, to entity.Version, edited bool) (int, error) {
+	coll := r.db.Collection("variants")
+
+	if salePrices == nil {
+		salePrices = []*entity.SalePrice{}
+	}
+
+	set := bson.M{"salePrices": salePrices, "_V": to}
+	if edited {
+		set["_E"] = to
+	}
+
+	result, err := coll.UpdateOne(
+		r.ctx,
+		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: version}},
+		bson.D{primitive.E{Key: "$set", Value: set}},
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
 //DeleteOne update an existing Variant
 func (r *MongoRepository) DeleteOne(id entity.ID, version entity.Version) (int, error) {
 	coll := r.db.Collection("variants")
 
 	result, err := coll.DeleteOne(
-		context.TODO(),
+		r.ctx,
 		bson.D{primitive.E{Key: "_id", Value: id}, primitive.E{Key: "_V", Value: version}},
 	)
 
@@ -129,3 +428,243 @@ func (r *MongoRepository) DeleteOne(id entity.ID, version entity.Version) (int,
 	return int(result.DeletedCount), nil
 
 }
+
+//AppendPriceHistory store price changes
+func (r *MongoRepository) AppendPriceHistory(changes []*entity.PriceChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	coll := r.db.Collection("variant-price-history")
+
+	var docs []interface{}
+	for _, c := range changes {
+		docs = append(docs, c)
+	}
+
+	_, err := coll.InsertMany(r.ctx, docs)
+
+	return err
+}
+
+//ReplacePriceHistory replace all the price changes of a variant with rebuilt ones
+func (r *MongoRepository) ReplacePriceHistory(id entity.ID, changes []*entity.PriceChange) error {
+	coll := r.db.Collection("variant-price-history")
+
+	if _, err := coll.DeleteMany(r.ctx, bson.M{"variant": id}); err != nil {
+		return err
+	}
+
+	return r.AppendPriceHistory(changes)
+}
+
+//ReserveStock move quantity from the available to the reserved stock of the location if enough is available,
+//returns the updated variant stock and version or ErrInsufficientStock
+func (r *MongoRepository) ReserveStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error) {
+	at := "stock." + string(location)
+
+	return r.incStock(bson.M{"_id": id, at + ".quantity": bson.M{"$gte": quantity}}, bson.M{"quantity": -quantity, "reserved": quantity, at + ".quantity": -quantity, at + ".reserved": quantity})
+}
+
+//ReleaseStock move quantity from the reserved back to the available stock of the location, returns the updated variant stock and version
+func (r *MongoRepository) ReleaseStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error) {
+	at := "stock." + string(location)
+
+	return r.incStock(bson.M{"_id": id, at + ".reserved": bson.M{"$gte": quantity}}, bson.M{"quantity": quantity, "reserved": -quantity, at + ".quantity": quantity, at + ".reserved": -quantity})
+}
+
+//CommitStock remove quantity from the reserved stock of the location, returns the updated variant stock and version
+func (r *MongoRepository) CommitStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error) {
+	at := "stock." + string(location)
+
+	return r.incStock(bson.M{"_id": id, at + ".reserved": bson.M{"$gte": quantity}}, bson.M{"reserved": -quantity, at + ".reserved": -quantity})
+}
+
+//MoveStock add the signed quantity to the available stock of the location, stock is removed only if enough is available
+//at the location, returns the updated variant stock and version or ErrInsufficientStock
+func (r *MongoRepository) MoveStock(id entity.ID, location entity.ID, quantity int) (*entity.Variant, error) {
+	at := "stock." + string(location)
+
+	filter := bson.M{"_id": id}
+	if quantity < 0 {
+		filter[at+".quantity"] = bson.M{"$gte": -quantity}
+	}
+
+	return r.incStock(filter, bson.M{"quantity": quantity, at + ".quantity": quantity})
+}
+
+//incStock increment the stock counters and the version of the variant matching filter in a single update
+//returns the variant stock counters and version after the update
+func (r *MongoRepository) incStock(filter bson.M, inc bson.M) (*entity.Variant, error) {
+	coll := r.db.Collection("variants")
+
+	inc["_V"] = 1
+
+	result := entity.Variant{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"product": 1, "_V": 1, "quantity": 1, "reserved": 1, "stock": 1})
+	err := coll.FindOneAndUpdate(r.ctx, filter, bson.M{"$inc": inc}, opts).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, ErrInsufficientStock
+	default:
+		return nil, err
+	}
+}
+
+//BumpVersion increment the variant version for an event without state changes, returns the number of updated variants
+func (r *MongoRepository) BumpVersion(id entity.ID, version entity.Version) (int, error) {
+	coll := r.db.Collection("variants")
+
+	result, err := coll.UpdateOne(r.ctx, bson.M{"_id": id, "_V": version}, bson.M{"$inc": bson.M{"_V": 1}})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+//CreateMovement append a movement to the stock ledger
+func (r *MongoRepository) CreateMovement(m *entity.StockMovement) error {
+	coll := r.db.Collection("stock-movements")
+
+	_, err := coll.InsertOne(r.ctx, m)
+
+	return err
+}
+
+//FindMovements find the stock movements of a variant latest first
+func (r *MongoRepository) FindMovements(id entity.ID, skip int, limit int) ([]*entity.StockMovement, error) {
+	coll := r.db.Collection("stock-movements")
+
+	opts := options.Find().SetSort(bson.M{"version": -1}).SetSkip(int64(skip)).SetLimit(int64(limit))
+
+	cur, err := coll.Find(r.ctx, bson.M{"variant": id}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	movements := []*entity.StockMovement{}
+	if err := cur.All(r.ctx, &movements); err != nil {
+		return nil, err
+	}
+
+	return movements, nil
+}
+
+//ReplaceMovements replace all the stock movements of a variant with rebuilt ones
+func (r *MongoRepository) ReplaceMovements(id entity.ID, movements []*entity.StockMovement) error {
+	coll := r.db.Collection("stock-movements")
+
+	if _, err := coll.DeleteMany(r.ctx, bson.M{"variant": id}); err != nil {
+		return err
+	}
+
+	if len(movements) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(movements))
+	for i, m := range movements {
+		docs[i] = m
+	}
+
+	_, err := coll.InsertMany(r.ctx, docs)
+
+	return err
+}
+
+//CreateReservation create new reservation
+func (r *MongoRepository) CreateReservation(reservation *entity.Reservation) error {
+	coll := r.db.Collection("reservations")
+
+	_, err := coll.InsertOne(r.ctx, reservation)
+
+	return err
+}
+
+//FindReservation find reservation by id
+func (r *MongoRepository) FindReservation(id entity.ID) (*entity.Reservation, error) {
+	result := entity.Reservation{}
+	coll := r.db.Collection("reservations")
+	err := coll.FindOne(r.ctx, bson.M{"_id": id}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindExpiredReservations find the pending reservations expired at now
+func (r *MongoRepository) FindExpiredReservations(now time.Time, limit int) ([]*entity.Reservation, error) {
+	coll := r.db.Collection("reservations")
+
+	query := bson.M{"status": entity.ReservationPending, "expiresAt": bson.M{"$lte": now}}
+
+	cur, err := coll.Find(r.ctx, query, options.Find().SetSort(bson.M{"expiresAt": 1}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(r.ctx)
+
+	reservations := []*entity.Reservation{}
+	if err := cur.All(r.ctx, &reservations); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+//UpdateReservationStatus change the reservation status if it's still from, returns the number of updated reservations
+func (r *MongoRepository) UpdateReservationStatus(id entity.ID, from string, to string) (int, error) {
+	coll := r.db.Collection("reservations")
+
+	result, err := coll.UpdateOne(r.ctx, bson.M{"_id": id, "status": from}, bson.M{"$set": bson.M{"status": to}})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+//ReplaceOne replace the stored variant with a rebuilt one, inserts it if missing
+func (r *MongoRepository) ReplaceOne(variant *entity.Variant) error {
+	coll := r.db.Collection("variants")
+
+	_, err := coll.ReplaceOne(r.ctx, bson.M{"_id": variant.ID}, variant, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+//RemoveOne remove the stored variant whatever its version
+func (r *MongoRepository) RemoveOne(id entity.ID) error {
+	coll := r.db.Collection("variants")
+
+	_, err := coll.DeleteOne(r.ctx, bson.M{"_id": id})
+
+	return err
+}
+
+//RemoveAll remove all the stored variants with their price history and stock ledger
+func (r *MongoRepository) RemoveAll() error {
+	coll := r.db.Collection("variants")
+
+	if _, err := coll.DeleteMany(r.ctx, bson.M{}); err != nil {
+		return err
+	}
+
+	if _, err := r.db.Collection("variant-price-history").DeleteMany(r.ctx, bson.M{}); err != nil {
+		return err
+	}
+
+	_, err := r.db.Collection("stock-movements").DeleteMany(r.ctx, bson.M{})
+
+	return err
+}
@@ -0,0 +1,309 @@
+package variant
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//ErrInsufficientStock the variant available stock is lower than the requested quantity
+var ErrInsufficientStock = errors.New("Insufficient stock")
+
+//errReservationChanged the reservation was committed, released or expired concurrently
+var errReservationChanged = errors.New("Reservation changed")
+
+//DefaultReservationTTL time a reservation holds the stock without ttl
+const DefaultReservationTTL = 15 * time.Minute
+
+//ReserveDTO reservation DTO, ttl in seconds. Without location the stock is reserved at the location
+//with the most available stock
+type ReserveDTO struct {
+	Variant   entity.ID `json:"variant" validate:"required" structs:"variant"`
+	Location  entity.ID `json:"location,omitempty" validate:"omitempty" structs:"location,omitempty"`
+	Quantity  int       `json:"quantity" validate:"required,min=1" structs:"quantity"`
+	TTL       int       `json:"ttl,omitempty" validate:"omitempty,min=1,max=86400" structs:"ttl,omitempty"`
+	Reference string    `json:"reference,omitempty" validate:"omitempty" structs:"reference,omitempty"`
+}
+
+//Reserve hold variant stock until the reservation is committed or released, it's released once its ttl expires
+func (s *Service) Reserve(reserveDTO ReserveDTO) (*entity.Reservation, *entity.Error) {
+	if err := validator.New().Struct(reserveDTO); err != nil {
+		errs := entity.Error{Op: "Reserve", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	Timestamp := time.Now()
+
+	ttl := DefaultReservationTTL
+	if reserveDTO.TTL != 0 {
+		ttl = time.Duration(reserveDTO.TTL) * time.Second
+	}
+
+	v, err := s.storeRepo.FindOneByID(reserveDTO.Variant)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "Reserve", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(reserveDTO.Variant) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Reserve", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	location := reserveDTO.Location
+	if location == "" {
+		location = mostAvailable(v)
+	}
+
+	r := &entity.Reservation{
+		ID:        entity.NewID(),
+		Variant:   reserveDTO.Variant,
+		Location:  location,
+		Quantity:  reserveDTO.Quantity,
+		Reference: reserveDTO.Reference,
+		Status:    entity.ReservationPending,
+		ExpiresAt: Timestamp.Add(ttl),
+		CreatedAt: Timestamp,
+	}
+
+	threshold, err := s.threshold(v.Product)
+	if err != nil {
+		return nil, &entity.Error{Op: "Reserve", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	c := &entity.Command{AggregateID: string(r.Variant), Type: "ReserveStock", Payload: structs.Map(reserveDTO), Timestamp: Timestamp}
+
+	var alerts []*entity.Message
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updated, err := tx.ReserveStock(r.Variant, r.Location, r.Quantity)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.CreateReservation(r); err != nil {
+			return err
+		}
+
+		messages := []*entity.Message{stockMessage("PRODUCT_VARIANT_STOCK_RESERVED", r, updated.Version, "", Timestamp)}
+
+		a, err := alert(tx, updated, -r.Quantity, threshold, Timestamp)
+		if err != nil {
+			return err
+		}
+		if a != nil {
+			alerts = []*entity.Message{a}
+			messages = append(messages, a)
+		}
+
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case ErrInsufficientStock:
+		return nil, &entity.Error{Op: "Reserve", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Quantity", Error: "Insufficient stock"}}}
+	case entity.ErrVersionConflict:
+		return nil, &entity.Error{Op: "Reserve", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Reserve", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	s.notify(alerts)
+
+	if err := s.outbox.Deliver(string(r.Variant)); err != nil {
+		return r, &entity.Error{Op: "Reserve", Kind: entity.DeliveryFailed, ErrorMessage: "Reserved, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return r, nil
+}
+
+//FindReservation find reservation by id
+func (s *Service) FindReservation(ID entity.ID) (*entity.Reservation, *entity.Error) {
+	r, err := s.storeRepo.FindReservation(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindReservation", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Reservation with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindReservation", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return r, nil
+}
+
+//Commit consume the reserved stock of a pending reservation that didn't expire
+func (s *Service) Commit(ID entity.ID) *entity.Error {
+	return s.finish("Commit", ID, entity.ReservationCommitted, "", time.Now())
+}
+
+//Release return the reserved stock of a pending reservation to the available stock
+func (s *Service) Release(ID entity.ID) *entity.Error {
+	return s.finish("Release", ID, entity.ReservationReleased, "released", time.Now())
+}
+
+//ReleaseExpired release the pending reservations expired at now, returns the number of released reservations
+func (s *Service) ReleaseExpired(now time.Time, limit int) (int, *entity.Error) {
+	reservations, err := s.storeRepo.FindExpiredReservations(now, limit)
+	if err != nil {
+		return 0, &entity.Error{Op: "ReleaseExpired", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	n := 0
+	for _, r := range reservations {
+		e := s.finish("ReleaseExpired", r.ID, entity.ReservationReleased, "expired", now)
+		switch {
+		case e == nil || e.Kind == entity.DeliveryFailed:
+			n++
+		case e.Kind == entity.Unexpected:
+			return n, e
+		}
+	}
+
+	return n, nil
+}
+
+//finish move a pending reservation to status and its stock accordingly, reason is set on released stock events
+func (s *Service) finish(op entity.Op, ID entity.ID, status string, reason string, now time.Time) *entity.Error {
+	r, err := s.storeRepo.FindReservation(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return &entity.Error{Op: op, Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Reservation with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return &entity.Error{Op: op, Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	if r.Status != entity.ReservationPending {
+		return &entity.Error{Op: op, Kind: entity.ValidationFailed, ErrorMessage: entity.ErrorMessage("Reservation is " + r.Status), Severity: logrus.InfoLevel}
+	}
+
+	//An expired reservation can only be released, its stock may be reserved by others once swept
+	if status == entity.ReservationCommitted && !now.Before(r.ExpiresAt) {
+		return &entity.Error{Op: op, Kind: entity.ValidationFailed, ErrorMessage: "Reservation expired", Severity: logrus.InfoLevel}
+	}
+
+	eventType := "PRODUCT_VARIANT_STOCK_RELEASED"
+	commandType := "ReleaseStock"
+	if status == entity.ReservationCommitted {
+		eventType = "PRODUCT_VARIANT_STOCK_COMMITTED"
+		commandType = "CommitStock"
+	}
+
+	c := &entity.Command{AggregateID: string(r.Variant), Type: commandType, Payload: map[string]interface{}{"reservation": string(r.ID)}, Timestamp: now}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updatedNum, err := tx.UpdateReservationStatus(r.ID, entity.ReservationPending, status)
+		if err != nil {
+			return err
+		}
+
+		if updatedNum != 1 {
+			return errReservationChanged
+		}
+
+		var updated *entity.Variant
+		if status == entity.ReservationCommitted {
+			updated, err = tx.CommitStock(r.Variant, r.Location, r.Quantity)
+		} else {
+			updated, err = tx.ReleaseStock(r.Variant, r.Location, r.Quantity)
+		}
+		if err != nil {
+			return err
+		}
+
+		//Committed stock leaves the warehouse, it's recorded in the ledger as a sale
+		if status == entity.ReservationCommitted {
+			if err := tx.CreateMovement(saleMovement(r, updated.Version, now)); err != nil {
+				return err
+			}
+		}
+
+		return tx.StoreMessages(*commandID, []*entity.Message{stockMessage(eventType, r, updated.Version, reason, now)})
+	})
+	switch err {
+	case errReservationChanged:
+		return &entity.Error{Op: op, Kind: entity.ConcurrentModification, ErrorMessage: "Reservation changed", Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return &entity.Error{Op: op, Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	if err := s.outbox.Deliver(string(r.Variant)); err != nil {
+		return &entity.Error{Op: op, Kind: entity.DeliveryFailed, ErrorMessage: "Updated, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return nil
+}
+
+//mostAvailable location with the most available stock of the variant, the default location without stock
+func mostAvailable(v *entity.Variant) entity.ID {
+	location, available := entity.DefaultLocation, 0
+	for l, stock := range v.Stock {
+		if stock.Quantity > available || (stock.Quantity == available && entity.ID(l) < location) {
+			location, available = entity.ID(l), stock.Quantity
+		}
+	}
+
+	return location
+}
+
+//stockMessage reservation stock event of the variant
+func stockMessage(eventType string, r *entity.Reservation, version entity.Version, reason string, t time.Time) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["reservation"] = string(r.ID)
+	payload["location"] = string(r.Location)
+	payload["quantity"] = r.Quantity
+	if r.Reference != "" {
+		payload["reference"] = r.Reference
+	}
+	if reason != "" {
+		payload["reason"] = reason
+	}
+
+	return &entity.Message{ID: string(r.Variant), Type: eventType, Version: version, Payload: payload, Timestamp: t}
+}
+
+//RunReservations release the expired reservations every interval until stop is closed
+func (s *Service) RunReservations(interval time.Duration, limit int, stop <-chan struct{}) {
+	for {
+		n, err := s.ReleaseExpired(time.Now(), limit)
+		if err != nil {
+			log.Println("Error on releasing expired reservations", err.Err)
+		}
+
+		//Keep going without waiting while there is a backlog
+		wait := interval
+		if n == limit && err == nil {
+			wait = 0
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
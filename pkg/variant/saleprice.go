@@ -0,0 +1,249 @@
+package variant
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//ScheduleSalePriceDTO sale price DTO, without price list the sale applies to the variant base price
+type ScheduleSalePriceDTO struct {
+	PriceList entity.ID     `json:"priceList,omitempty" validate:"omitempty" structs:"priceList,omitempty"`
+	Price     *entity.Money `json:"price" validate:"required" structs:"price"`
+	ValidFrom time.Time     `json:"validFrom" validate:"required" structs:"validFrom"`
+	ValidTo   time.Time     `json:"validTo" validate:"required,gtfield=ValidFrom" structs:"validTo"`
+}
+
+//ScheduleSalePrice schedule a sale price, the scheduler starts and ends it when its window opens and closes
+func (s *Service) ScheduleSalePrice(ID entity.ID, v int32, scheduleSalePriceDTO ScheduleSalePriceDTO) (*entity.ID, *int32, *entity.Error) {
+	if err := validator.New().Struct(scheduleSalePriceDTO); err != nil {
+		errs := entity.Error{Op: "ScheduleSalePrice", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, nil, &errs
+	}
+
+	Timestamp := time.Now()
+	version := entity.Version(v)
+
+	variant, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, nil, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, nil, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	if variant.Stale(version) {
+		return nil, nil, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	}
+	version = variant.Version
+
+	errs := entity.Error{Op: "ScheduleSalePrice", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+	//The sale is compared to the price it discounts
+	listPrice := variant.Price
+	if scheduleSalePriceDTO.PriceList != "" {
+		listPrice = variant.Prices[string(scheduleSalePriceDTO.PriceList)]
+	}
+
+	price := scheduleSalePriceDTO.Price
+	switch {
+	case listPrice == nil:
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Price", Error: "Variant has no price to discount"})
+	case listPrice.Currency != price.Currency:
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Sale price must be in " + listPrice.Currency})
+	case price.Amount >= listPrice.Amount:
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Price", Error: "Sale price must be lower than the list price"})
+	}
+
+	if !scheduleSalePriceDTO.ValidTo.After(Timestamp) {
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "ValidTo", Error: "Provide a future time"})
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, nil, &errs
+	}
+
+	sale := &entity.SalePrice{
+		ID:        entity.NewID(),
+		PriceList: scheduleSalePriceDTO.PriceList,
+		Price:     price,
+		ValidFrom: scheduleSalePriceDTO.ValidFrom,
+		ValidTo:   scheduleSalePriceDTO.ValidTo,
+		Status:    entity.SalePriceScheduled,
+	}
+
+	version++
+
+	payload := make(map[string]interface{})
+	payload["id"] = string(sale.ID)
+	payload["price"] = sale.Price
+	payload["validFrom"] = sale.ValidFrom
+	payload["validTo"] = sale.ValidTo
+	if sale.PriceList != "" {
+		payload["priceList"] = string(sale.PriceList)
+	}
+
+	m := &entity.Message{ID: string(ID), Type: "PRODUCT_VARIANT_SALE_PRICE_SCHEDULED", Version: version, Payload: payload, Timestamp: Timestamp}
+	c := &entity.Command{AggregateID: string(ID), Type: "ScheduleVariantSalePrice", Payload: structs.Map(scheduleSalePriceDTO), Timestamp: Timestamp}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updatedNum, err := tx.UpdateSalePrices(ID, append(variant.SalePrices, sale), variant.Version, version, true)
+		if err != nil {
+			return err
+		}
+
+		if updatedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		return tx.StoreMessages(*commandID, []*entity.Message{m})
+	})
+	switch err {
+	case entity.ErrVersionConflict:
+		return nil, nil, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, nil, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	Version := int32(version)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &sale.ID, &Version, &entity.Error{Op: "ScheduleSalePrice", Kind: entity.DeliveryFailed, ErrorMessage: "Scheduled, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return &sale.ID, &Version, nil
+}
+
+//ApplySalePrices start the scheduled sale prices whose window opened and end the ones whose window closed at now,
+//returns the number of updated variants. A variant changed concurrently is left for the next run.
+func (s *Service) ApplySalePrices(now time.Time, limit int) (int, *entity.Error) {
+	variants, err := s.storeRepo.FindDueSalePrices(now, limit)
+	if err != nil {
+		return 0, &entity.Error{Op: "ApplySalePrices", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	n := 0
+	for _, v := range variants {
+		salePrices, messages := salePriceTransitions(v, now)
+		if len(messages) == 0 {
+			continue
+		}
+
+		c := &entity.Command{AggregateID: string(v.ID), Type: "ApplyVariantSalePrices", Payload: map[string]interface{}{"at": now}, Timestamp: now}
+		to := messages[len(messages)-1].Version
+
+		err := s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+			commandID, err := tx.StoreCommand(c)
+			if err != nil {
+				return err
+			}
+
+			updatedNum, err := tx.UpdateSalePrices(v.ID, salePrices, v.Version, to, false)
+			if err != nil {
+				return err
+			}
+
+			if updatedNum != 1 {
+				return entity.ErrVersionConflict
+			}
+
+			if err := tx.AppendPriceHistory(priceHistory(v, entity.NewStoredEvents("variant", messages))); err != nil {
+				return err
+			}
+
+			return tx.StoreMessages(*commandID, messages)
+		})
+		switch err {
+		case nil:
+			n++
+		case entity.ErrVersionConflict:
+			continue
+		default:
+			return n, &entity.Error{Op: "ApplySalePrices", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		//Undelivered events stay in the outbox for the relay
+		if err := s.outbox.Deliver(string(v.ID)); err != nil {
+			log.Println("Error on delivering sale prices events", v.ID, err)
+		}
+	}
+
+	return n, nil
+}
+
+//salePriceTransitions the variant sale prices after starting and ending the due ones at now with their events
+func salePriceTransitions(v *entity.Variant, now time.Time) ([]*entity.SalePrice, []*entity.Message) {
+	var salePrices []*entity.SalePrice
+	var messages []*entity.Message
+	version := v.Version
+
+	for _, sale := range v.SalePrices {
+		eventType := ""
+		switch {
+		case !now.Before(sale.ValidTo):
+			eventType = "PRODUCT_VARIANT_SALE_PRICE_ENDED"
+		case sale.Status == entity.SalePriceScheduled && !now.Before(sale.ValidFrom):
+			eventType = "PRODUCT_VARIANT_SALE_PRICE_STARTED"
+			started := *sale
+			started.Status = entity.SalePriceActive
+			salePrices = append(salePrices, &started)
+		default:
+			salePrices = append(salePrices, sale)
+			continue
+		}
+
+		version++
+
+		payload := make(map[string]interface{})
+		payload["id"] = string(sale.ID)
+		payload["price"] = sale.Price
+		if sale.PriceList != "" {
+			payload["priceList"] = string(sale.PriceList)
+		}
+
+		messages = append(messages, &entity.Message{ID: string(v.ID), Type: eventType, Version: version, Payload: payload, Timestamp: now})
+	}
+
+	return salePrices, messages
+}
+
+//RunSalePrices apply the due sale prices every interval until stop is closed
+func (s *Service) RunSalePrices(interval time.Duration, limit int, stop <-chan struct{}) {
+	for {
+		n, err := s.ApplySalePrices(time.Now(), limit)
+		if err != nil {
+			log.Println("Error on applying sale prices", err.Err)
+		}
+
+		//Keep going without waiting while there is a backlog
+		wait := interval
+		if n == limit && err == nil {
+			wait = 0
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
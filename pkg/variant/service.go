@@ -9,33 +9,75 @@ import (
 	"github.com/fatih/structs"
 	"github.com/go-playground/validator"
 	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/location"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/markus-azer/products-service/pkg/pricelist"
 	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/producttype"
+	"github.com/markus-azer/products-service/pkg/webhook"
 	"github.com/sirupsen/logrus"
 )
 
 //Service service interface
 type Service struct {
-	msgRepo     MessagesRepository
-	storeRepo   StoreRepository
-	productRepo product.StoreRepository
+	storeRepo     StoreRepository
+	productRepo   product.StoreRepository
+	priceListRepo pricelist.StoreRepository
+	locationRepo  location.StoreRepository
+	typeRepo      producttype.StoreRepository
+	eventRepo     eventstore.StoreRepository
+	outbox        outbox.UseCase
+	webhooks      webhook.UseCase
+	//lowStockThreshold available quantity at or below which a variant is low on stock, products may override it
+	lowStockThreshold int
+	//skuTemplate template of the SKUs generated for the variants created without one, product types may override it
+	skuTemplate string
 }
 
 //NewService create new service
-func NewService(msgR MessagesRepository, storeR StoreRepository, productR product.StoreRepository) *Service {
+func NewService(storeR StoreRepository, productR product.StoreRepository, priceListR pricelist.StoreRepository, locationR location.StoreRepository, typeR producttype.StoreRepository, eventR eventstore.StoreRepository, outboxU outbox.UseCase, webhookU webhook.UseCase, lowStockThreshold int, skuTemplate string) *Service {
 	return &Service{
-		msgRepo:     msgR,
-		storeRepo:   storeR,
-		productRepo: productR,
+		storeRepo:     storeR,
+		productRepo:   productR,
+		priceListRepo: priceListR,
+		locationRepo:  locationR,
+		typeRepo:      typeR,
+		eventRepo:     eventR,
+		outbox:        outboxU,
+		webhooks:      webhookU,
+
+		lowStockThreshold: lowStockThreshold,
+		skuTemplate:       skuTemplate,
 	}
 }
 
+//FindOneByID find variant by id with its base price sale effective now
+func (s *Service) FindOneByID(ID entity.ID) (*entity.Variant, *entity.Error) {
+	v, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	v.SetSalePrice(time.Now())
+
+	return v, nil
+}
+
 //CreateVariantDTO new variant DTO
 type CreateVariantDTO struct {
 	Product    entity.ID         `json:"product" validate:"required" structs:"product"`
 	SKU        string            `json:"sku,omitempty" validate:"omitempty" structs:"sku,omitempty"`
-	Quantity   int               `json:"quantity,omitempty" validate:"omitempty" structs:"quantity,omitempty"`
-	Price      int               `json:"price,omitempty" validate:"omitempty,min=1" structs:"price,omitempty"`
+	Quantity   int               `json:"quantity,omitempty" validate:"omitempty,min=1" structs:"quantity,omitempty"`
+	Location   entity.ID         `json:"location,omitempty" validate:"omitempty" structs:"location,omitempty"` //location of the initial stock, the default location without it
+	Price      *entity.Money     `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
 	Image      string            `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
+	Barcode    string            `json:"barcode,omitempty" validate:"omitempty" structs:"barcode,omitempty"` //EAN-8, UPC-A, EAN-13 or GTIN-14
 	Attributes map[string]string `json:"attributes" validate:"required" structs:"attributes"`
 }
 
@@ -60,6 +102,9 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 	errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
 	var messages []*entity.Message
 	var version entity.Version = 1
+	var opening *entity.StockMovement
+	var p *entity.Product
+	var generatedSKU bool
 
 	//Lower Case Attributes
 	for k, v := range createVariantDTO.Attributes {
@@ -68,7 +113,7 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 	}
 
 	payload := make(map[string]interface{})
-	payload["product"] = createVariantDTO.Product
+	payload["product"] = string(createVariantDTO.Product)
 	payload["attributes"] = createVariantDTO.Attributes
 
 	messages = append(messages, &entity.Message{
@@ -91,7 +136,8 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 
 		switch field.Name {
 		case "Product":
-			_, err := s.productRepo.FindOneByID(entity.ID(value.String()))
+			var err error
+			p, err = s.productRepo.FindOneByID(entity.ID(value.String()))
 			switch err {
 			case entity.ErrNotFound:
 				errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Product with ID " + value.String() + " doesn't Exist"})
@@ -111,15 +157,41 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 				errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Variant Attributes Duplication with ID " + string(duplicatedVariant.ID)})
 			}
 
-			if len(createVariantDTO.Attributes) > 3 {
-				errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Max Attributes is 3"})
+			//The product is checked first, its type and options constrain the attributes
+			if p != nil {
+				attributesErrs, err := s.checkAttributes(p, createVariantDTO.Attributes)
+				if err != nil {
+					return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				errs.Errors = append(errs.Errors, attributesErrs...)
 			}
 		case "SKU":
-			if value.String() != "" {
+			//Variants created without SKU get one from the template, the product is checked first
+			if value.String() == "" && p != nil {
+				sku, err := s.generateSKU(p, createVariantDTO.Attributes)
+				if err == nil && sku != "" {
+					sku, err = s.uniqueSKU(sku, nil)
+				}
+				if err != nil {
+					return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				createVariantDTO.SKU = sku
+				generatedSKU = sku != ""
+			} else if value.String() != "" {
+				e, err := s.checkSKU(createVariantDTO.SKU, ID)
+				if err != nil {
+					return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				if e != nil {
+					errs.Errors = append(errs.Errors, *e)
+				}
+			}
+
+			if createVariantDTO.SKU != "" {
 				version++
 
 				payload := make(map[string]interface{})
-				payload["sku"] = value.String()
+				payload["sku"] = createVariantDTO.SKU
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
@@ -129,25 +201,37 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 					Timestamp: Timestamp})
 			}
 		case "Quantity":
+			//The initial stock is the first receipt of the variant ledger
 			if value.Int() != 0 {
 				version++
 
-				payload := make(map[string]interface{})
-				payload["quantity"] = value.Int()
-
-				messages = append(messages, &entity.Message{
-					ID:        string(ID),
-					Type:      "PRODUCT_VARIANT_QUANTITY_UPDATED",
-					Version:   version,
-					Payload:   payload,
-					Timestamp: Timestamp})
+				opening = &entity.StockMovement{ID: entity.NewID(), Variant: ID, Location: entity.DefaultLocation, Type: entity.StockReceipt, Quantity: int(value.Int()), Reason: "Initial stock", Version: version, CreatedAt: Timestamp}
+				if createVariantDTO.Location != "" {
+					opening.Location = createVariantDTO.Location
+				}
+				messages = append(messages, movementMessage(opening))
+			}
+		case "Location":
+			if value.String() != "" {
+				_, err := s.locationRepo.FindOneByID(entity.ID(value.String()))
+				switch err {
+				case entity.ErrNotFound:
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Location with ID " + value.String() + " doesn't Exist"})
+				default:
+					if err != nil {
+						return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+					}
+				}
 			}
 		case "Price":
-			if value.Int() != 0 {
+			if createVariantDTO.Price != nil {
+				if !entity.IsCurrency(createVariantDTO.Price.Currency) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Unknown currency " + createVariantDTO.Price.Currency})
+				}
 				version++
 
 				payload := make(map[string]interface{})
-				payload["price"] = value.String()
+				payload["price"] = createVariantDTO.Price
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
@@ -156,6 +240,20 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
+		case "Barcode":
+			if value.String() != "" {
+				barcodeType, barcodeErrs, err := s.checkBarcode(value.String(), ID)
+				if err != nil {
+					return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				if len(barcodeErrs) > 0 {
+					errs.Errors = append(errs.Errors, barcodeErrs...)
+					break
+				}
+				version++
+
+				messages = append(messages, barcodeMessage(ID, version, value.String(), barcodeType, Timestamp))
+			}
 		case "Image":
 			//TODO: check if image exist and add event to delete other image
 			if value.String() != "" {
@@ -184,35 +282,80 @@ func (s *Service) Create(createVariantDTO CreateVariantDTO) (*entity.ID, *int32,
 		Product:    createVariantDTO.Product,
 		SKU:        createVariantDTO.SKU,
 		Quantity:   createVariantDTO.Quantity,
+		Stock:      stock(opening),
 		Price:      createVariantDTO.Price,
 		Image:      createVariantDTO.Image,
 		Attributes: createVariantDTO.Attributes,
+		Signature:  entity.AttributesSignature(createVariantDTO.Attributes),
 		CreatedAt:  Timestamp,
+		Edited:     version,
+	}
+	if createVariantDTO.Barcode != "" {
+		v.Barcode = createVariantDTO.Barcode
+		v.BarcodeType, _ = entity.DetectBarcode(v.Barcode)
+		v.GTIN = entity.GTIN(v.Barcode)
 	}
 
 	c := &entity.Command{AggregateID: string(ID), Type: "CreateVariant", Payload: structs.Map(createVariantDTO), Timestamp: Timestamp}
-	_, err := s.storeRepo.StoreCommand(c)
-	if err != nil {
-		return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
-	}
 
-	_, err = s.storeRepo.Create(v)
-	if err != nil {
-		return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
-	}
+	//Command, variant and messages are stored atomically, the messages are delivered once committed
+	err := s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Create(v); err != nil {
+			return err
+		}
+
+		if opening != nil {
+			if err := tx.CreateMovement(opening); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.AppendPriceHistory(priceHistory(&entity.Variant{}, entity.NewStoredEvents("variant", messages))); err != nil {
+			return err
+		}
 
-	s.msgRepo.SendMessages(messages)
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case ErrDuplicateAttributes:
+		return nil, nil, s.duplicateAttributes("Create", v.Product, v.Attributes)
+	case ErrDuplicateSKU:
+		//The client didn't send the generated SKU, another variant took it since it was checked
+		if generatedSKU {
+			return nil, nil, &entity.Error{Op: "Create", Kind: entity.ConcurrentModification, ErrorMessage: "Variant created concurrently, create again", Severity: logrus.InfoLevel}
+		}
+		return nil, nil, s.duplicateSKU("Create", v.SKU)
+	case ErrDuplicateBarcode:
+		return nil, nil, s.duplicateBarcode("Create", v.Barcode)
+	default:
+		if err != nil {
+			return nil, nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		}
+	}
 
 	Version := int32(v.Version)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &ID, &Version, &entity.Error{Op: "Create", Kind: entity.DeliveryFailed, ErrorMessage: "Created, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
 	return &ID, &Version, nil
 }
 
 //UpdateVariantDTO update variant DTO
 type UpdateVariantDTO struct {
-	SKU      string `json:"sku,omitempty" validate:"omitempty" structs:"sku,omitempty"`
-	Quantity int    `json:"quantity,omitempty" validate:"omitempty" structs:"quantity,omitempty"`
-	Price    int    `json:"price,omitempty" validate:"omitempty,min=1" structs:"price,omitempty"`
-	Image    string `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
+	SKU   string        `json:"sku,omitempty" validate:"omitempty" structs:"sku,omitempty"`
+	Price *entity.Money `json:"price,omitempty" validate:"omitempty" structs:"price,omitempty"`
+	Image string        `json:"image,omitempty" validate:"omitempty,uri" structs:"image,omitempty"`
+	//Attributes attributes to set, an empty value removes the attribute
+	Attributes map[string]string `json:"attributes,omitempty" validate:"omitempty" structs:"attributes,omitempty"`
+	//Barcode EAN-8, UPC-A, EAN-13 or GTIN-14
+	Barcode string `json:"barcode,omitempty" validate:"omitempty" structs:"barcode,omitempty"`
 }
 
 //UpdateOne product
@@ -241,13 +384,16 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateVariantDTO UpdateVarian
 		}
 	}
 
-	if version != variant.Version {
+	if variant.Stale(version) {
 		return nil, &entity.Error{Op: "Update", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
 	}
+	version = variant.Version
 
 	//Loop through the struct to generate events and validate
 	errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
 	var messages []*entity.Message
+	var attributes map[string]string
+	var barcodeType string
 
 	fields := reflect.TypeOf(updateVariantDTO)
 	values := reflect.ValueOf(updateVariantDTO)
@@ -265,6 +411,14 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateVariantDTO UpdateVarian
 				if variant.SKU == updateVariantDTO.SKU {
 					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Sku already updated"})
 				}
+
+				e, err := s.checkSKU(updateVariantDTO.SKU, ID)
+				if err != nil {
+					return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				if e != nil {
+					errs.Errors = append(errs.Errors, *e)
+				}
 				version++
 
 				payload := make(map[string]interface{})
@@ -277,58 +431,104 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateVariantDTO UpdateVarian
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
-		case "Quantity":
+		case "Image":
+			//TODO: check if image exist and add event to delete other image
 			if value.String() != "" {
-				if variant.Quantity == updateVariantDTO.Quantity {
-					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Quantity already updated"})
+				if variant.Image == updateVariantDTO.Image {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Image already updated"})
 				}
 				version++
 
 				payload := make(map[string]interface{})
-				payload["quantity"] = value.String()
+				payload["image"] = value.String()
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
-					Type:      "PRODUCT_VARIANT_QUANTITY_UPDATED",
+					Type:      "PRODUCT_VARIANT_IMAGE_UPDATED",
 					Version:   version,
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
-		case "Image":
-			//TODO: check if image exist and add event to delete other image
-			if value.String() != "" {
-				if variant.Image == updateVariantDTO.Image {
-					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Image already updated"})
+		case "Price":
+			if updateVariantDTO.Price != nil {
+				if variant.Price.Equal(updateVariantDTO.Price) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Price already updated"})
+				}
+				if !entity.IsCurrency(updateVariantDTO.Price.Currency) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Currency", Error: "Unknown currency " + updateVariantDTO.Price.Currency})
 				}
 				version++
 
 				payload := make(map[string]interface{})
-				payload["image"] = value.String()
+				payload["price"] = updateVariantDTO.Price
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
-					Type:      "PRODUCT_VARIANT_IMAGE_UPDATED",
+					Type:      "PRODUCT_VARIANT_PRICE_UPDATED",
 					Version:   version,
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
-		case "Price":
-			if value.Int() != 0 {
-				if variant.Price == updateVariantDTO.Price {
-					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Price already updated"})
+		case "Attributes":
+			if len(updateVariantDTO.Attributes) > 0 {
+				attributes = mergeAttributes(variant.Attributes, updateVariantDTO.Attributes)
+				if reflect.DeepEqual(variant.Attributes, attributes) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Attributes already updated"})
+				}
+				if len(attributes) == 0 {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Variant must keep at least one attribute"})
+				}
+
+				duplicatedVariant, err := s.storeRepo.FindOneByAttribute(variant.Product, attributes)
+				if err != entity.ErrNotFound && err != nil {
+					return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+
+				if duplicatedVariant != nil && duplicatedVariant.ID != ID {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Variant Attributes Duplication with ID " + string(duplicatedVariant.ID)})
+				}
+
+				p, err := s.productRepo.FindOneByID(variant.Product)
+				if err != nil {
+					return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+
+				attributesErrs, err := s.checkAttributes(p, attributes)
+				if err != nil {
+					return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
 				}
+				errs.Errors = append(errs.Errors, attributesErrs...)
+
 				version++
 
 				payload := make(map[string]interface{})
-				payload["price"] = value.Int()
+				payload["attributes"] = attributes
 
 				messages = append(messages, &entity.Message{
 					ID:        string(ID),
-					Type:      "PRODUCT_VARIANT_PRICE_UPDATED",
+					Type:      "PRODUCT_VARIANT_ATTRIBUTES_UPDATED",
 					Version:   version,
 					Payload:   payload,
 					Timestamp: Timestamp})
 			}
+		case "Barcode":
+			if value.String() != "" {
+				t, barcodeErrs, err := s.checkBarcode(updateVariantDTO.Barcode, ID)
+				if err != nil {
+					return nil, &entity.Error{Op: "UpdateOne", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+				}
+				if len(barcodeErrs) > 0 {
+					errs.Errors = append(errs.Errors, barcodeErrs...)
+					break
+				}
+				if variant.GTIN == entity.GTIN(updateVariantDTO.Barcode) {
+					errs.Errors = append(errs.Errors, entity.ErrorField{Field: fieldName, Error: "Barcode already updated"})
+				}
+				barcodeType = t
+				version++
+
+				messages = append(messages, barcodeMessage(ID, version, updateVariantDTO.Barcode, barcodeType, Timestamp))
+			}
 		}
 	}
 
@@ -341,32 +541,66 @@ func (s *Service) UpdateOne(ID entity.ID, v int32, updateVariantDTO UpdateVarian
 	}
 
 	c := &entity.Command{AggregateID: string(ID), Type: "UpdateProduct", Payload: structs.Map(updateVariantDTO), Timestamp: Timestamp}
-	_, err = s.storeRepo.StoreCommand(c)
-	if err != nil {
-		return nil, &entity.Error{Op: "Update", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
-	}
 
 	up := &entity.UpdateVariant{
-		Version:  version,
-		SKU:      updateVariantDTO.SKU,
-		Quantity: updateVariantDTO.Quantity,
-		Price:    updateVariantDTO.Price,
-		Image:    updateVariantDTO.Image,
+		Version:    version,
+		Edited:     version,
+		SKU:        updateVariantDTO.SKU,
+		Price:      updateVariantDTO.Price,
+		Image:      updateVariantDTO.Image,
+		Attributes: attributes,
 	}
-
-	updatedNum, err := s.storeRepo.UpdateOne(ID, up, entity.Version(v))
-	if err != nil {
-		return nil, &entity.Error{Op: "Update", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+	if attributes != nil {
+		up.Signature = entity.AttributesSignature(attributes)
 	}
+	if updateVariantDTO.Barcode != "" {
+		up.Barcode = updateVariantDTO.Barcode
+		up.BarcodeType = barcodeType
+		up.GTIN = entity.GTIN(updateVariantDTO.Barcode)
+	}
+
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updatedNum, err := tx.UpdateOne(ID, up, variant.Version)
+		if err != nil {
+			return err
+		}
+
+		if updatedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		if err := tx.AppendPriceHistory(priceHistory(variant, entity.NewStoredEvents("variant", messages))); err != nil {
+			return err
+		}
 
-	if updatedNum != 1 {
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case entity.ErrVersionConflict:
 		return nil, &entity.Error{Op: "Update", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	case ErrDuplicateAttributes:
+		return nil, s.duplicateAttributes("Update", variant.Product, attributes)
+	case ErrDuplicateSKU:
+		return nil, s.duplicateSKU("Update", updateVariantDTO.SKU)
+	case ErrDuplicateBarcode:
+		return nil, s.duplicateBarcode("Update", updateVariantDTO.Barcode)
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "Update", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		}
 	}
 
-	//TODO:handle failure cases
-	s.msgRepo.SendMessages(messages)
-
 	Version := int32(version)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return &Version, &entity.Error{Op: "Update", Kind: entity.DeliveryFailed, ErrorMessage: "Updated, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
 	return &Version, nil
 }
 
@@ -375,7 +609,6 @@ func (s *Service) Delete(id entity.ID, v int32) *entity.Error {
 	Timestamp := time.Now()
 	version := entity.Version(v)
 
-	//TODO check Transactions
 	p, err := s.storeRepo.FindOneByID(id)
 	switch err {
 	case entity.ErrNotFound:
@@ -386,29 +619,104 @@ func (s *Service) Delete(id entity.ID, v int32) *entity.Error {
 		}
 	}
 
-	if version != p.Version {
+	if p.Stale(version) {
 		return &entity.Error{Op: "Update", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
 
 	}
+	version = p.Version
 
 	c := &entity.Command{AggregateID: string(id), Type: "DeleteVariant", Timestamp: Timestamp}
-	s.storeRepo.StoreCommand(c)
+	m := &entity.Message{ID: string(id), Type: "PRODUCT_VARIANT_DELETED", Version: version + 1, Timestamp: Timestamp}
 
-	updatedNum, err := s.storeRepo.DeleteOne(id, version)
-	if err != nil {
-		return &entity.Error{Op: "Delete", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		deletedNum, err := tx.DeleteOne(id, version)
+		if err != nil {
+			return err
+		}
+
+		if deletedNum != 1 {
+			return entity.ErrVersionConflict
+		}
+
+		return tx.StoreMessages(*commandID, []*entity.Message{m})
+	})
+	switch err {
+	case entity.ErrVersionConflict:
+		return &entity.Error{Op: "Delete", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return &entity.Error{Op: "Delete", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel}
+		}
 	}
 
-	if updatedNum != 1 {
-		return &entity.Error{Op: "Update", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	if err := s.outbox.Deliver(string(id)); err != nil {
+		return &entity.Error{Op: "Delete", Kind: entity.DeliveryFailed, ErrorMessage: "Deleted, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return nil
+}
 
+//Rebuild recompute the stored variant by folding its events from the event store
+//returns nil without error for a deleted variant
+func (s *Service) Rebuild(ID entity.ID) (*entity.Variant, *entity.Error) {
+	events, err := s.eventRepo.FindByAggregate(string(ID))
+	if err != nil {
+		return nil, &entity.Error{Op: "Rebuild", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
 	}
 
-	version++
+	return s.Project(ID, events)
+}
+
+//Project replace the stored variant by the fold of its events, the stored variant is removed if it is deleted
+//returns nil without error for a deleted variant
+func (s *Service) Project(ID entity.ID, events []*entity.StoredEvent) (*entity.Variant, *entity.Error) {
+	v, err := Rehydrate(events)
+	switch err {
+	case nil:
+		if err := s.storeRepo.ReplaceOne(v); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if err := s.storeRepo.ReplacePriceHistory(ID, priceHistory(&entity.Variant{}, events)); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if err := s.storeRepo.ReplaceMovements(ID, stockMovements(events)); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		return v, nil
+	case entity.ErrAggregateDeleted:
+		if err := s.storeRepo.ReplacePriceHistory(ID, nil); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		if err := s.storeRepo.ReplaceMovements(ID, nil); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
 
-	//TODO:handle failure cases
-	m := &entity.Message{ID: string(id), Type: "PRODUCT_VARIANT_DELETED", Version: version, Timestamp: Timestamp}
-	s.msgRepo.SendMessage(m)
+		if err := s.storeRepo.RemoveOne(ID); err != nil {
+			return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+
+		return nil, nil
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "Project", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		return nil, &entity.Error{Op: "Project", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+}
+
+//RemoveAll remove all the stored variants, used before rebuilding them
+func (s *Service) RemoveAll() *entity.Error {
+	if err := s.storeRepo.RemoveAll(); err != nil {
+		return &entity.Error{Op: "RemoveAll", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
 
 	return nil
 }
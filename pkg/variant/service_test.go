@@ -0,0 +1,867 @@
+package variant_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/eventstore"
+	"github.com/markus-azer/products-service/pkg/location"
+	"github.com/markus-azer/products-service/pkg/outbox"
+	"github.com/markus-azer/products-service/pkg/pricelist"
+	"github.com/markus-azer/products-service/pkg/product"
+	"github.com/markus-azer/products-service/pkg/producttype"
+	"github.com/markus-azer/products-service/pkg/variant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPrice(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+	germany := &entity.PriceList{ID: entity.NewID(), Name: "Germany", Currency: "EUR", Regions: []string{"DE"}}
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Version: 2}, nil).Times(2)
+	priceListRepo.EXPECT().FindOneByID(germany.ID).Return(germany, nil).Times(2)
+
+	// The price must be in the currency of the list
+	_, err := service.SetPrice(ID, 2, variant.SetPriceDTO{PriceList: germany.ID, Price: &entity.Money{Amount: 1999, Currency: "USD"}})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, "Currency", err.Errors[0].Field)
+
+	price := &entity.Money{Amount: 1999, Currency: "EUR"}
+
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdatePrice(ID, germany.ID, price, entity.Version(2)).Return(1, nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Do(func(changes []*entity.PriceChange) {
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, germany.ID, changes[0].PriceList)
+		assert.Equal(t, price, changes[0].Price)
+		assert.Equal(t, entity.Version(3), changes[0].Version)
+	}).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_PRICE_UPDATED", messages[0].Type)
+		assert.Equal(t, string(germany.ID), messages[0].Payload["priceList"])
+		assert.Equal(t, entity.Version(3), messages[0].Version)
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	v, err := service.SetPrice(ID, 2, variant.SetPriceDTO{PriceList: germany.ID, Price: price})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), *v)
+}
+
+func TestEffectivePrice(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	eurozone := &entity.PriceList{ID: entity.NewID(), Name: "Eurozone", Currency: "EUR", Priority: 10}
+	germany := &entity.PriceList{ID: entity.NewID(), Name: "Germany", Currency: "EUR", Regions: []string{"DE"}}
+
+	v := &entity.Variant{
+		ID:    ID,
+		Price: &entity.Money{Amount: 2500, Currency: "USD"},
+		Prices: map[string]*entity.Money{
+			string(eurozone.ID): {Amount: 2200, Currency: "EUR"},
+			string(germany.ID):  {Amount: 1999, Currency: "EUR"},
+		},
+	}
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(v, nil).AnyTimes()
+	priceListRepo.EXPECT().FindByCurrency("EUR").Return([]*entity.PriceList{eurozone, germany}, nil).AnyTimes()
+	priceListRepo.EXPECT().FindByCurrency("USD").Return([]*entity.PriceList{}, nil).AnyTimes()
+	priceListRepo.EXPECT().FindByCurrency("GBP").Return([]*entity.PriceList{}, nil).AnyTimes()
+
+	// The list of the region wins over the lists applying everywhere
+	p, err := service.EffectivePrice(ID, variant.EffectivePriceDTO{Region: "de", Currency: "EUR"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, germany, p.PriceList)
+	assert.Equal(t, int64(1999), p.Price.Amount)
+
+	p, err = service.EffectivePrice(ID, variant.EffectivePriceDTO{Region: "FR", Currency: "EUR"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, eurozone, p.PriceList)
+
+	// Without a list the base price is used if it's in the currency
+	p, err = service.EffectivePrice(ID, variant.EffectivePriceDTO{Region: "US", Currency: "USD"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, p.PriceList)
+	assert.Equal(t, int64(2500), p.Price.Amount)
+
+	_, err = service.EffectivePrice(ID, variant.EffectivePriceDTO{Currency: "GBP"})
+
+	assert.Equal(t, entity.NotFound, err.Kind)
+}
+
+func TestApplySalePrices(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+	now := time.Now()
+
+	starting := &entity.SalePrice{ID: entity.NewID(), Price: &entity.Money{Amount: 1500, Currency: "USD"}, ValidFrom: now.Add(-time.Minute), ValidTo: now.Add(time.Hour), Status: entity.SalePriceScheduled}
+	ending := &entity.SalePrice{ID: entity.NewID(), Price: &entity.Money{Amount: 1800, Currency: "USD"}, ValidFrom: now.Add(-time.Hour), ValidTo: now, Status: entity.SalePriceActive}
+	later := &entity.SalePrice{ID: entity.NewID(), Price: &entity.Money{Amount: 1200, Currency: "USD"}, ValidFrom: now.Add(time.Hour), ValidTo: now.Add(2 * time.Hour), Status: entity.SalePriceScheduled}
+
+	v := &entity.Variant{ID: ID, Version: 4, Price: &entity.Money{Amount: 2000, Currency: "USD"}, SalePrices: []*entity.SalePrice{starting, ending, later}}
+
+	variantRepo.EXPECT().FindDueSalePrices(now, 100).Return([]*entity.Variant{v}, nil)
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdateSalePrices(ID, gomock.Any(), entity.Version(4), entity.Version(6), false).Do(func(id entity.ID, salePrices []*entity.SalePrice, version entity.Version, to entity.Version, edited bool) {
+		assert.Equal(t, 2, len(salePrices))
+		assert.Equal(t, entity.SalePriceActive, salePrices[0].Status)
+		assert.Equal(t, later, salePrices[1])
+	}).Return(1, nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Do(func(changes []*entity.PriceChange) {
+		// The started sale is the effective price once the other one ended
+		assert.Equal(t, 1, len(changes))
+		assert.Equal(t, int64(1500), changes[0].Price.Amount)
+		assert.True(t, changes[0].Sale)
+		assert.Equal(t, entity.Version(5), changes[0].Version)
+	}).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 2, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_SALE_PRICE_STARTED", messages[0].Type)
+		assert.Equal(t, string(starting.ID), messages[0].Payload["id"])
+		assert.Equal(t, "PRODUCT_VARIANT_SALE_PRICE_ENDED", messages[1].Type)
+		assert.Equal(t, entity.Version(6), messages[1].Version)
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	n, err := service.ApplySalePrices(now, 100)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	// The scheduled sale isn't modified, the effective sale is read from the validity window
+	assert.Equal(t, entity.SalePriceScheduled, starting.Status)
+	assert.Equal(t, starting, v.ActiveSalePrice("", now))
+}
+
+func TestLowestPrice(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	saleStart := time.Now().Add(-time.Hour)
+
+	sale := &entity.SalePrice{ID: entity.NewID(), Price: &entity.Money{Amount: 1500, Currency: "USD"}, ValidFrom: saleStart, ValidTo: time.Now().Add(time.Hour), Status: entity.SalePriceActive}
+	v := &entity.Variant{ID: ID, Price: &entity.Money{Amount: 2000, Currency: "USD"}, SalePrices: []*entity.SalePrice{sale}}
+
+	history := []*entity.PriceChange{
+		{Variant: ID, Price: &entity.Money{Amount: 2200, Currency: "USD"}},
+		{Variant: ID, Price: &entity.Money{Amount: 1800, Currency: "USD"}},
+		{Variant: ID, Price: &entity.Money{Amount: 2000, Currency: "USD"}},
+	}
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(v, nil)
+	// The window ends when the current sale started
+	variantRepo.EXPECT().FindPriceHistory(ID, entity.ID(""), saleStart.AddDate(0, 0, -30), saleStart).Return(history, nil)
+
+	l, err := service.LowestPrice(ID, variant.LowestPriceDTO{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1500), l.Price.Amount)
+	assert.Equal(t, int64(2000), l.ListPrice.Amount)
+	assert.Equal(t, int64(1800), l.Lowest.Amount)
+}
+
+func TestReserve(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+
+	warehouse := entity.NewID()
+	stock := map[string]*entity.Stock{string(entity.DefaultLocation): {Quantity: 1}, string(warehouse): {Quantity: 2}}
+
+	productID := entity.NewID()
+	threshold := 1
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Product: productID, Version: 3, Quantity: 3, Stock: stock}, nil).Times(2)
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, LowStockThreshold: &threshold}, nil).Times(2)
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	}).Times(2)
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil).Times(2)
+
+	// Without location the stock is reserved where most is available, the decrement is conditioned on it
+	variantRepo.EXPECT().ReserveStock(ID, warehouse, 3).Return(nil, variant.ErrInsufficientStock)
+
+	_, err := service.Reserve(variant.ReserveDTO{Variant: ID, Quantity: 3})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, "Quantity", err.Errors[0].Field)
+
+	variantRepo.EXPECT().ReserveStock(ID, warehouse, 2).Return(&entity.Variant{ID: ID, Product: productID, Version: 4, Quantity: 1}, nil)
+	variantRepo.EXPECT().CreateReservation(gomock.Any()).Return(nil)
+
+	// The available quantity crosses the product threshold
+	variantRepo.EXPECT().BumpVersion(ID, entity.Version(4)).Return(1, nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 2, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_STOCK_RESERVED", messages[0].Type)
+		assert.Equal(t, entity.Version(4), messages[0].Version)
+		assert.Equal(t, 2, messages[0].Payload["quantity"])
+		assert.Equal(t, "PRODUCT_VARIANT_LOW_STOCK", messages[1].Type)
+		assert.Equal(t, entity.Version(5), messages[1].Version)
+		assert.Equal(t, 1, messages[1].Payload["threshold"])
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	r, err := service.Reserve(variant.ReserveDTO{Variant: ID, Quantity: 2, Reference: "cart-1"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, entity.ReservationPending, r.Status)
+	assert.Equal(t, warehouse, r.Location)
+	assert.WithinDuration(t, time.Now().Add(variant.DefaultReservationTTL), r.ExpiresAt, time.Second)
+}
+
+func TestReleaseExpired(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	storeID := entity.NewID()
+	now := time.Now()
+	r := &entity.Reservation{ID: entity.NewID(), Variant: entity.NewID(), Location: entity.DefaultLocation, Quantity: 2, Status: entity.ReservationPending, ExpiresAt: now.Add(-time.Second)}
+
+	variantRepo.EXPECT().FindReservation(r.ID).Return(r, nil).Times(2)
+
+	// An expired reservation can't be committed
+	err := service.Commit(r.ID)
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+
+	variantRepo.EXPECT().FindExpiredReservations(now, 100).Return([]*entity.Reservation{r}, nil)
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdateReservationStatus(r.ID, entity.ReservationPending, entity.ReservationReleased).Return(1, nil)
+	variantRepo.EXPECT().ReleaseStock(r.Variant, r.Location, 2).Return(&entity.Variant{ID: r.Variant, Version: 6, Quantity: 2}, nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, "PRODUCT_VARIANT_STOCK_RELEASED", messages[0].Type)
+		assert.Equal(t, "expired", messages[0].Payload["reason"])
+		assert.Equal(t, entity.Version(6), messages[0].Version)
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(r.Variant)).Return(nil)
+
+	n, err := service.ReleaseExpired(now, 100)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestPostMovement(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+	warehouse := entity.NewID()
+
+	locationRepo.EXPECT().FindOneByID(warehouse).Return(&entity.Location{ID: warehouse, Name: "Warehouse"}, nil).Times(2)
+
+	// Sales remove stock
+	_, err := service.PostMovement(ID, variant.PostMovementDTO{Location: warehouse, Type: entity.StockSale, Quantity: 2})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, "Quantity", err.Errors[0].Field)
+
+	productID := entity.NewID()
+
+	// Without product threshold the global one applies, 3 available are above it
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Product: productID, Version: 3, Quantity: 5}, nil)
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID}, nil)
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().MoveStock(ID, warehouse, -2).Return(&entity.Variant{ID: ID, Product: productID, Version: 4, Quantity: 3}, nil)
+	variantRepo.EXPECT().CreateMovement(gomock.Any()).Do(func(m *entity.StockMovement) {
+		assert.Equal(t, entity.Version(4), m.Version)
+		assert.Equal(t, warehouse, m.Location)
+	}).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_STOCK_MOVED", messages[0].Type)
+		assert.Equal(t, entity.Version(4), messages[0].Version)
+		assert.Equal(t, -2, messages[0].Payload["quantity"])
+		assert.Equal(t, "damaged", messages[0].Payload["reason"])
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	m, err := service.PostMovement(ID, variant.PostMovementDTO{Location: warehouse, Type: entity.StockAdjustment, Quantity: -2, Reason: "damaged"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, -2, m.Quantity)
+}
+
+func TestGenerateVariants(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+	storeID := entity.NewID()
+	price := &entity.Money{Amount: 1999, Currency: "EUR"}
+	options := []*entity.Option{{Name: "size", Values: []string{"s", "m"}}, {Name: "color", Values: []string{"red", "blue"}}}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Name: "Tee", Options: options}, nil)
+	productRepo.EXPECT().FindVariantsByProduct(productID).Return([]*entity.Variant{{ID: entity.NewID(), Product: productID, Attributes: map[string]string{"color": "red", "size": "s"}}}, nil)
+	variantRepo.EXPECT().FindOneBySKU(gomock.Any()).Return(nil, entity.ErrNotFound).Times(3)
+
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().CreateMany(gomock.Any()).Do(func(variants []*entity.Variant) {
+		// The existing combination is skipped
+		assert.Equal(t, 3, len(variants))
+		assert.Equal(t, map[string]string{"size": "s", "color": "blue"}, variants[0].Attributes)
+		assert.Equal(t, "tee-blue-s", variants[0].SKU)
+		assert.Equal(t, entity.Version(3), variants[0].Version)
+	}).Return(nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Do(func(changes []*entity.PriceChange) {
+		assert.Equal(t, 3, len(changes))
+	}).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 9, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_DRAFT_CREATED", messages[0].Type)
+		assert.Equal(t, "PRODUCT_VARIANT_SKU_UPDATED", messages[1].Type)
+		assert.Equal(t, "PRODUCT_VARIANT_PRICE_UPDATED", messages[2].Type)
+	}).Return(nil)
+	outboxService.EXPECT().DeliverMany(gomock.Any()).Do(func(aggregateIDs []string) {
+		assert.Equal(t, 3, len(aggregateIDs))
+	}).Return(nil)
+
+	IDs, err := service.GenerateVariants(variant.GenerateVariantsDTO{Product: productID, Price: price})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(IDs))
+}
+
+func TestGenerateVariantsSuffixesSKUs(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	// Without attributes in the template all the variants get the same SKU
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, "{brand}-{product-slug}")
+
+	productID := entity.NewID()
+	storeID := entity.NewID()
+	options := []*entity.Option{{Name: "size", Values: []string{"s", "m"}}}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Name: "Tee", Brand: "Acme", Options: options}, nil)
+	productRepo.EXPECT().FindVariantsByProduct(productID).Return([]*entity.Variant{}, nil)
+
+	// Another product of the brand has the same name
+	variantRepo.EXPECT().FindOneBySKU("acme-tee").Return(&entity.Variant{ID: entity.NewID(), SKU: "acme-tee"}, nil).Times(2)
+	variantRepo.EXPECT().FindOneBySKU("acme-tee-2").Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().FindOneBySKU("acme-tee-3").Return(nil, entity.ErrNotFound)
+
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().CreateMany(gomock.Any()).Do(func(variants []*entity.Variant) {
+		assert.Equal(t, "acme-tee-2", variants[0].SKU)
+		assert.Equal(t, "acme-tee-3", variants[1].SKU)
+	}).Return(nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Return(nil)
+	outboxService.EXPECT().DeliverMany(gomock.Any()).Return(nil)
+
+	IDs, err := service.GenerateVariants(variant.GenerateVariantsDTO{Product: productID})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(IDs))
+}
+
+func TestGenerateVariantsBoundsCombinations(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+	values := make([]string, 11)
+	for i := range values {
+		values[i] = fmt.Sprint(i)
+	}
+	options := []*entity.Option{{Name: "size", Values: values}, {Name: "length", Values: values}}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Name: "Jeans", Options: options}, nil)
+
+	_, err := service.GenerateVariants(variant.GenerateVariantsDTO{Product: productID})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, []entity.ErrorField{{Field: "Product", Error: "Product options make more than 100 combinations"}}, err.Errors)
+}
+
+func TestCreateChecksAttributesAgainstProductType(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+	shoes := &entity.ProductType{ID: entity.NewID(), Name: "Shoes", Attributes: []*entity.AttributeSchema{
+		{Key: "size", Type: entity.AttributeNumber, Unit: "eu", Required: true},
+		{Key: "color", Type: entity.AttributeEnum, Values: []string{"red", "blue"}},
+		{Key: "waterproof", Type: entity.AttributeBoolean},
+		{Key: "material", Type: entity.AttributeText, Required: true},
+	}}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Type: shoes.ID}, nil)
+	variantRepo.EXPECT().FindOneByAttribute(productID, gomock.Any()).Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().FindOneBySKU(gomock.Any()).Return(nil, entity.ErrNotFound)
+	typeRepo.EXPECT().FindOneByID(shoes.ID).Return(shoes, nil).Times(2)
+
+	_, _, err := service.Create(variant.CreateVariantDTO{Product: productID, Attributes: map[string]string{"Size": "44 EU", "Color": "Green", "waterproof": "maybe"}})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, []entity.ErrorField{
+		{Field: "Attributes", Error: "Attribute color must be one of red, blue"},
+		{Field: "Attributes", Error: "Attribute waterproof must be true or false"},
+		{Field: "Attributes", Error: "Attribute material is required"},
+	}, err.Errors)
+}
+
+func TestUpdateAttributes(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	productID := entity.NewID()
+	storeID := entity.NewID()
+	attributes := map[string]string{"size": "m", "color": "blue"}
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Product: productID, Version: 2, Attributes: map[string]string{"size": "m", "color": "red", "material": "cotton"}}, nil)
+	variantRepo.EXPECT().FindOneByAttribute(productID, attributes).Return(nil, entity.ErrNotFound)
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID}, nil)
+
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdateOne(ID, &entity.UpdateVariant{Version: 3, Attributes: attributes, Signature: "color=blue&size=m", Edited: 3}, entity.Version(2)).Return(1, nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_ATTRIBUTES_UPDATED", messages[0].Type)
+		assert.Equal(t, attributes, messages[0].Payload["attributes"])
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	// Values are lower cased and an empty value removes the attribute
+	v, err := service.UpdateOne(ID, 2, variant.UpdateVariantDTO{Attributes: map[string]string{"Color": "Blue", "material": ""}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), *v)
+}
+
+func TestCreateRejectsDuplicateAttributes(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+	duplicateID := entity.NewID()
+	attributes := map[string]string{"size": "m", "color": "red"}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID}, nil)
+	variantRepo.EXPECT().FindOneBySKU("red-m").Return(nil, entity.ErrNotFound)
+
+	// The variant is created concurrently between the check and the insert, the unique signature rejects it
+	gomock.InOrder(
+		variantRepo.EXPECT().FindOneByAttribute(productID, attributes).Return(nil, entity.ErrNotFound),
+		variantRepo.EXPECT().WithTransaction(gomock.Any()).Return(variant.ErrDuplicateAttributes),
+		variantRepo.EXPECT().FindOneByAttribute(productID, attributes).Return(&entity.Variant{ID: duplicateID}, nil),
+	)
+
+	_, _, err := service.Create(variant.CreateVariantDTO{Product: productID, Attributes: map[string]string{"color": "red", "size": "m"}})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, "Variant Attributes Duplication with ID "+string(duplicateID), err.Errors[0].Error)
+}
+
+func TestCreateDuplicateSKU(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+	existingID := entity.NewID()
+	shoes := &entity.ProductType{ID: entity.NewID(), Name: "Shoes", SKUTemplate: "{brand}-{product-slug}-{attr.size}", Attributes: []*entity.AttributeSchema{
+		{Key: "size", Type: entity.AttributeNumber},
+		{Key: "color", Type: entity.AttributeText},
+	}}
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Slug: "air-max", Brand: "Nike", Type: shoes.ID}, nil)
+	typeRepo.EXPECT().FindOneByID(shoes.ID).Return(shoes, nil)
+	variantRepo.EXPECT().FindOneByAttribute(productID, gomock.Any()).Return(nil, entity.ErrNotFound)
+
+	// A SKU sent by the client is rejected if another variant uses it
+	variantRepo.EXPECT().FindOneBySKU("nike-air-max-44").Return(&entity.Variant{ID: existingID, SKU: "nike-air-max-44"}, nil)
+
+	_, _, err := service.Create(variant.CreateVariantDTO{Product: productID, SKU: "nike-air-max-44", Attributes: map[string]string{"size": "44", "color": "black"}})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, []entity.ErrorField{{Field: "SKU", Error: "SKU nike-air-max-44 already used by variant " + string(existingID)}}, err.Errors)
+
+	// The SKU generated from the product type template when missing is suffixed until unused
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID, Slug: "air-max", Brand: "Nike", Type: shoes.ID}, nil)
+	typeRepo.EXPECT().FindOneByID(shoes.ID).Return(shoes, nil).Times(2)
+	variantRepo.EXPECT().FindOneByAttribute(productID, gomock.Any()).Return(nil, entity.ErrNotFound)
+	gomock.InOrder(
+		variantRepo.EXPECT().FindOneBySKU("nike-air-max-44").Return(&entity.Variant{ID: existingID, SKU: "nike-air-max-44"}, nil),
+		variantRepo.EXPECT().FindOneBySKU("nike-air-max-44-2").Return(nil, entity.ErrNotFound),
+	)
+
+	storeID := entity.NewID()
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().Create(gomock.Any()).DoAndReturn(func(v *entity.Variant) (*entity.ID, error) {
+		assert.Equal(t, "nike-air-max-44-2", v.SKU)
+		return &v.ID, nil
+	})
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Return(nil)
+	outboxService.EXPECT().Deliver(gomock.Any()).Return(nil)
+
+	_, _, err = service.Create(variant.CreateVariantDTO{Product: productID, Attributes: map[string]string{"size": "44", "color": "black"}})
+
+	assert.Nil(t, err)
+}
+
+func TestUpdateBarcode(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Version: 2}, nil).Times(3)
+
+	// A wrong check digit is rejected before looking for other variants
+	_, err := service.UpdateOne(ID, 2, variant.UpdateVariantDTO{Barcode: "036000291453"})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, entity.ErrBarcodeCheckDigit.Error(), err.Errors[0].Error)
+
+	_, err = service.UpdateOne(ID, 2, variant.UpdateVariantDTO{Barcode: "123456789012345"})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, []entity.ErrorField{{Field: "Barcode", Error: entity.ErrBarcodeLength.Error()}}, err.Errors)
+
+	variantRepo.EXPECT().FindOneByGTIN("00036000291452").Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdateOne(ID, &entity.UpdateVariant{Version: 3, Barcode: "036000291452", BarcodeType: entity.BarcodeUPCA, GTIN: "00036000291452", Edited: 3}, entity.Version(2)).Return(1, nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, 1, len(messages))
+		assert.Equal(t, "PRODUCT_VARIANT_BARCODE_UPDATED", messages[0].Type)
+		assert.Equal(t, "00036000291452", messages[0].Payload["gtin"])
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	v, err := service.UpdateOne(ID, 2, variant.UpdateVariantDTO{Barcode: "036000291452"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), *v)
+}
+
+func TestCreateRejectsOverlongBarcode(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	productID := entity.NewID()
+
+	productRepo.EXPECT().FindOneByID(productID).Return(&entity.Product{ID: productID}, nil)
+	variantRepo.EXPECT().FindOneByAttribute(productID, gomock.Any()).Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().FindOneBySKU("tee-s").Return(nil, entity.ErrNotFound)
+
+	_, _, err := service.Create(variant.CreateVariantDTO{Product: productID, SKU: "tee-s", Barcode: "123456789012345", Attributes: map[string]string{"size": "s"}})
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+	assert.Equal(t, []entity.ErrorField{{Field: "Barcode", Error: entity.ErrBarcodeLength.Error()}}, err.Errors)
+}
+
+func TestFindOneByBarcode(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	service := variant.NewService(variantRepo, nil, nil, nil, nil, nil, nil, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+
+	// The EAN-13 form of a UPC-A barcode finds the same variant
+	variantRepo.EXPECT().FindOneByGTIN("00036000291452").Return(&entity.Variant{ID: ID, Barcode: "036000291452"}, nil)
+
+	v, err := service.FindOneByBarcode("0036000291452")
+
+	assert.Nil(t, err)
+	assert.Equal(t, ID, v.ID)
+
+	_, err = service.FindOneByBarcode("12345")
+
+	assert.Equal(t, entity.ValidationFailed, err.Kind)
+}
+
+func TestUpdateAfterStockChanges(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	ID := entity.NewID()
+	storeID := entity.NewID()
+
+	// The variant was edited at version 2 then sold three times
+	variantRepo.EXPECT().FindOneByID(ID).Return(&entity.Variant{ID: ID, Version: 5, Edited: 2}, nil).Times(2)
+
+	// A version older than the last edit misses it
+	_, err := service.UpdateOne(ID, 1, variant.UpdateVariantDTO{Image: "https://img.example.com/a.png"})
+
+	assert.Equal(t, entity.ConcurrentModification, err.Kind)
+
+	variantRepo.EXPECT().WithTransaction(gomock.Any()).DoAndReturn(func(fn func(variant.StoreRepository) error) error {
+		return fn(variantRepo)
+	})
+	variantRepo.EXPECT().StoreCommand(gomock.Any()).Return(&storeID, nil)
+	variantRepo.EXPECT().UpdateOne(ID, &entity.UpdateVariant{Version: 6, Image: "https://img.example.com/a.png", Edited: 6}, entity.Version(5)).Return(1, nil)
+	variantRepo.EXPECT().AppendPriceHistory(gomock.Any()).Return(nil)
+	variantRepo.EXPECT().StoreMessages(storeID, gomock.Any()).Do(func(commandID entity.ID, messages []*entity.Message) {
+		assert.Equal(t, entity.Version(6), messages[0].Version)
+	}).Return(nil)
+	outboxService.EXPECT().Deliver(string(ID)).Return(nil)
+
+	// The stock changes since the edit don't fail it
+	v, err := service.UpdateOne(ID, 2, variant.UpdateVariantDTO{Image: "https://img.example.com/a.png"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(6), *v)
+}
+
+func TestCreateTransliteratesSKU(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	variantRepo := variant.NewMockStoreRepository(controller)
+	productRepo := product.NewMockStoreRepository(controller)
+	priceListRepo := pricelist.NewMockStoreRepository(controller)
+	locationRepo := location.NewMockStoreRepository(controller)
+	typeRepo := producttype.NewMockStoreRepository(controller)
+	eventRepo := eventstore.NewMockStoreRepository(controller)
+	outboxService := outbox.NewMockUseCase(controller)
+
+	service := variant.NewService(variantRepo, productRepo, priceListRepo, locationRepo, typeRepo, eventRepo, outboxService, nil, variant.DefaultLowStockThreshold, variant.DefaultSKUTemplate)
+
+	cafe := entity.NewID()
+	tea := entity.ID("5f1c2a9e-0b7d-4c3e-9a1f-2d6e8b4c7a10")
+	unknownCurrency := []entity.ErrorField{{Field: "Currency", Error: "Unknown currency XXX"}}
+
+	// Latin letters lose their diacritics, the unknown currency stops the create once the SKU is checked
+	productRepo.EXPECT().FindOneByID(cafe).Return(&entity.Product{ID: cafe, Name: "Café Crème", Brand: "Müller"}, nil)
+	variantRepo.EXPECT().FindOneByAttribute(cafe, gomock.Any()).Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().FindOneBySKU("muller-cafe-creme-grosse").Return(nil, entity.ErrNotFound)
+
+	_, _, err := service.Create(variant.CreateVariantDTO{Product: cafe, Price: &entity.Money{Amount: 100, Currency: "XXX"}, Attributes: map[string]string{"size": "Große"}})
+
+	assert.Equal(t, unknownCurrency, err.Errors)
+
+	// Other scripts fall back to the product id and the checksum of the values, the SKUs of the variants differ
+	productRepo.EXPECT().FindOneByID(tea).Return(&entity.Product{ID: tea, Name: "绿茶"}, nil)
+	variantRepo.EXPECT().FindOneByAttribute(tea, gomock.Any()).Return(nil, entity.ErrNotFound)
+	variantRepo.EXPECT().FindOneBySKU("5f1c2a9e-d2b184ff").Return(nil, entity.ErrNotFound)
+
+	_, _, err = service.Create(variant.CreateVariantDTO{Product: tea, Price: &entity.Money{Amount: 100, Currency: "XXX"}, Attributes: map[string]string{"size": "大"}})
+
+	assert.Equal(t, unknownCurrency, err.Errors)
+}
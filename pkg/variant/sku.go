@@ -0,0 +1,88 @@
+package variant
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//ErrDuplicateSKU another variant has the same SKU
+var ErrDuplicateSKU = errors.New("Duplicate SKU")
+
+//DefaultSKUTemplate template of the SKUs generated for the variants created without one when none is configured
+const DefaultSKUTemplate = "{brand}-{product-slug}-{attrs}"
+
+//maxSKUSuffix suffixed forms tried for a generated SKU used by other variants
+const maxSKUSuffix = 100
+
+//generateSKU SKU of a new variant of the product from the product type template or the service one
+func (s *Service) generateSKU(p *entity.Product, attributes map[string]string) (string, error) {
+	template := s.skuTemplate
+	if p.Type != "" {
+		t, err := s.typeRepo.FindOneByID(p.Type)
+		if err != nil {
+			return "", err
+		}
+
+		if t.SKUTemplate != "" {
+			template = t.SKUTemplate
+		}
+	}
+
+	return entity.RenderSKU(template, p, attributes), nil
+}
+
+//uniqueSKU the generated SKU or its first suffixed form sku-2, sku-3... used neither by other variants nor in taken,
+//products sharing brand and name or templates without attributes generate the same SKUs
+func (s *Service) uniqueSKU(sku string, taken map[string]bool) (string, error) {
+	for n := 1; n <= maxSKUSuffix; n++ {
+		candidate := sku
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", sku, n)
+		}
+
+		if taken[candidate] {
+			continue
+		}
+
+		_, err := s.storeRepo.FindOneBySKU(candidate)
+		switch err {
+		case nil:
+		case entity.ErrNotFound:
+			return candidate, nil
+		default:
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("SKU %s and its %d suffixed forms are all used", sku, maxSKUSuffix)
+}
+
+//checkSKU check the SKU isn't used by another variant than ID, returns the validation error naming the variant using it
+func (s *Service) checkSKU(sku string, ID entity.ID) (*entity.ErrorField, error) {
+	v, err := s.storeRepo.FindOneBySKU(sku)
+	switch err {
+	case nil:
+		if v.ID == ID {
+			return nil, nil
+		}
+
+		return &entity.ErrorField{Field: "SKU", Error: "SKU " + sku + " already used by variant " + string(v.ID)}, nil
+	case entity.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+//duplicateSKU validation error of a SKU taken concurrently by another variant
+func (s *Service) duplicateSKU(op entity.Op, sku string) *entity.Error {
+	e := entity.ErrorField{Field: "SKU", Error: "SKU " + sku + " already used"}
+	if v, err := s.storeRepo.FindOneBySKU(sku); err == nil {
+		e.Error += " by variant " + string(v.ID)
+	}
+
+	return &entity.Error{Op: op, Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{e}}
+}
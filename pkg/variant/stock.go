@@ -0,0 +1,342 @@
+package variant
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fatih/structs"
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//PostMovementDTO stock movement DTO, quantity is signed: receipts and returns add stock, sales remove it
+//and adjustments may do both
+type PostMovementDTO struct {
+	Location  entity.ID `json:"location" validate:"required" structs:"location"`
+	Type      string    `json:"type" validate:"required,oneof=receipt sale return adjustment" structs:"type"`
+	Quantity  int       `json:"quantity" validate:"required" structs:"quantity"`
+	Reason    string    `json:"reason,omitempty" validate:"omitempty" structs:"reason,omitempty"`
+	Reference string    `json:"reference,omitempty" validate:"omitempty" structs:"reference,omitempty"`
+}
+
+//PostMovement record a stock movement of the variant at a location and apply it to the available stock,
+//stock can't be removed below the quantity available at the location
+func (s *Service) PostMovement(ID entity.ID, postMovementDTO PostMovementDTO) (*entity.StockMovement, *entity.Error) {
+	if err := validator.New().Struct(postMovementDTO); err != nil {
+		errs := entity.Error{Op: "PostMovement", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	errs := entity.Error{Op: "PostMovement", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+	switch postMovementDTO.Type {
+	case entity.StockReceipt, entity.StockReturn:
+		if postMovementDTO.Quantity < 0 {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Quantity", Error: "A " + postMovementDTO.Type + " adds stock, provide a positive quantity"})
+		}
+	case entity.StockSale:
+		if postMovementDTO.Quantity > 0 {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Quantity", Error: "A sale removes stock, provide a negative quantity"})
+		}
+	case entity.StockAdjustment:
+		if postMovementDTO.Reason == "" {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Reason", Error: "Provide the adjustment reason"})
+		}
+	}
+
+	_, err := s.locationRepo.FindOneByID(postMovementDTO.Location)
+	switch err {
+	case entity.ErrNotFound:
+		errs.Errors = append(errs.Errors, entity.ErrorField{Field: "Location", Error: "Location with ID " + string(postMovementDTO.Location) + " doesn't Exist"})
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "PostMovement", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	if len(errs.Errors) > 0 {
+		return nil, &errs
+	}
+
+	Timestamp := time.Now()
+
+	v, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "PostMovement", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "PostMovement", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	threshold, err := s.threshold(v.Product)
+	if err != nil {
+		return nil, &entity.Error{Op: "PostMovement", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	m := &entity.StockMovement{
+		ID:        entity.NewID(),
+		Variant:   ID,
+		Location:  postMovementDTO.Location,
+		Type:      postMovementDTO.Type,
+		Quantity:  postMovementDTO.Quantity,
+		Reason:    postMovementDTO.Reason,
+		Reference: postMovementDTO.Reference,
+		CreatedAt: Timestamp,
+	}
+
+	c := &entity.Command{AggregateID: string(ID), Type: "PostStockMovement", Payload: structs.Map(postMovementDTO), Timestamp: Timestamp}
+
+	var alerts []*entity.Message
+	err = s.storeRepo.WithTransaction(func(tx StoreRepository) error {
+		commandID, err := tx.StoreCommand(c)
+		if err != nil {
+			return err
+		}
+
+		updated, err := tx.MoveStock(ID, m.Location, m.Quantity)
+		if err != nil {
+			return err
+		}
+		m.Version = updated.Version
+
+		if err := tx.CreateMovement(m); err != nil {
+			return err
+		}
+
+		messages := []*entity.Message{movementMessage(m)}
+
+		a, err := alert(tx, updated, m.Quantity, threshold, Timestamp)
+		if err != nil {
+			return err
+		}
+		if a != nil {
+			alerts = []*entity.Message{a}
+			messages = append(messages, a)
+		}
+
+		return tx.StoreMessages(*commandID, messages)
+	})
+	switch err {
+	case ErrInsufficientStock:
+		return nil, &entity.Error{Op: "PostMovement", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel, Errors: []entity.ErrorField{{Field: "Quantity", Error: "Insufficient stock"}}}
+	case entity.ErrVersionConflict:
+		return nil, &entity.Error{Op: "PostMovement", Kind: entity.ConcurrentModification, ErrorMessage: entity.ErrorMessage("Version conflict"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "PostMovement", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	s.notify(alerts)
+
+	if err := s.outbox.Deliver(string(ID)); err != nil {
+		return m, &entity.Error{Op: "PostMovement", Kind: entity.DeliveryFailed, ErrorMessage: "Posted, events delivery pending", Severity: logrus.WarnLevel, Err: err}
+	}
+
+	return m, nil
+}
+
+//ListMovementsDTO variant stock movements page DTO
+type ListMovementsDTO struct {
+	Page  int `validate:"omitempty,min=1"`
+	Limit int `validate:"omitempty,min=1,max=100"`
+}
+
+//FindMovements list the variant stock movements, latest first
+func (s *Service) FindMovements(ID entity.ID, listMovementsDTO ListMovementsDTO) ([]*entity.StockMovement, *entity.Error) {
+	if err := validator.New().Struct(listMovementsDTO); err != nil {
+		errs := entity.Error{Op: "FindMovements", Kind: entity.ValidationFailed, ErrorMessage: "Validation Failed", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	if _, err := s.storeRepo.FindOneByID(ID); err != nil {
+		if err == entity.ErrNotFound {
+			return nil, &entity.Error{Op: "FindMovements", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+		}
+
+		return nil, &entity.Error{Op: "FindMovements", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	page, limit := listMovementsDTO.Page, listMovementsDTO.Limit
+	if page == 0 {
+		page = 1
+	}
+	if limit == 0 {
+		limit = 20
+	}
+
+	movements, err := s.storeRepo.FindMovements(ID, (page-1)*limit, limit)
+	if err != nil {
+		return nil, &entity.Error{Op: "FindMovements", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return movements, nil
+}
+
+//movementMessage stock moved event of the movement
+func movementMessage(m *entity.StockMovement) *entity.Message {
+	payload := make(map[string]interface{})
+	payload["movement"] = string(m.ID)
+	payload["location"] = string(m.Location)
+	payload["type"] = m.Type
+	payload["quantity"] = m.Quantity
+	if m.Reason != "" {
+		payload["reason"] = m.Reason
+	}
+	if m.Reference != "" {
+		payload["reference"] = m.Reference
+	}
+
+	return &entity.Message{ID: string(m.Variant), Type: "PRODUCT_VARIANT_STOCK_MOVED", Version: m.Version, Payload: payload, Timestamp: m.CreatedAt}
+}
+
+//saleMovement sale movement of a committed reservation, identified by the reservation
+func saleMovement(r *entity.Reservation, version entity.Version, t time.Time) *entity.StockMovement {
+	return &entity.StockMovement{
+		ID:        r.ID,
+		Variant:   r.Variant,
+		Location:  r.Location,
+		Type:      entity.StockSale,
+		Quantity:  -r.Quantity,
+		Reference: r.Reference,
+		Version:   version,
+		CreatedAt: t,
+	}
+}
+
+//stockMovements the ledger of the variant rebuilt from its events, absolute quantity updates predating the ledger
+//are recorded as adjustments of the difference at the default location
+func stockMovements(events []*entity.StoredEvent) []*entity.StockMovement {
+	var movements []*entity.StockMovement
+	available := 0
+
+	for _, e := range events {
+		switch e.Type {
+		case "PRODUCT_VARIANT_QUANTITY_UPDATED":
+			if delta := int(e.Int("quantity")) - available; delta != 0 {
+				movements = append(movements, &entity.StockMovement{
+					ID:        e.ID,
+					Variant:   entity.ID(e.AggregateID),
+					Location:  entity.DefaultLocation,
+					Type:      entity.StockAdjustment,
+					Quantity:  delta,
+					Reason:    "Quantity updated",
+					Version:   e.Version,
+					CreatedAt: e.Timestamp,
+				})
+			}
+			available = int(e.Int("quantity"))
+		case "PRODUCT_VARIANT_STOCK_MOVED":
+			movements = append(movements, &entity.StockMovement{
+				ID:        entity.ID(e.String("movement")),
+				Variant:   entity.ID(e.AggregateID),
+				Location:  eventLocation(e),
+				Type:      e.String("type"),
+				Quantity:  int(e.Int("quantity")),
+				Reason:    e.String("reason"),
+				Reference: e.String("reference"),
+				Version:   e.Version,
+				CreatedAt: e.Timestamp,
+			})
+			available += int(e.Int("quantity"))
+		case "PRODUCT_VARIANT_STOCK_RESERVED":
+			available -= int(e.Int("quantity"))
+		case "PRODUCT_VARIANT_STOCK_RELEASED":
+			available += int(e.Int("quantity"))
+		case "PRODUCT_VARIANT_STOCK_COMMITTED":
+			r := &entity.Reservation{ID: entity.ID(e.String("reservation")), Variant: entity.ID(e.AggregateID), Location: eventLocation(e), Quantity: int(e.Int("quantity")), Reference: e.String("reference")}
+			movements = append(movements, saleMovement(r, e.Version, e.Timestamp))
+		}
+	}
+
+	return movements
+}
+
+//eventLocation location of a stock event, the default location for the events recorded before locations
+func eventLocation(e *entity.StoredEvent) entity.ID {
+	if l := e.String("location"); l != "" {
+		return entity.ID(l)
+	}
+
+	return entity.DefaultLocation
+}
+
+//stock per location stock of a new variant holding the opening movement
+func stock(opening *entity.StockMovement) map[string]*entity.Stock {
+	if opening == nil {
+		return nil
+	}
+
+	return map[string]*entity.Stock{string(opening.Location): {Quantity: opening.Quantity}}
+}
+
+//stockAt variant stock at the location, created if missing
+func stockAt(v *entity.Variant, location entity.ID) *entity.Stock {
+	if v.Stock == nil {
+		v.Stock = map[string]*entity.Stock{}
+	}
+
+	if v.Stock[string(location)] == nil {
+		v.Stock[string(location)] = &entity.Stock{}
+	}
+
+	return v.Stock[string(location)]
+}
+
+//LocationStock variant stock at a location
+type LocationStock struct {
+	Location entity.ID `json:"location"`
+	entity.Stock
+}
+
+//Availability variant stock per location with the totals
+type Availability struct {
+	Variant   entity.ID        `json:"variant"`
+	Locations []*LocationStock `json:"locations"`
+	Quantity  int              `json:"quantity"`
+	Reserved  int              `json:"reserved"`
+}
+
+//FindStock the variant available and reserved stock per location and in total
+func (s *Service) FindStock(ID entity.ID) (*Availability, *entity.Error) {
+	v, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindStock", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Variant with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindStock", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return availability(v), nil
+}
+
+//availability variant stock per location sorted by location, the totals are the variant counters
+func availability(v *entity.Variant) *Availability {
+	a := &Availability{Variant: v.ID, Locations: []*LocationStock{}, Quantity: v.Quantity, Reserved: v.Reserved}
+
+	for l, stock := range v.Stock {
+		a.Locations = append(a.Locations, &LocationStock{Location: entity.ID(l), Stock: *stock})
+	}
+
+	sort.Slice(a.Locations, func(i, j int) bool {
+		return a.Locations[i].Location < a.Locations[j].Location
+	})
+
+	return a
+}
@@ -0,0 +1,53 @@
+//go:generate mockgen -source interface.go -destination webhook_mock.go -package webhook
+
+package webhook
+
+import "github.com/markus-azer/products-service/pkg/entity"
+
+//MessagesWriter webhook calls writer
+type messagesWriter interface {
+	Post(url string, m *entity.Message) error
+}
+
+//MessagesRepository repository interface
+type MessagesRepository interface {
+	messagesWriter
+}
+
+//StoreReader webhook reader interface
+type storeReader interface {
+	FindOneByID(id entity.ID) (*entity.Webhook, error)
+	FindMany() ([]*entity.Webhook, error)
+	FindByEvent(eventType string) ([]*entity.Webhook, error)
+}
+
+//StoreWriter webhook writer interface
+type storeWriter interface {
+	Create(w *entity.Webhook) error
+	DeleteOne(id entity.ID) (int, error)
+}
+
+//StoreRepository webhook store repository interface
+type StoreRepository interface {
+	storeReader
+	storeWriter
+}
+
+//Reader interface
+type reader interface {
+	FindOneByID(id entity.ID) (*entity.Webhook, *entity.Error)
+	FindMany() ([]*entity.Webhook, *entity.Error)
+}
+
+//Writer interface
+type writer interface {
+	Create(createWebhookDTO CreateWebhookDTO) (*entity.ID, *entity.Error)
+	Delete(id entity.ID) *entity.Error
+	Notify(messages []*entity.Message) int
+}
+
+//UseCase use case interface
+type UseCase interface {
+	reader
+	writer
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+)
+
+//HTTPRepository http repo
+type HTTPRepository struct {
+	client *http.Client
+}
+
+//NewHTTPRepository create new repository, timeout bounds each webhook call
+func NewHTTPRepository(timeout time.Duration) MessagesRepository {
+	return &HTTPRepository{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+//Post post the message as json to the url, any non 2xx status is an error
+func (r *HTTPRepository) Post(url string, m *entity.Message) error {
+	reqBodyBytes := new(bytes.Buffer)
+	if err := json.NewEncoder(reqBodyBytes).Encode(m); err != nil {
+		return err
+	}
+
+	res, err := r.client.Post(url, "application/json", reqBodyBytes)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("Webhook responded with status %d", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"log"
+
+	"github.com/markus-azer/products-service/pkg/entity"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//MongoRepository mongodb repo
+type MongoRepository struct {
+	db *mongo.Database
+}
+
+//NewMongoRepository create new repository
+func NewMongoRepository(db *mongo.Database) StoreRepository {
+	r := &MongoRepository{
+		db: db,
+	}
+	r.createIndexes()
+
+	return r
+}
+
+//createIndexes lookups by event
+func (r *MongoRepository) createIndexes() {
+	coll := r.db.Collection("webhooks")
+
+	models := []mongo.IndexModel{
+		{Keys: bson.D{primitive.E{Key: "events", Value: 1}}},
+	}
+
+	if _, err := coll.Indexes().CreateMany(context.TODO(), models); err != nil {
+		log.Println("Error on creating webhooks indexes", err)
+	}
+}
+
+//FindOneByID find webhook by Id
+func (r *MongoRepository) FindOneByID(id entity.ID) (*entity.Webhook, error) {
+	result := entity.Webhook{}
+	coll := r.db.Collection("webhooks")
+	err := coll.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&result)
+
+	switch err {
+	case nil:
+		return &result, nil
+	case mongo.ErrNoDocuments:
+		return nil, entity.ErrNotFound
+	default:
+		return nil, err
+	}
+}
+
+//FindMany find all the webhooks
+func (r *MongoRepository) FindMany() ([]*entity.Webhook, error) {
+	return r.find(bson.M{})
+}
+
+//FindByEvent find the webhooks registered to an event type
+func (r *MongoRepository) FindByEvent(eventType string) ([]*entity.Webhook, error) {
+	return r.find(bson.M{"events": eventType})
+}
+
+func (r *MongoRepository) find(query bson.M) ([]*entity.Webhook, error) {
+	coll := r.db.Collection("webhooks")
+
+	cur, err := coll.Find(context.TODO(), query, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.TODO())
+
+	webhooks := []*entity.Webhook{}
+	if err := cur.All(context.TODO(), &webhooks); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+//Create create new webhook
+func (r *MongoRepository) Create(w *entity.Webhook) error {
+	coll := r.db.Collection("webhooks")
+
+	_, err := coll.InsertOne(context.TODO(), w)
+
+	return err
+}
+
+//DeleteOne delete a webhook, returns the number of deleted webhooks
+func (r *MongoRepository) DeleteOne(id entity.ID) (int, error) {
+	coll := r.db.Collection("webhooks")
+
+	result, err := coll.DeleteOne(context.TODO(), bson.M{"_id": id})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.DeletedCount), nil
+}
@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-playground/validator"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/sirupsen/logrus"
+)
+
+//Service service interface
+type Service struct {
+	storeRepo    StoreRepository
+	messagesRepo MessagesRepository
+}
+
+//NewService create new service
+func NewService(storeR StoreRepository, messagesR MessagesRepository) *Service {
+	return &Service{
+		storeRepo:    storeR,
+		messagesRepo: messagesR,
+	}
+}
+
+//CreateWebhookDTO new webhook DTO
+type CreateWebhookDTO struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=PRODUCT_VARIANT_LOW_STOCK PRODUCT_VARIANT_OUT_OF_STOCK"`
+}
+
+//Create register a webhook to events
+func (s *Service) Create(createWebhookDTO CreateWebhookDTO) (*entity.ID, *entity.Error) {
+	if err := validator.New().Struct(createWebhookDTO); err != nil {
+		errs := entity.Error{Op: "Create", Kind: entity.ValidationFailed, ErrorMessage: "Provide valid Payload", Severity: logrus.InfoLevel}
+
+		for _, e := range err.(validator.ValidationErrors) {
+			errs.Errors = append(errs.Errors, entity.ErrorField{Field: e.Field(), Error: fmt.Sprint(e)})
+		}
+
+		return nil, &errs
+	}
+
+	w := &entity.Webhook{
+		ID:        entity.NewID(),
+		URL:       createWebhookDTO.URL,
+		Events:    createWebhookDTO.Events,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.storeRepo.Create(w); err != nil {
+		return nil, &entity.Error{Op: "Create", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return &w.ID, nil
+}
+
+//FindOneByID webhook
+func (s *Service) FindOneByID(ID entity.ID) (*entity.Webhook, *entity.Error) {
+	w, err := s.storeRepo.FindOneByID(ID)
+	switch err {
+	case entity.ErrNotFound:
+		return nil, &entity.Error{Op: "FindOneByID", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Webhook with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	default:
+		if err != nil {
+			return nil, &entity.Error{Op: "FindOneByID", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+		}
+	}
+
+	return w, nil
+}
+
+//FindMany all the webhooks
+func (s *Service) FindMany() ([]*entity.Webhook, *entity.Error) {
+	webhooks, err := s.storeRepo.FindMany()
+	if err != nil {
+		return nil, &entity.Error{Op: "FindMany", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	return webhooks, nil
+}
+
+//Delete unregister a webhook
+func (s *Service) Delete(ID entity.ID) *entity.Error {
+	deletedNum, err := s.storeRepo.DeleteOne(ID)
+	if err != nil {
+		return &entity.Error{Op: "Delete", Kind: entity.Unexpected, ErrorMessage: "Internal Server Error", Severity: logrus.ErrorLevel, Err: err}
+	}
+
+	if deletedNum != 1 {
+		return &entity.Error{Op: "Delete", Kind: entity.NotFound, ErrorMessage: entity.ErrorMessage("Webhook with id " + string(ID) + " Not found"), Severity: logrus.InfoLevel}
+	}
+
+	return nil
+}
+
+//Notify call the webhooks registered to each message type, failed calls are logged and not retried
+//returns the number of successful calls
+func (s *Service) Notify(messages []*entity.Message) int {
+	n := 0
+
+	for _, m := range messages {
+		webhooks, err := s.storeRepo.FindByEvent(m.Type)
+		if err != nil {
+			log.Println("Error on finding webhooks of", m.Type, err)
+			continue
+		}
+
+		for _, w := range webhooks {
+			if err := s.messagesRepo.Post(w.URL, m); err != nil {
+				log.Println("Error on calling webhook", w.ID, m.Type, err)
+				continue
+			}
+
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,38 @@
+package webhook_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/markus-azer/products-service/pkg/entity"
+	"github.com/markus-azer/products-service/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyCallsRegisteredWebhooks(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	storeRepo := webhook.NewMockStoreRepository(controller)
+	messagesRepo := webhook.NewMockMessagesRepository(controller)
+
+	service := webhook.NewService(storeRepo, messagesRepo)
+
+	lowStock := &entity.Message{ID: string(entity.NewID()), Type: "PRODUCT_VARIANT_LOW_STOCK", Version: 5}
+	outOfStock := &entity.Message{ID: string(entity.NewID()), Type: "PRODUCT_VARIANT_OUT_OF_STOCK", Version: 8}
+
+	storeRepo.EXPECT().FindByEvent("PRODUCT_VARIANT_LOW_STOCK").Return([]*entity.Webhook{
+		{ID: entity.NewID(), URL: "https://a.example.com/hooks"},
+		{ID: entity.NewID(), URL: "https://b.example.com/hooks"},
+	}, nil)
+	storeRepo.EXPECT().FindByEvent("PRODUCT_VARIANT_OUT_OF_STOCK").Return([]*entity.Webhook{}, nil)
+
+	// A failing webhook doesn't stop the others
+	messagesRepo.EXPECT().Post("https://a.example.com/hooks", lowStock).Return(errors.New("Webhook responded with status 500"))
+	messagesRepo.EXPECT().Post("https://b.example.com/hooks", lowStock).Return(nil)
+
+	n := service.Notify([]*entity.Message{lowStock, outOfStock})
+
+	assert.Equal(t, 1, n)
+}